@@ -0,0 +1,181 @@
+package silk
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+//Silk file format ids of the augmented record formats. Records in these
+//formats are IPv4 only and store their start time as an offset from the
+//hour in variable length header entry 1.
+const (
+	FormatRWAugSNMPOut uint8 = 0x0D
+	FormatRWAugRouting uint8 = 0x0E
+	FormatRWAugmented  uint8 = 0x14
+	FormatRWAugWeb     uint8 = 0x15
+)
+
+//augmentedVersion is the record version of the augmented formats that is supported
+const augmentedVersion uint16 = 5
+
+//pktsDivisor is the multiplier applied to the packet count when the pflag bit is set
+const pktsDivisor = 64
+
+//bppPrecision is the number of fractional bytes-per-packet steps
+const bppPrecision = 64
+
+//webPorts maps the 2 bit server port index of the web formats to a port
+var webPorts = [4]uint16{80, 443, 8080, 0}
+
+//augmentedRecordSizes maps each augmented format to its version 5 record size
+//
+//	FT_RWAUGMENTED  28 bytes
+//	 0- 3 stime_bb1
+//	 4- 7 bb2_elapsed
+//	 8-11 pro_flg_pkts
+//	12    tcp_state
+//	13    rest_flags
+//	14-15 application
+//	16-17 sPort
+//	18-19 dPort
+//	20-23 sIP
+//	24-27 dIP
+//
+//	FT_RWAUGROUTING 36 bytes, FT_RWAUGMENTED with
+//	20-21 input
+//	22-23 output
+//	24-27 sIP
+//	28-31 dIP
+//	32-35 nhIP
+//
+//	FT_RWAUGSNMPOUT 30 bytes, FT_RWAUGMENTED with
+//	28-29 output
+//
+//	FT_RWAUGWEB     26 bytes
+//	 0-15 as FT_RWAUGMENTED, pro_flg_pkts padding is the server port index
+//	      and is_tcp is set when the source is the server
+//	16-19 sIP
+//	20-23 dIP
+//	24-25 client port
+var augmentedRecordSizes = map[uint8]uint16{
+	FormatRWAugmented:  28,
+	FormatRWAugRouting: 36,
+	FormatRWAugSNMPOut: 30,
+	FormatRWAugWeb:     26,
+}
+
+func init() {
+	for format, size := range augmentedRecordSizes {
+		registerFormat(format, augmentedVersion, recordFormat{size: size, packed: true, decode: decodeAugmented})
+	}
+}
+
+//decodeAugmented decodes a version 5 augmented record into silkFlow
+func decodeAugmented(record []byte, h *Header, silkFlow *Record) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if h.FileFlags != 0 {
+		order = binary.BigEndian
+	}
+
+	*silkFlow = Record{}
+	protFlags, isTCP, padding := unpackStimeBppPkts(record, order, h, silkFlow)
+	silkFlow.Application = order.Uint16(record[14:16])
+	silkFlow.Sensor = uint16(h.fileSensor)
+	silkFlow.ClassType = uint8(h.fileFlowType)
+
+	if h.RecordFormat == FormatRWAugWeb {
+		//Web flows are always TCP, the is_tcp bit says which side is the server
+		unpackProtoFlags(silkFlow, true, protFlags, record[12], record[13])
+		silkFlow.SrcIP = decodeIPv4(record[16:20], order)
+		silkFlow.DstIP = decodeIPv4(record[20:24], order)
+		var clientPort = order.Uint16(record[24:26])
+		if isTCP {
+			silkFlow.SrcPort = webPorts[padding]
+			silkFlow.DstPort = clientPort
+		} else {
+			silkFlow.SrcPort = clientPort
+			silkFlow.DstPort = webPorts[padding]
+		}
+		return
+	}
+
+	unpackProtoFlags(silkFlow, isTCP, protFlags, record[12], record[13])
+	silkFlow.SrcPort = order.Uint16(record[16:18])
+	silkFlow.DstPort = order.Uint16(record[18:20])
+
+	switch h.RecordFormat {
+	case FormatRWAugRouting:
+		silkFlow.SNMPIn = order.Uint16(record[20:22])
+		silkFlow.SNMPOut = order.Uint16(record[22:24])
+		silkFlow.SrcIP = decodeIPv4(record[24:28], order)
+		silkFlow.DstIP = decodeIPv4(record[28:32], order)
+		silkFlow.NextHopIP = decodeIPv4(record[32:36], order)
+	case FormatRWAugSNMPOut:
+		silkFlow.SrcIP = decodeIPv4(record[20:24], order)
+		silkFlow.DstIP = decodeIPv4(record[24:28], order)
+		silkFlow.SNMPOut = order.Uint16(record[28:30])
+	default:
+		silkFlow.SrcIP = decodeIPv4(record[20:24], order)
+		silkFlow.DstIP = decodeIPv4(record[24:28], order)
+	}
+}
+
+//unpackStimeBppPkts decodes the three 32 bit words that start the millisecond
+//packed record formats into the start time, duration, packets and bytes of
+//silkFlow. The start time is an offset from the file hour and bytes are stored
+//as a bytes-per-packet value with 6 fractional bits. When pflag is set the
+//packet count was divided by pktsDivisor to fit in 20 bits.
+//
+//	stime_bb1    stime:22 bPPkt1:10
+//	bb2_elapsed  bPPkt2:4 bPPFrac:6 elapsed:22
+//	pro_flg_pkts prot_flags:8 pflag:1 is_tcp:1 padding:2 pkts:20
+//
+//The protocol/flags byte, is_tcp bit and padding bits are returned for the caller.
+func unpackStimeBppPkts(record []byte, order binary.ByteOrder, h *Header, silkFlow *Record) (protFlags uint8, isTCP bool, padding uint8) {
+	var stimeBB1 = order.Uint32(record[0:4])
+	var bb2Elapsed = order.Uint32(record[4:8])
+	var proFlgPkts = order.Uint32(record[8:12])
+
+	silkFlow.StartTimeMS = h.fileDateMS + uint64(stimeBB1>>10)
+	silkFlow.Duration = bb2Elapsed & 0x3FFFFF
+
+	var bpp = uint64((stimeBB1&0x3FF)<<10 | bb2Elapsed>>22)
+	var packets = uint64(proFlgPkts & 0xFFFFF)
+	if (proFlgPkts>>23)&1 != 0 {
+		packets *= pktsDivisor
+	}
+	silkFlow.Packets = uint32(packets)
+	silkFlow.Bytes = uint32((bpp>>6)*packets + ((bpp&0x3F)*packets+bppPrecision/2)/bppPrecision)
+
+	protFlags = uint8(proFlgPkts >> 24)
+	isTCP = (proFlgPkts>>22)&1 != 0
+	padding = uint8(proFlgPkts>>20) & 0x3
+	return
+}
+
+//unpackProtoFlags sets the protocol and TCP flags of silkFlow from the packed
+//protocol/flags byte. For TCP flows with expanded state the packed byte holds
+//the initial flags and restFlags the flags of the remaining packets, for other
+//protocols restFlags holds the flow's reported flags.
+func unpackProtoFlags(silkFlow *Record, isTCP bool, protFlags uint8, tcpState uint8, restFlags uint8) {
+	silkFlow.Attributes = tcpState
+	if isTCP == false {
+		silkFlow.Proto = protFlags
+		silkFlow.Flags = restFlags
+		return
+	}
+	silkFlow.Proto = 6
+	if (tcpState & SilkTCPStateExpanded) != 0 {
+		silkFlow.InitalFlags = protFlags
+		silkFlow.SessionFlags = restFlags
+		silkFlow.Flags = protFlags | restFlags
+	} else {
+		silkFlow.Flags = protFlags
+	}
+}
+
+//decodeIPv4 decodes an IPv4 address stored as a uint32 in the file byte order
+func decodeIPv4(b []byte, order binary.ByteOrder) netip.Addr {
+	var ip = order.Uint32(b)
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)})
+}
@@ -0,0 +1,128 @@
+package silk
+
+import (
+	"compress/zlib"
+	"io"
+)
+
+//BodyReader reads the bytes after the header of a silk file that does not
+//hold flow records, such as an IPset, bag or prefix map. Compressed blocks
+//are decompressed so Read returns the body as one stream of bytes.
+type BodyReader struct {
+	r            io.Reader
+	header       Header
+	blockHeader  []byte
+	compressed   []byte
+	buf          []byte
+	pos          int
+	decompressor decompressor
+}
+
+//NewBodyReader parses the silk header from r and returns a BodyReader
+//positioned at the start of the body. The record format is not checked.
+func NewBodyReader(r io.Reader) (br *BodyReader, err error) {
+	var header Header
+	if header, err = parseHeader(r); err != nil {
+		return
+	}
+	switch header.Compression {
+	case 0, 1, 2, 3:
+	default:
+		err = ErrUnsupportedCompression
+		return
+	}
+	br = &BodyReader{r: r, header: header, blockHeader: make([]byte, 8)}
+	return
+}
+
+//Header returns the parsed silk file header
+func (br *BodyReader) Header() Header {
+	return br.header
+}
+
+//Read reads decompressed body bytes into p, io.EOF is returned at the end of the body
+func (br *BodyReader) Read(p []byte) (n int, err error) {
+	if br.header.Compression == 0 {
+		return br.r.Read(p)
+	}
+	for br.pos == len(br.buf) {
+		var decompressedBlockSize int
+		if br.compressed, decompressedBlockSize, err = readFrame(br.r, br.blockHeader, br.compressed); err != nil {
+			return
+		}
+		if decompressedBlockSize > cap(br.buf) {
+			br.buf = make([]byte, decompressedBlockSize)
+		}
+		if br.buf, err = br.decompressor.decompress(br.header.Compression, br.compressed, br.buf[:decompressedBlockSize]); err != nil {
+			return
+		}
+		br.buf = br.buf[:decompressedBlockSize]
+		br.pos = 0
+	}
+	n = copy(p, br.buf[br.pos:])
+	br.pos += n
+	return
+}
+
+//BodyWriter writes the body of a silk file that does not hold flow records.
+//Bytes are buffered and written a block at a time, compressed when the
+//header has a compression type. Close must be called to write any
+//remaining buffered bytes.
+type BodyWriter struct {
+	sw *Writer
+}
+
+//NewBodyWriter writes the silk header h to w and returns a BodyWriter for
+//its body. Header defaults are filled in as NewWriter fills them, a zero
+//RecordSize is written as 1. The record format is not checked.
+func NewBodyWriter(w io.Writer, h Header) (bw *BodyWriter, err error) {
+	switch h.Compression {
+	case 0, 1, 2, 3:
+	default:
+		err = ErrUnsupportedCompression
+		return
+	}
+	if h.RecordSize == 0 {
+		h.RecordSize = 1
+	}
+	if err = writeHeader(w, &h); err != nil {
+		return
+	}
+	bw = &BodyWriter{sw: &Writer{
+		w:           w,
+		header:      h,
+		buf:         make([]byte, DefaultBlockSize),
+		blockHeader: make([]byte, 8),
+	}}
+	if h.Compression == 1 {
+		bw.sw.zlibWriter = zlib.NewWriter(&bw.sw.compressed)
+	}
+	return
+}
+
+//Header returns the header as it was written, including the end of header padding
+func (bw *BodyWriter) Header() Header {
+	return bw.sw.header
+}
+
+//Write buffers p, writing each full block
+func (bw *BodyWriter) Write(p []byte) (n int, err error) {
+	var sw = bw.sw
+	for len(p) > 0 {
+		if sw.n == len(sw.buf) {
+			if err = sw.Flush(); err != nil {
+				return
+			}
+		}
+		var copied = copy(sw.buf[sw.n:], p)
+		sw.n += copied
+		n += copied
+		p = p[copied:]
+	}
+	return
+}
+
+//Close writes buffered bytes. It does not close the underlying io.Writer.
+func (bw *BodyWriter) Close() error {
+	return bw.sw.Flush()
+}
@@ -0,0 +1,55 @@
+package silk
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+//TestBodyRoundTrip verifies a body spanning several blocks reads back the
+//same with each compression
+func TestBodyRoundTrip(t *testing.T) {
+	var body = make([]byte, 3*DefaultBlockSize+1234)
+	for x := range body {
+		body[x] = byte(x * 7 / 13)
+	}
+	for compression := uint8(0); compression <= 3; compression++ {
+		var buf bytes.Buffer
+		var bw, err = NewBodyWriter(&buf, Header{RecordFormat: 0x1D, RecordVersion: 4, RecordSize: 5, Compression: compression})
+		if err != nil {
+			t.Fatalf("NewBodyWriter compression:%d error:%s", compression, err)
+		}
+		//uneven writes cross block boundaries
+		for x := 0; x < len(body); x += 1000 {
+			var end = x + 1000
+			if end > len(body) {
+				end = len(body)
+			}
+			if _, err = bw.Write(body[x:end]); err != nil {
+				t.Fatalf("Write compression:%d error:%s", compression, err)
+			}
+		}
+		if err = bw.Close(); err != nil {
+			t.Fatalf("Close compression:%d error:%s", compression, err)
+		}
+
+		var br *BodyReader
+		if br, err = NewBodyReader(&buf); err != nil {
+			t.Fatalf("NewBodyReader compression:%d error:%s", compression, err)
+		}
+		if h := br.Header(); h.RecordFormat != 0x1D || h.RecordVersion != 4 || h.Compression != compression {
+			t.Errorf("Compression:%d header format:%d version:%d compression:%d", compression, h.RecordFormat, h.RecordVersion, h.Compression)
+		}
+		var read []byte
+		if read, err = io.ReadAll(br); err != nil {
+			t.Fatalf("ReadAll compression:%d error:%s", compression, err)
+		}
+		if bytes.Equal(read, body) == false {
+			t.Errorf("Compression:%d read:%d bytes expected:%d", compression, len(read), len(body))
+		}
+	}
+
+	if _, err := NewBodyWriter(&bytes.Buffer{}, Header{Compression: 9}); err != ErrUnsupportedCompression {
+		t.Errorf("NewBodyWriter compression:9 error:%v expected:%s", err, ErrUnsupportedCompression)
+	}
+}
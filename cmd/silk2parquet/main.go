@@ -0,0 +1,174 @@
+//Command silk2parquet converts silk flow files to Apache Parquet. Given a
+//directory, such as an rwflowpack data root of hourly files, every file under
+//it is converted to a file of the same relative path with a .parquet suffix
+//under the output directory. Given a file it writes a single output file.
+//
+//	silk2parquet [-compression=snappy] [-row-group-size=262144] [-workers=4] INPUT OUTPUT
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/parquet"
+)
+
+//conversion is an input file and the parquet file it is converted to
+type conversion struct {
+	input  string
+	output string
+}
+
+func main() {
+	var compression = flag.String("compression", "snappy", "parquet compression: none, snappy or gzip")
+	var rowGroupSize = flag.Int("row-group-size", parquet.DefaultRowGroupSize, "rows in each parquet row group")
+	var pageSize = flag.Int("page-size", parquet.DefaultPageSize, "target uncompressed data page size in bytes")
+	var workers = flag.Int("workers", 1, "files converted at the same time")
+	var skipExisting = flag.Bool("skip-existing", false, "do not convert files whose output file exists")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [OPTIONS] INPUT OUTPUT\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts = parquet.Options{RowGroupSize: *rowGroupSize, PageSize: *pageSize}
+	var err error
+	if opts.Compression, err = parquet.ParseCompression(*compression); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+
+	var conversions []conversion
+	if conversions, err = findFiles(flag.Arg(0), flag.Arg(1)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	var jobs = make(chan conversion)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var exitCode int
+	if *workers < 1 {
+		*workers = 1
+	}
+	for x := 0; x < *workers; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				if *skipExisting {
+					if _, err := os.Stat(c.output); err == nil {
+						continue
+					}
+				}
+				if err := convert(c, opts); err != nil {
+					mu.Lock()
+					fmt.Fprintf(os.Stderr, "%s: %s\n", c.input, err)
+					exitCode = 1
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, c := range conversions {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+	os.Exit(exitCode)
+}
+
+//findFiles returns the conversion of input, or of every regular file under
+//input when it is a directory
+func findFiles(input string, output string) (conversions []conversion, err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(input); err != nil {
+		return
+	}
+	if info.IsDir() == false {
+		conversions = append(conversions, conversion{input: input, output: output})
+		return
+	}
+	err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() == false {
+			return nil
+		}
+		rel, err := filepath.Rel(input, path)
+		if err != nil {
+			return err
+		}
+		conversions = append(conversions, conversion{input: path, output: filepath.Join(output, rel+".parquet")})
+		return nil
+	})
+	return
+}
+
+//convert writes the flows of c.input to c.output. The parquet file is
+//written to a temporary file that is renamed once complete so an
+//interrupted conversion never leaves a partial output file.
+func convert(c conversion, opts parquet.Options) (err error) {
+	var in, out *os.File
+	if in, err = os.Open(c.input); err != nil {
+		return
+	}
+	defer in.Close()
+
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(bufio.NewReader(in)); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(c.output), 0755); err != nil {
+		return
+	}
+	if out, err = os.CreateTemp(filepath.Dir(c.output), filepath.Base(c.output)+".tmp*"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+	//Temporary files are only readable by their owner
+	if err = out.Chmod(0644); err != nil {
+		return
+	}
+
+	var bw = bufio.NewWriterSize(out, 1<<20)
+	var pw *parquet.Writer
+	if pw, err = parquet.NewWriter(bw, opts); err != nil {
+		return
+	}
+	for sr.Next() {
+		if err = pw.WriteRecord(sr.Record()); err != nil {
+			return
+		}
+	}
+	if err = sr.Err(); err != nil {
+		return
+	}
+	if err = pw.Close(); err != nil {
+		return
+	}
+	if err = bw.Flush(); err != nil {
+		return
+	}
+	if err = out.Close(); err != nil {
+		return
+	}
+	return os.Rename(out.Name(), c.output)
+}
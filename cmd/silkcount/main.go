@@ -0,0 +1,132 @@
+//Command silkcount prints the records, bytes and packets of silk flow files
+//in time bins like rwcount. Files are read in order, standard input is read
+//when no files are given.
+//
+//	silkcount [--bin-size=30] [--load-scheme=time-proportional] [--skip-zeroes] FILE...
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/count"
+	"github.com/chrispassas/silk/format"
+)
+
+func main() {
+	var binSize = flag.Float64("bin-size", count.DefaultBinSizeMS/1000, "bin size in seconds, fractions of a second are allowed")
+	var loadScheme = flag.String("load-scheme", "time-proportional", "how flows are spread across bins: bin-uniform, start-spike, end-spike, middle-spike, time-proportional, maximum-volume or their numbers 0-5")
+	var startTime = flag.String("start-time", "", "first bin to print, YYYY/MM/DD[:HH[:MM[:SS[.sss]]]]")
+	var endTime = flag.String("end-time", "", "last bin to print, YYYY/MM/DD[:HH[:MM[:SS[.sss]]]]")
+	var skipZeroes = flag.Bool("skip-zeroes", false, "do not print empty bins")
+	var noTitles = flag.Bool("no-titles", false, "do not print the column titles")
+	var noColumns = flag.Bool("no-columns", false, "do not pad values to the column width")
+	var noFinalDelimiter = flag.Bool("no-final-delimiter", false, "do not print a delimiter after the last column")
+	var delimited = flag.Bool("delimited", false, "same as --no-columns --no-final-delimiter")
+	var columnSeparator = flag.String("column-separator", "|", "single character printed between columns")
+	var timestampFormat = flag.String("timestamp-format", "default", "timestamp format: default, iso, epoch or epoch-ms")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [OPTIONS] [FILE...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var opts = count.Options{BinSizeMS: uint64(*binSize * 1000)}
+	var err error
+	if *binSize <= 0 || opts.BinSizeMS == 0 {
+		fatal(fmt.Errorf("Bin size:%g must be at least 0.001 seconds", *binSize))
+	}
+	if opts.LoadScheme, err = count.ParseLoadScheme(*loadScheme); err != nil {
+		fatal(err)
+	}
+	if *startTime != "" {
+		if opts.StartTimeMS, err = format.ParseTime(*startTime); err != nil {
+			fatal(fmt.Errorf("Start time:%s %s", *startTime, err))
+		}
+	}
+	if *endTime != "" {
+		if opts.EndTimeMS, err = format.ParseTime(*endTime); err != nil {
+			fatal(fmt.Errorf("End time:%s %s", *endTime, err))
+		}
+	}
+	if len(*columnSeparator) != 1 {
+		fatal(fmt.Errorf("Column separator:%q must be a single character", *columnSeparator))
+	}
+	var tf format.TimestampFormat
+	if tf, err = format.ParseTimestampFormat(*timestampFormat); err != nil {
+		fatal(err)
+	}
+
+	var c *count.Counter
+	if c, err = count.NewCounter(opts); err != nil {
+		fatal(err)
+	}
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	for _, path := range paths {
+		if err = add(c, path); err != nil {
+			fatal(fmt.Errorf("%s: %s", path, err))
+		}
+	}
+
+	var tw = format.NewTextWriter(os.Stdout, format.TextOptions{
+		NoTitles:         *noTitles,
+		NoColumns:        *noColumns || *delimited,
+		Delimiter:        (*columnSeparator)[0],
+		NoFinalDelimiter: *noFinalDelimiter || *delimited,
+		Columns: []format.Column{
+			{Title: "Date", Width: len(tf.AppendTime(nil, 0))},
+			{Title: "Records", Width: 15},
+			{Title: "Bytes", Width: 20},
+			{Title: "Packets", Width: 17},
+		},
+	})
+	var values = make([][]byte, 4)
+	for _, b := range c.Bins(*skipZeroes) {
+		values[0] = tf.AppendTime(values[0][:0], b.StartTimeMS)
+		values[1] = strconv.AppendFloat(values[1][:0], b.Records, 'f', 2, 64)
+		values[2] = strconv.AppendFloat(values[2][:0], b.Bytes, 'f', 2, 64)
+		values[3] = strconv.AppendFloat(values[3][:0], b.Packets, 'f', 2, 64)
+		if err = tw.WriteValues(values...); err != nil {
+			fatal(err)
+		}
+	}
+	if err = tw.Flush(); err != nil {
+		fatal(err)
+	}
+}
+
+//add counts the records of the file at path, - is standard input
+func add(c *count.Counter, path string) (err error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(bufio.NewReader(r)); err != nil {
+		return
+	}
+	for sr.Next() {
+		c.AddRecord(sr.Record())
+	}
+	return sr.Err()
+}
+
+//fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,107 @@
+//Command silkcut prints the fields of silk flow records as text columns in
+//the layout of rwcut. Files are read in order, standard input is read when
+//no files are given.
+//
+//	silkcut [--fields=1-12] [--no-titles] [--delimited] [--timestamp-format=iso] FILE...
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+)
+
+func main() {
+	var fields = flag.String("fields", "1-12", "comma separated field names or numbers to print")
+	var noTitles = flag.Bool("no-titles", false, "do not print the column titles")
+	var noColumns = flag.Bool("no-columns", false, "do not pad values to the column width")
+	var noFinalDelimiter = flag.Bool("no-final-delimiter", false, "do not print a delimiter after the last column")
+	var delimited = flag.Bool("delimited", false, "same as --no-columns --no-final-delimiter")
+	var columnSeparator = flag.String("column-separator", "|", "single character printed between columns")
+	var timestampFormat = flag.String("timestamp-format", "default", "timestamp format: default, iso, epoch or epoch-ms")
+	var ipFormat = flag.String("ip-format", "canonical", "IP address format: canonical, decimal, hexadecimal or zero-padded")
+	var numRecs = flag.Uint64("num-recs", 0, "stop after printing this many records, 0 prints every record")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [OPTIONS] [FILE...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Fields: sIP dIP sPort dPort protocol packets bytes flags sTime duration eTime\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "sensor in out nhIP initialFlags sessionFlags attributes application iType iCode flowtype\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var opts = format.TextOptions{
+		NoTitles:         *noTitles,
+		NoColumns:        *noColumns || *delimited,
+		NoFinalDelimiter: *noFinalDelimiter || *delimited,
+	}
+	var err error
+	if opts.Fields, err = format.ParseFields(*fields); err != nil {
+		fatal(err)
+	}
+	if len(*columnSeparator) != 1 {
+		fatal(fmt.Errorf("Column separator:%q must be a single character", *columnSeparator))
+	}
+	opts.Delimiter = (*columnSeparator)[0]
+	if opts.TimestampFormat, err = format.ParseTimestampFormat(*timestampFormat); err != nil {
+		fatal(err)
+	}
+	if opts.IPFormat, err = format.ParseIPFormat(*ipFormat); err != nil {
+		fatal(err)
+	}
+
+	var tw = format.NewTextWriter(os.Stdout, opts)
+	var remaining = *numRecs
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	for _, path := range paths {
+		if err = cut(tw, path, &remaining, *numRecs > 0); err != nil {
+			tw.Flush()
+			fatal(fmt.Errorf("%s: %s", path, err))
+		}
+		if *numRecs > 0 && remaining == 0 {
+			break
+		}
+	}
+	if err = tw.Flush(); err != nil {
+		fatal(err)
+	}
+}
+
+//cut prints the records of the file at path, - is standard input. When
+//limited it prints at most remaining records and counts them down.
+func cut(tw *format.TextWriter, path string, remaining *uint64, limited bool) (err error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(bufio.NewReader(r)); err != nil {
+		return
+	}
+	for (limited == false || *remaining > 0) && sr.Next() {
+		if err = tw.WriteRecord(sr.Record()); err != nil {
+			return
+		}
+		*remaining--
+	}
+	return sr.Err()
+}
+
+//fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,181 @@
+//Command silkinfo prints information about silk files in the layout of rwfileinfo
+//
+//	silkinfo [-json] FILE...
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/chrispassas/silk"
+)
+
+//packedFileInfo is the JSON form of header entry 1
+type packedFileInfo struct {
+	StartTime string `json:"start_time"`
+	FlowType  uint32 `json:"flowtype"`
+	Sensor    uint32 `json:"sensor"`
+}
+
+//fileInfo is the JSON form of a file, names follow rwfileinfo fields
+type fileInfo struct {
+	Path           string               `json:"path"`
+	Format         string               `json:"format"`
+	FormatID       uint8                `json:"format_id"`
+	Version        uint8                `json:"version"`
+	ByteOrder      string               `json:"byte_order"`
+	Compression    string               `json:"compression"`
+	CompressionID  uint8                `json:"compression_id"`
+	HeaderLength   int                  `json:"header_length"`
+	RecordLength   uint16               `json:"record_length"`
+	RecordVersion  uint16               `json:"record_version"`
+	SilkVersion    string               `json:"silk_version"`
+	CountRecords   uint64               `json:"count_records"`
+	FileSize       int64                `json:"file_size"`
+	PackedFileInfo *packedFileInfo      `json:"packed_file_info,omitempty"`
+	ProbeName      string               `json:"probe_name,omitempty"`
+	PrefixMap      *silk.PrefixMapEntry `json:"prefix_map,omitempty"`
+	Bag            *silk.BagEntry       `json:"bag,omitempty"`
+	IPSet          *silk.IPSetEntry     `json:"ipset,omitempty"`
+	CommandLines   []string             `json:"command_lines"`
+	Annotations    []string             `json:"annotations"`
+}
+
+func main() {
+	var jsonOutput = flag.Bool("json", false, "print file information as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] FILE...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var exitCode int
+	var infos = make([]fileInfo, 0, flag.NArg())
+	for _, path := range flag.Args() {
+		fi, err := silk.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			exitCode = 1
+			continue
+		}
+		if *jsonOutput {
+			infos = append(infos, newFileInfo(fi))
+		} else {
+			printFileInfo(os.Stdout, fi)
+		}
+	}
+
+	if *jsonOutput {
+		var encoder = json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(infos); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+//formatTime formats a millisecond timestamp the way the silk tools do
+func formatTime(ms uint64) string {
+	return time.Unix(int64(ms/1000), 0).UTC().Format("2006/01/02T15:04:05")
+}
+
+//newFileInfo converts fi to its JSON form
+func newFileInfo(fi silk.FileInfo) fileInfo {
+	var h = fi.Header
+	var info = fileInfo{
+		Path:          fi.Path,
+		Format:        fi.FormatName(),
+		FormatID:      h.RecordFormat,
+		Version:       h.FileVersion,
+		ByteOrder:     fi.ByteOrder(),
+		Compression:   fi.CompressionName(),
+		CompressionID: h.Compression,
+		HeaderLength:  h.HeaderLength,
+		RecordLength:  h.RecordSize,
+		RecordVersion: h.RecordVersion,
+		SilkVersion:   fi.SilkVersion(),
+		CountRecords:  fi.RecordCount,
+		FileSize:      fi.FileSize,
+		CommandLines:  h.Invocations(),
+		Annotations:   h.Annotations(),
+	}
+	if packedFile, ok := h.PackedFile(); ok {
+		info.PackedFileInfo = &packedFileInfo{
+			StartTime: formatTime(packedFile.StartTimeMS),
+			FlowType:  packedFile.FlowType,
+			Sensor:    packedFile.Sensor,
+		}
+	}
+	info.ProbeName, _ = h.ProbeName()
+	if prefixMap, ok := h.PrefixMap(); ok {
+		info.PrefixMap = &prefixMap
+	}
+	if bag, ok := h.Bag(); ok {
+		info.Bag = &bag
+	}
+	if ipSet, ok := h.IPSet(); ok {
+		info.IPSet = &ipSet
+	}
+	if info.CommandLines == nil {
+		info.CommandLines = []string{}
+	}
+	if info.Annotations == nil {
+		info.Annotations = []string{}
+	}
+	return info
+}
+
+//printFileInfo prints fi in the layout of rwfileinfo
+func printFileInfo(w io.Writer, fi silk.FileInfo) {
+	var h = fi.Header
+	fmt.Fprintf(w, "%s:\n", fi.Path)
+	fmt.Fprintf(w, "  %-20s%s(0x%02x)\n", "format(id)", fi.FormatName(), h.RecordFormat)
+	fmt.Fprintf(w, "  %-20s%d\n", "version", h.FileVersion)
+	fmt.Fprintf(w, "  %-20s%s\n", "byte-order", fi.ByteOrder())
+	fmt.Fprintf(w, "  %-20s%s(%d)\n", "compression(id)", fi.CompressionName(), h.Compression)
+	fmt.Fprintf(w, "  %-20s%d\n", "header-length", h.HeaderLength)
+	fmt.Fprintf(w, "  %-20s%d\n", "record-length", h.RecordSize)
+	fmt.Fprintf(w, "  %-20s%d\n", "record-version", h.RecordVersion)
+	fmt.Fprintf(w, "  %-20s%s\n", "silk-version", fi.SilkVersion())
+	fmt.Fprintf(w, "  %-20s%d\n", "count-records", fi.RecordCount)
+	fmt.Fprintf(w, "  %-20s%d\n", "file-size", fi.FileSize)
+	if packedFile, ok := h.PackedFile(); ok {
+		fmt.Fprintf(w, "  %-20s%s %d %d\n", "packed-file-info", formatTime(packedFile.StartTimeMS), packedFile.FlowType, packedFile.Sensor)
+	}
+	if probeName, ok := h.ProbeName(); ok {
+		fmt.Fprintf(w, "  %-20s%s\n", "probe-name", probeName)
+	}
+	if prefixMap, ok := h.PrefixMap(); ok {
+		fmt.Fprintf(w, "  %-20sv%d: %s\n", "prefix-map", prefixMap.Version, prefixMap.MapName)
+	}
+	if bag, ok := h.Bag(); ok {
+		fmt.Fprintf(w, "  %-20skey: %d @ %d octets; counter: %d @ %d octets\n", "bag",
+			bag.KeyType, bag.KeyLength, bag.CounterType, bag.CounterLength)
+	}
+	if ipSet, ok := h.IPSet(); ok {
+		fmt.Fprintf(w, "  %-20snodes: %d x %d; leaves: %d x %d; root: %d\n", "ipset",
+			ipSet.NodeCount, ipSet.NodeSize, ipSet.LeafCount, ipSet.LeafSize, ipSet.RootIndex)
+	}
+	if invocations := h.Invocations(); len(invocations) > 0 {
+		fmt.Fprintf(w, "  %s\n", "command-lines")
+		for x, invocation := range invocations {
+			fmt.Fprintf(w, "%20d  %s\n", x+1, invocation)
+		}
+	}
+	if annotations := h.Annotations(); len(annotations) > 0 {
+		fmt.Fprintf(w, "  %s\n", "annotations")
+		for x, annotation := range annotations {
+			fmt.Fprintf(w, "%20d  %s\n", x+1, annotation)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+//Command silksort sorts silk flow records by a list of fields like rwsort
+//and writes them as an FT_RWIPV6ROUTING file. Records beyond the buffer size
+//are sorted in runs written to the temporary directory and merged. Files are
+//read in order, standard input is read when no files are given.
+//
+//	silksort --fields=sTime,sIP [--reverse] [--buffer-size=256M] --output-path=FILE [FILE...]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/internal/cli"
+	"github.com/chrispassas/silk/sort"
+)
+
+func main() {
+	var fields = flag.String("fields", "", "comma separated field names or numbers to sort by, required")
+	var reverse = flag.Bool("reverse", false, "sort from largest to smallest")
+	var bufferSize = flag.String("buffer-size", "256M", "memory used to sort records before writing temporary files, K, M and G suffixes are allowed")
+	var tempDirectory = flag.String("temp-directory", "", "directory of temporary files, the system temporary directory when empty")
+	var compression = flag.String("compression", "none", "output block compression: none, zlib, lzo1x or snappy")
+	var outputPath = flag.String("output-path", "", "silk file to write, - for standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s --fields=FIELDS [OPTIONS] --output-path=FILE [FILE...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *fields == "" || *outputPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts = sort.Options{Reverse: *reverse, TempDir: *tempDirectory}
+	var err error
+	if opts.Fields, err = format.ParseFields(*fields); err != nil {
+		fatal(err)
+	}
+	if opts.MemoryLimit, err = cli.ParseSize(*bufferSize); err != nil {
+		fatal(err)
+	}
+	var h = silk.Header{
+		RecordFormat:  silk.FormatRWIPV6Routing,
+		RecordVersion: 1,
+		VarLenHeaders: []silk.VarLenHeader{silk.NewStringHeader(2, strings.Join(os.Args, " "))},
+	}
+	if h.Compression, err = silk.ParseCompression(*compression); err != nil {
+		fatal(err)
+	}
+
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	if err = sortFiles(opts, h, paths, *outputPath); err != nil {
+		fatal(err)
+	}
+}
+
+//sortFiles sorts the records of the files in paths into a silk file with
+//header h at outputPath, - is standard output. Temporary runs are removed.
+func sortFiles(opts sort.Options, h silk.Header, paths []string, outputPath string) (err error) {
+	var s *sort.Sorter
+	if s, err = sort.NewSorter(opts); err != nil {
+		return
+	}
+	defer s.Close()
+
+	for _, path := range paths {
+		if err = add(s, path); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	if err = s.Sort(); err != nil {
+		return
+	}
+
+	var out io.Writer = os.Stdout
+	if outputPath != "-" {
+		var f *os.File
+		if f, err = os.Create(outputPath); err != nil {
+			return
+		}
+		defer f.Close()
+		out = f
+	}
+	var bw = bufio.NewWriter(out)
+	var sw *silk.Writer
+	if sw, err = silk.NewWriter(bw, h); err != nil {
+		return
+	}
+	for s.Next() {
+		if err = sw.WriteRecord(s.Record()); err != nil {
+			return
+		}
+	}
+	if err = s.Err(); err != nil {
+		return
+	}
+	if err = sw.Close(); err != nil {
+		return
+	}
+	return bw.Flush()
+}
+
+//add adds the records of the file at path, - is standard input
+func add(s *sort.Sorter, path string) (err error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(bufio.NewReader(r)); err != nil {
+		return
+	}
+	for sr.Next() {
+		if err = s.AddRecord(sr.Record()); err != nil {
+			return
+		}
+	}
+	return sr.Err()
+}
+
+//fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
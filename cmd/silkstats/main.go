@@ -0,0 +1,264 @@
+//Command silkstats prints the top or bottom groups of silk flow records by
+//records, bytes or packets like rwstats, with each group's percentage of the
+//total and the cumulative percentage. With --summary it prints the
+//distribution of records, bytes and packets over protocols or ports
+//instead. Files are streamed in order, standard input is read when no files
+//are given.
+//
+//	silkstats --fields=sIP --values=bytes --count=20 [--bottom] [FILE...]
+//	silkstats --summary=dPort --protocols=6,17 [FILE...]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/internal/cli"
+	"github.com/chrispassas/silk/stats"
+)
+
+//valueTitles are the column titles indexed by stats.Value
+var valueTitles = []string{"Records", "Bytes", "Packets"}
+
+//recordAdder is a stats.Top or stats.Summary adapted to return an error
+type recordAdder func(r *silk.Record) error
+
+func main() {
+	var fields = flag.String("fields", "", "comma separated field names or numbers to group by")
+	var values = flag.String("values", "records", "value groups are ranked by: records, bytes or packets")
+	var count = flag.Int("count", 0, "print this many groups")
+	var threshold = flag.Uint64("threshold", 0, "print groups whose value is at least this, at most with --bottom")
+	var percentage = flag.Float64("percentage", 0, "print groups with at least this percent of the total value, at most with --bottom")
+	var top = flag.Bool("top", false, "print the groups with the largest values, the default")
+	var bottom = flag.Bool("bottom", false, "print the groups with the smallest values")
+	var summary = flag.String("summary", "", "print the distribution over protocol, sPort or dPort instead of groups")
+	var protocols = flag.String("protocols", "", "comma separated protocols the summary is limited to")
+	var bufferSize = flag.String("buffer-size", "256M", "memory used for groups before writing temporary files, K, M and G suffixes are allowed")
+	var tempDirectory = flag.String("temp-directory", "", "directory of temporary files, the system temporary directory when empty")
+	var noTitles = flag.Bool("no-titles", false, "do not print the report and column titles")
+	var noColumns = flag.Bool("no-columns", false, "do not pad values to the column width")
+	var noFinalDelimiter = flag.Bool("no-final-delimiter", false, "do not print a delimiter after the last column")
+	var delimited = flag.Bool("delimited", false, "same as --no-columns --no-final-delimiter")
+	var columnSeparator = flag.String("column-separator", "|", "single character printed between columns")
+	var timestampFormat = flag.String("timestamp-format", "default", "timestamp format: default, iso, epoch or epoch-ms")
+	var ipFormat = flag.String("ip-format", "canonical", "IP address format: canonical, decimal, hexadecimal or zero-padded")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s --fields=FIELDS (--count=N | --threshold=N | --percentage=P) [OPTIONS] [FILE...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s --summary=protocol|sPort|dPort [--protocols=6,17] [OPTIONS] [FILE...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if (*fields == "") == (*summary == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *top && *bottom {
+		fatal(fmt.Errorf("Only one of --top and --bottom may be given"))
+	}
+
+	if len(*columnSeparator) != 1 {
+		fatal(fmt.Errorf("Column separator:%q must be a single character", *columnSeparator))
+	}
+	var to = format.TextOptions{
+		NoTitles:         *noTitles,
+		NoColumns:        *noColumns || *delimited,
+		Delimiter:        (*columnSeparator)[0],
+		NoFinalDelimiter: *noFinalDelimiter || *delimited,
+	}
+	var err error
+	if to.TimestampFormat, err = format.ParseTimestampFormat(*timestampFormat); err != nil {
+		fatal(err)
+	}
+	if to.IPFormat, err = format.ParseIPFormat(*ipFormat); err != nil {
+		fatal(err)
+	}
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	if *summary != "" {
+		var opts stats.SummaryOptions
+		var summaryFields []format.Field
+		if summaryFields, err = format.ParseFields(*summary); err != nil {
+			fatal(err)
+		}
+		if len(summaryFields) != 1 {
+			fatal(fmt.Errorf("Summary:%s must name one field", *summary))
+		}
+		opts.Field = summaryFields[0]
+		if *protocols != "" {
+			for _, p := range strings.Split(*protocols, ",") {
+				var v uint64
+				if v, err = strconv.ParseUint(strings.TrimSpace(p), 10, 8); err != nil {
+					fatal(fmt.Errorf("Invalid protocol:%s", p))
+				}
+				opts.Protocols = append(opts.Protocols, uint8(v))
+			}
+		}
+		if err = summarizeFiles(opts, to, paths); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	var opts = stats.Options{
+		Bottom:     *bottom,
+		Count:      *count,
+		Threshold:  *threshold,
+		Percentage: *percentage,
+		TempDir:    *tempDirectory,
+	}
+	if *count <= 0 && *threshold == 0 && *percentage <= 0 {
+		fatal(fmt.Errorf("One of --count, --threshold or --percentage is required"))
+	}
+	if opts.Fields, err = format.ParseFields(*fields); err != nil {
+		fatal(err)
+	}
+	if opts.Value, err = stats.ParseValue(strings.ToLower(*values)); err != nil {
+		fatal(err)
+	}
+	if opts.MemoryLimit, err = cli.ParseSize(*bufferSize); err != nil {
+		fatal(err)
+	}
+	if err = topFiles(opts, to, paths); err != nil {
+		fatal(err)
+	}
+}
+
+//topFiles ranks the groups of the records of the files in paths and prints
+//the report. Temporary runs are removed.
+func topFiles(opts stats.Options, to format.TextOptions, paths []string) (err error) {
+	var t *stats.Top
+	if t, err = stats.NewTop(opts); err != nil {
+		return
+	}
+	for _, path := range paths {
+		if err = add(t.AddRecord, path); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	var rows []stats.Row
+	if rows, err = t.Rows(); err != nil {
+		return
+	}
+
+	var title = valueTitles[opts.Value]
+	if to.NoTitles == false {
+		var direction = "Top"
+		if opts.Bottom {
+			direction = "Bottom"
+		}
+		var limit string
+		if opts.Threshold > 0 {
+			limit = fmt.Sprintf(" (threshold %d)", opts.Threshold)
+		} else if opts.Percentage > 0 {
+			limit = fmt.Sprintf(" (%g%% of whole)", opts.Percentage)
+		}
+		fmt.Printf("INPUT: %d Records for %d Bins and %d Total %s\n", t.Records(), t.Groups(), t.Total(), title)
+		fmt.Printf("OUTPUT: %s %d Bins by %s%s\n", direction, len(rows), title, limit)
+	}
+
+	to.Fields = opts.Fields
+	to.Columns = []format.Column{{Title: title, Width: 20}, {Title: "%" + title, Width: 10}, {Title: "cumul_%", Width: 10}}
+	var tw = format.NewTextWriter(os.Stdout, to)
+	var values = make([][]byte, 3)
+	for x := range rows {
+		values[0] = strconv.AppendUint(values[0][:0], rows[x].Value, 10)
+		values[1] = strconv.AppendFloat(values[1][:0], rows[x].Percent, 'f', 6, 64)
+		values[2] = strconv.AppendFloat(values[2][:0], rows[x].CumulativePercent, 'f', 6, 64)
+		if err = tw.WriteRecordValues(&rows[x].Key, values...); err != nil {
+			return
+		}
+	}
+	return tw.Flush()
+}
+
+//summarizeFiles prints the distribution of the records of the files in paths
+func summarizeFiles(opts stats.SummaryOptions, to format.TextOptions, paths []string) (err error) {
+	var s *stats.Summary
+	if s, err = stats.NewSummary(opts); err != nil {
+		return
+	}
+	for _, path := range paths {
+		var adder = func(r *silk.Record) error {
+			s.AddRecord(r)
+			return nil
+		}
+		if err = add(adder, path); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	to.Fields = []format.Field{opts.Field}
+	to.Columns = []format.Column{
+		{Title: "Records", Width: 10},
+		{Title: "%Records", Width: 10},
+		{Title: "cumul_%", Width: 10},
+		{Title: "Bytes", Width: 20},
+		{Title: "%Bytes", Width: 10},
+		{Title: "Packets", Width: 15},
+		{Title: "%Packets", Width: 10},
+	}
+	var tw = format.NewTextWriter(os.Stdout, to)
+	var values = make([][]byte, len(to.Columns))
+	var key silk.Record
+	for _, row := range s.Rows() {
+		switch opts.Field {
+		case format.FieldSPort:
+			key.SrcPort = row.Value
+		case format.FieldDPort:
+			key.DstPort = row.Value
+		default:
+			key.Proto = uint8(row.Value)
+		}
+		values[0] = strconv.AppendUint(values[0][:0], row.Records, 10)
+		values[1] = strconv.AppendFloat(values[1][:0], row.RecordsPercent, 'f', 6, 64)
+		values[2] = strconv.AppendFloat(values[2][:0], row.CumulativePercent, 'f', 6, 64)
+		values[3] = strconv.AppendUint(values[3][:0], row.Bytes, 10)
+		values[4] = strconv.AppendFloat(values[4][:0], row.BytesPercent, 'f', 6, 64)
+		values[5] = strconv.AppendUint(values[5][:0], row.Packets, 10)
+		values[6] = strconv.AppendFloat(values[6][:0], row.PacketsPercent, 'f', 6, 64)
+		if err = tw.WriteRecordValues(&key, values...); err != nil {
+			return
+		}
+	}
+	return tw.Flush()
+}
+
+//add streams the records of the file at path to adder, - is standard input
+func add(adder recordAdder, path string) (err error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(bufio.NewReader(r)); err != nil {
+		return
+	}
+	for sr.Next() {
+		if err = adder(sr.Record()); err != nil {
+			return
+		}
+	}
+	return sr.Err()
+}
+
+//fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,133 @@
+//Command silktotal prints the records, bytes and packets of silk flow files
+//summed by a bucketed key like rwtotal. Exactly one key switch is given.
+//Files are read in order, standard input is read when no files are given.
+//
+//	silktotal --sip-first-16 [--skip-zeroes] [--min-records=N] [--max-records=N] FILE...
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/total"
+)
+
+func main() {
+	var keys = map[string]*bool{}
+	for _, name := range total.KeyNames() {
+		keys[name] = flag.Bool(name, false, "total by "+strings.ReplaceAll(name, "-", " "))
+	}
+	var skipZeroes = flag.Bool("skip-zeroes", false, "do not print keys without records")
+	var minRecords = flag.Uint64("min-records", 0, "only print keys with at least this many records")
+	var maxRecords = flag.Uint64("max-records", 0, "only print keys with at most this many records")
+	var noTitles = flag.Bool("no-titles", false, "do not print the column titles")
+	var noColumns = flag.Bool("no-columns", false, "do not pad values to the column width")
+	var noFinalDelimiter = flag.Bool("no-final-delimiter", false, "do not print a delimiter after the last column")
+	var delimited = flag.Bool("delimited", false, "same as --no-columns --no-final-delimiter")
+	var columnSeparator = flag.String("column-separator", "|", "single character printed between columns")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s --KEY [OPTIONS] [FILE...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var opts = total.Options{MinRecords: *minRecords, MaxRecords: *maxRecords}
+	var selected int
+	for name, set := range keys {
+		if *set {
+			opts.Key, _ = total.ParseKey(name)
+			selected++
+		}
+	}
+	if selected != 1 {
+		fatal(fmt.Errorf("Exactly one key switch is required: --%s", strings.Join(total.KeyNames(), ", --")))
+	}
+	if len(*columnSeparator) != 1 {
+		fatal(fmt.Errorf("Column separator:%q must be a single character", *columnSeparator))
+	}
+
+	var t, err = total.NewTotaler(opts)
+	if err != nil {
+		fatal(err)
+	}
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	for _, path := range paths {
+		if err = add(t, path); err != nil {
+			fatal(fmt.Errorf("%s: %s", path, err))
+		}
+	}
+
+	var tw = format.NewTextWriter(os.Stdout, format.TextOptions{
+		NoTitles:         *noTitles,
+		NoColumns:        *noColumns || *delimited,
+		Delimiter:        (*columnSeparator)[0],
+		NoFinalDelimiter: *noFinalDelimiter || *delimited,
+		Columns: []format.Column{
+			{Title: opts.Key.Title(), Width: keyWidth(opts.Key)},
+			{Title: "Records", Width: 15},
+			{Title: "Bytes", Width: 20},
+			{Title: "Packets", Width: 17},
+		},
+	})
+	var values = make([][]byte, 4)
+	for _, b := range t.Bins(*skipZeroes) {
+		values[0] = opts.Key.AppendValue(values[0][:0], b.Value)
+		values[1] = strconv.AppendUint(values[1][:0], b.Records, 10)
+		values[2] = strconv.AppendUint(values[2][:0], b.Bytes, 10)
+		values[3] = strconv.AppendUint(values[3][:0], b.Packets, 10)
+		if err = tw.WriteValues(values...); err != nil {
+			fatal(err)
+		}
+	}
+	if err = tw.Flush(); err != nil {
+		fatal(err)
+	}
+}
+
+//keyWidth returns the width of the key column, wide enough for the title
+//and the largest value
+func keyWidth(k total.Key) int {
+	var width = len(k.AppendValue(nil, k.MaxValue()))
+	if len(k.Title()) > width {
+		width = len(k.Title())
+	}
+	return width
+}
+
+//add totals the records of the file at path, - is standard input
+func add(t *total.Totaler, path string) (err error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(bufio.NewReader(r)); err != nil {
+		return
+	}
+	for sr.Next() {
+		t.AddRecord(sr.Record())
+	}
+	return sr.Err()
+}
+
+//fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
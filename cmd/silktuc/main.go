@@ -0,0 +1,164 @@
+//Command silktuc writes silk flow files from text, the inverse of silkcut
+//like rwtuc. Input is rwcut style delimited text, the CSV of the CSV
+//receiver or the JSON Lines of the JSON receiver. Column titles and JSON
+//keys name the fields, --fields names them for text without a title line.
+//Files are read in order, standard input is read when no files are given.
+//
+//	silktuc [--input-format=auto] [--output-format=ipv6routing] [--compression=none] --output-path=FILE [FILE...]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+)
+
+//recordReader is the format.CSVReader and format.JSONReader
+type recordReader interface {
+	Next() bool
+	Record() *silk.Record
+	Err() error
+}
+
+//outputFormats are the --output-format names
+var outputFormats = map[string]uint8{
+	"ipv6routing": silk.FormatRWIPV6Routing,
+	"ipv6":        silk.FormatRWIPV6,
+}
+
+func main() {
+	var inputFormat = flag.String("input-format", "auto", "input format: auto, text or json, auto reads JSON when the input starts with {")
+	var fields = flag.String("fields", "", "comma separated field names or numbers of text without a title line")
+	var columnSeparator = flag.String("column-separator", "", "single character between text columns, detected from the first line when empty")
+	var ipFormat = flag.String("ip-format", "canonical", "IP address format: canonical, decimal or hexadecimal")
+	var outputFormat = flag.String("output-format", "ipv6routing", "record format: ipv6routing or ipv6")
+	var compression = flag.String("compression", "none", "block compression: none, zlib, lzo1x or snappy")
+	var bigEndian = flag.Bool("big-endian", false, "write records in big endian byte order")
+	var outputPath = flag.String("output-path", "", "silk file to write, - for standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [OPTIONS] --output-path=FILE [FILE...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *outputPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var csvOpts format.CSVReaderOptions
+	var err error
+	if *fields != "" {
+		if csvOpts.Fields, err = format.ParseFields(*fields); err != nil {
+			fatal(err)
+		}
+	}
+	if *columnSeparator != "" {
+		if len(*columnSeparator) != 1 {
+			fatal(fmt.Errorf("Column separator:%q must be a single character", *columnSeparator))
+		}
+		csvOpts.Delimiter = (*columnSeparator)[0]
+	}
+	if csvOpts.IPFormat, err = format.ParseIPFormat(*ipFormat); err != nil {
+		fatal(err)
+	}
+	switch *inputFormat {
+	case "auto", "text", "json":
+	default:
+		fatal(fmt.Errorf("Unknown input format:%s", *inputFormat))
+	}
+
+	var h = silk.Header{VarLenHeaders: []silk.VarLenHeader{silk.NewStringHeader(2, strings.Join(os.Args, " "))}}
+	var ok bool
+	if h.RecordFormat, ok = outputFormats[*outputFormat]; ok == false {
+		fatal(fmt.Errorf("Unknown output format:%s", *outputFormat))
+	}
+	h.RecordVersion = 1
+	if h.Compression, err = silk.ParseCompression(*compression); err != nil {
+		fatal(err)
+	}
+	if *bigEndian {
+		h.FileFlags = 1
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "-" {
+		var f *os.File
+		if f, err = os.Create(*outputPath); err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	var bw = bufio.NewWriter(out)
+	var sw *silk.Writer
+	if sw, err = silk.NewWriter(bw, h); err != nil {
+		fatal(err)
+	}
+
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	for _, path := range paths {
+		if err = tuc(sw, path, *inputFormat, csvOpts); err != nil {
+			fatal(fmt.Errorf("%s: %s", path, err))
+		}
+	}
+	if err = sw.Close(); err != nil {
+		fatal(err)
+	}
+	if err = bw.Flush(); err != nil {
+		fatal(err)
+	}
+}
+
+//tuc writes the records of the text file at path, - is standard input
+func tuc(sw *silk.Writer, path string, inputFormat string, csvOpts format.CSVReaderOptions) (err error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var br = bufio.NewReader(r)
+	if inputFormat == "auto" {
+		inputFormat = "text"
+		var start []byte
+		if start, err = br.Peek(64); err != nil && err != io.EOF {
+			return
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(start), []byte("{")) {
+			inputFormat = "json"
+		}
+	}
+
+	var rr recordReader
+	if inputFormat == "json" {
+		rr = format.NewJSONReader(br, csvOpts.IPFormat)
+	} else {
+		rr = format.NewCSVReader(br, csvOpts)
+	}
+	for rr.Next() {
+		if err = sw.WriteRecord(rr.Record()); err != nil {
+			return
+		}
+	}
+	return rr.Err()
+}
+
+//fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
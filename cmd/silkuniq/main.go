@@ -0,0 +1,222 @@
+//Command silkuniq groups the records of silk flow files by a list of key
+//fields and prints the totals of each group like rwuniq. Groups beyond the
+//buffer size are written to the temporary directory and merged, groups are
+//printed in key order. Files are read in order, standard input is read when
+//no files are given.
+//
+//	silkuniq --fields=sIP,dPort [--values=records,bytes,distinct:dIP] [--bytes=1000-] [FILE...]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/internal/cli"
+	"github.com/chrispassas/silk/uniq"
+)
+
+//value is a column printed after the key fields
+type value struct {
+	kind     string
+	distinct int
+}
+
+func main() {
+	var fields = flag.String("fields", "", "comma separated field names or numbers to group by, required")
+	var values = flag.String("values", "records", "comma separated totals to print: records, bytes, packets, sTime-Earliest, eTime-Latest or distinct:FIELD")
+	var flows = flag.String("flows", "", "only print groups with a number of records in MIN-MAX or MIN-")
+	var bytes = flag.String("bytes", "", "only print groups with a number of bytes in MIN-MAX or MIN-")
+	var packets = flag.String("packets", "", "only print groups with a number of packets in MIN-MAX or MIN-")
+	var bufferSize = flag.String("buffer-size", "256M", "memory used for groups before writing temporary files, K, M and G suffixes are allowed")
+	var tempDirectory = flag.String("temp-directory", "", "directory of temporary files, the system temporary directory when empty")
+	var noTitles = flag.Bool("no-titles", false, "do not print the column titles")
+	var noColumns = flag.Bool("no-columns", false, "do not pad values to the column width")
+	var noFinalDelimiter = flag.Bool("no-final-delimiter", false, "do not print a delimiter after the last column")
+	var delimited = flag.Bool("delimited", false, "same as --no-columns --no-final-delimiter")
+	var columnSeparator = flag.String("column-separator", "|", "single character printed between columns")
+	var timestampFormat = flag.String("timestamp-format", "default", "timestamp format: default, iso, epoch or epoch-ms")
+	var ipFormat = flag.String("ip-format", "canonical", "IP address format: canonical, decimal, hexadecimal or zero-padded")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s --fields=FIELDS [OPTIONS] [FILE...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *fields == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts = uniq.Options{TempDir: *tempDirectory}
+	var err error
+	if opts.Fields, err = format.ParseFields(*fields); err != nil {
+		fatal(err)
+	}
+	if opts.MemoryLimit, err = cli.ParseSize(*bufferSize); err != nil {
+		fatal(err)
+	}
+	for _, r := range []struct {
+		value string
+		dst   *uniq.Range
+	}{{*flows, &opts.Records}, {*bytes, &opts.Bytes}, {*packets, &opts.Packets}} {
+		if r.value != "" {
+			if *r.dst, err = uniq.ParseRange(r.value); err != nil {
+				fatal(err)
+			}
+		}
+	}
+	if len(*columnSeparator) != 1 {
+		fatal(fmt.Errorf("Column separator:%q must be a single character", *columnSeparator))
+	}
+	var to = format.TextOptions{
+		Fields:           opts.Fields,
+		NoTitles:         *noTitles,
+		NoColumns:        *noColumns || *delimited,
+		Delimiter:        (*columnSeparator)[0],
+		NoFinalDelimiter: *noFinalDelimiter || *delimited,
+	}
+	if to.TimestampFormat, err = format.ParseTimestampFormat(*timestampFormat); err != nil {
+		fatal(err)
+	}
+	if to.IPFormat, err = format.ParseIPFormat(*ipFormat); err != nil {
+		fatal(err)
+	}
+	var columns []value
+	if columns, err = parseValues(*values, &opts, &to); err != nil {
+		fatal(err)
+	}
+
+	var paths = flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	if err = uniqFiles(opts, to, columns, paths); err != nil {
+		fatal(err)
+	}
+}
+
+//parseValues parses the --values list, adding distinct fields to opts and
+//columns to to
+func parseValues(s string, opts *uniq.Options, to *format.TextOptions) (columns []value, err error) {
+	var timeWidth = len(to.TimestampFormat.AppendTime(nil, 0))
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		var lower = strings.ToLower(name)
+		switch {
+		case lower == "records" || lower == "flows":
+			columns = append(columns, value{kind: "records"})
+			to.Columns = append(to.Columns, format.Column{Title: "Records", Width: 10})
+		case lower == "bytes":
+			columns = append(columns, value{kind: "bytes"})
+			to.Columns = append(to.Columns, format.Column{Title: "Bytes", Width: 20})
+		case lower == "packets":
+			columns = append(columns, value{kind: "packets"})
+			to.Columns = append(to.Columns, format.Column{Title: "Packets", Width: 15})
+		case lower == "stime-earliest":
+			columns = append(columns, value{kind: "sTime"})
+			to.Columns = append(to.Columns, format.Column{Title: "sTime-Earliest", Width: timeWidth})
+		case lower == "etime-latest":
+			columns = append(columns, value{kind: "eTime"})
+			to.Columns = append(to.Columns, format.Column{Title: "eTime-Latest", Width: timeWidth})
+		case strings.HasPrefix(lower, "distinct:"):
+			var distinct []format.Field
+			if distinct, err = format.ParseFields(name[len("distinct:"):]); err != nil {
+				return
+			}
+			if len(distinct) != 1 {
+				return nil, fmt.Errorf("Value:%s must name one field", name)
+			}
+			columns = append(columns, value{kind: "distinct", distinct: len(opts.Distinct)})
+			var title = distinct[0].Title() + "-Distinct"
+			to.Columns = append(to.Columns, format.Column{Title: title, Width: len(title)})
+			opts.Distinct = append(opts.Distinct, distinct[0])
+		default:
+			return nil, fmt.Errorf("Unknown value:%s", name)
+		}
+	}
+	return
+}
+
+//uniqFiles groups the records of the files in paths and prints the groups.
+//Temporary runs are removed.
+func uniqFiles(opts uniq.Options, to format.TextOptions, columns []value, paths []string) (err error) {
+	var a *uniq.Aggregator
+	if a, err = uniq.NewAggregator(opts); err != nil {
+		return
+	}
+	defer a.Release()
+
+	for _, path := range paths {
+		if err = add(a, path); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	a.Close()
+
+	var tw = format.NewTextWriter(os.Stdout, to)
+	var values = make([][]byte, len(columns))
+	for a.Next() {
+		var g = a.Group()
+		for x, c := range columns {
+			var v = values[x][:0]
+			switch c.kind {
+			case "records":
+				v = strconv.AppendUint(v, g.Records, 10)
+			case "bytes":
+				v = strconv.AppendUint(v, g.Bytes, 10)
+			case "packets":
+				v = strconv.AppendUint(v, g.Packets, 10)
+			case "sTime":
+				v = to.TimestampFormat.AppendTime(v, g.StartTimeMS)
+			case "eTime":
+				v = to.TimestampFormat.AppendTime(v, g.EndTimeMS)
+			case "distinct":
+				v = strconv.AppendUint(v, g.Distinct[c.distinct], 10)
+			}
+			values[x] = v
+		}
+		if err = tw.WriteRecordValues(&g.Key, values...); err != nil {
+			return
+		}
+	}
+	if err = a.Err(); err != nil {
+		return
+	}
+	return tw.Flush()
+}
+
+//add adds the records of the file at path, - is standard input
+func add(a *uniq.Aggregator, path string) (err error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(bufio.NewReader(r)); err != nil {
+		return
+	}
+	for sr.Next() {
+		if err = a.AddRecord(sr.Record()); err != nil {
+			return
+		}
+	}
+	return sr.Err()
+}
+
+//fatal prints err and exits
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(1)
+}
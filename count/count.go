@@ -0,0 +1,236 @@
+//Package count bins flow records into fixed time intervals and totals the
+//records, bytes and packets of each bin like rwcount. A flow lasting longer
+//than a bin is spread across the bins it spans by a LoadScheme.
+package count
+
+import (
+	"fmt"
+	stdsort "sort"
+	"strconv"
+
+	"github.com/chrispassas/silk"
+)
+
+//DefaultBinSizeMS is rwcount's default bin size of 30 seconds
+const DefaultBinSizeMS = 30 * 1000
+
+//LoadScheme selects how a flow's volume is divided among the bins it spans.
+//A flow spans the bins from the one holding its start time to the one
+//holding its last millisecond, StartTimeMS+Duration-1.
+type LoadScheme uint8
+
+//Load schemes of rwcount --load-scheme, numbered as rwcount numbers them
+const (
+	//LoadBinUniform divides the volume evenly among the bins
+	LoadBinUniform LoadScheme = iota
+	//LoadStart adds the whole volume to the bin of the start time
+	LoadStart
+	//LoadEnd adds the whole volume to the bin of the end time
+	LoadEnd
+	//LoadMiddle adds the whole volume to the bin of the middle of the flow
+	LoadMiddle
+	//LoadProportional divides the volume by the time the flow spent in each bin
+	LoadProportional
+	//LoadMaximum adds the whole volume to every bin, totals exceed the input
+	LoadMaximum
+)
+
+//loadSchemeNames are the --load-scheme names indexed by LoadScheme
+var loadSchemeNames = []string{"bin-uniform", "start-spike", "end-spike", "middle-spike", "time-proportional", "maximum-volume"}
+
+//ParseLoadScheme returns the load scheme named bin-uniform, start-spike,
+//end-spike, middle-spike, time-proportional or maximum-volume, or given by
+//its rwcount number
+func ParseLoadScheme(name string) (LoadScheme, error) {
+	for x := range loadSchemeNames {
+		if loadSchemeNames[x] == name || strconv.Itoa(x) == name {
+			return LoadScheme(x), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown load scheme:%s", name)
+}
+
+//String returns the load scheme name
+func (ls LoadScheme) String() string {
+	if int(ls) < len(loadSchemeNames) {
+		return loadSchemeNames[ls]
+	}
+	return fmt.Sprintf("LoadScheme(%d)", uint8(ls))
+}
+
+//Bin is the total volume of one interval starting at StartTimeMS. Volumes
+//are fractional when flows are divided among bins.
+type Bin struct {
+	StartTimeMS uint64
+	Records     float64
+	Bytes       float64
+	Packets     float64
+}
+
+//Options configure a Counter. BinSizeMS is the bin width in milliseconds,
+//DefaultBinSizeMS when 0. Bins start at multiples of the bin size from the
+//epoch, or from StartTimeMS when it is set. Volume before StartTimeMS or
+//after the bin holding EndTimeMS is dropped, 0 leaves either end open.
+//The zero LoadScheme is LoadBinUniform, rwcount's scheme 0.
+type Options struct {
+	BinSizeMS   uint64
+	LoadScheme  LoadScheme
+	StartTimeMS uint64
+	EndTimeMS   uint64
+}
+
+//Counter totals records into bins. It is a silk.FlowReceiver so it can be
+//passed to silk.Parse, or records can be added with AddRecord.
+type Counter struct {
+	opts Options
+	bins map[uint64]*Bin
+}
+
+//NewCounter returns a Counter, EndTimeMS must not be before StartTimeMS
+func NewCounter(opts Options) (c *Counter, err error) {
+	if opts.BinSizeMS == 0 {
+		opts.BinSizeMS = DefaultBinSizeMS
+	}
+	if int(opts.LoadScheme) >= len(loadSchemeNames) {
+		err = fmt.Errorf("Unknown load scheme:%d", opts.LoadScheme)
+		return
+	}
+	if opts.EndTimeMS != 0 && opts.EndTimeMS < opts.StartTimeMS {
+		err = fmt.Errorf("End time:%d is before start time:%d", opts.EndTimeMS, opts.StartTimeMS)
+		return
+	}
+	c = &Counter{opts: opts, bins: map[uint64]*Bin{}}
+	return
+}
+
+//HandleHeader does nothing, the Counter needs no header information
+func (c *Counter) HandleHeader(h silk.Header) {}
+
+//HandleFlow adds f
+func (c *Counter) HandleFlow(f silk.Flow) {
+	c.Add(&f)
+}
+
+//Close does nothing, read the totals with Bins
+func (c *Counter) Close() {}
+
+//Add adds the volume of f to the bins it spans
+func (c *Counter) Add(f *silk.Flow) {
+	var r silk.Record
+	r.SetFlow(f)
+	c.AddRecord(&r)
+}
+
+//AddRecord adds the volume of r to the bins it spans
+func (c *Counter) AddRecord(r *silk.Record) {
+	var start = r.StartTimeMS
+	var last = start
+	if r.Duration > 0 {
+		last = start + uint64(r.Duration) - 1
+	}
+	var first, final = c.binIndex(start), c.binIndex(last)
+
+	switch c.opts.LoadScheme {
+	case LoadStart:
+		c.addBin(first, r, 1)
+	case LoadEnd:
+		c.addBin(final, r, 1)
+	case LoadMiddle:
+		c.addBin(c.binIndex(start+uint64(r.Duration)/2), r, 1)
+	case LoadMaximum:
+		for i := first; i <= final; i++ {
+			c.addBin(i, r, 1)
+		}
+	case LoadBinUniform:
+		var share = 1 / float64(final-first+1)
+		for i := first; i <= final; i++ {
+			c.addBin(i, r, share)
+		}
+	case LoadProportional:
+		if r.Duration == 0 {
+			c.addBin(first, r, 1)
+			return
+		}
+		var from, end = int64(start), int64(start) + int64(r.Duration)
+		for i := first; i <= final; i++ {
+			var to = c.binStartMS(i + 1)
+			if to > end {
+				to = end
+			}
+			c.addBin(i, r, float64(to-from)/float64(r.Duration))
+			from = to
+		}
+	}
+}
+
+//binIndex returns the bin holding time ms, bins before StartTimeMS are negative
+func (c *Counter) binIndex(ms uint64) int64 {
+	var offset = int64(ms) - int64(c.opts.StartTimeMS)
+	var size = int64(c.opts.BinSizeMS)
+	var i = offset / size
+	if offset < 0 && offset%size != 0 {
+		i--
+	}
+	return i
+}
+
+//binStartMS returns the start time of bin i
+func (c *Counter) binStartMS(i int64) int64 {
+	return int64(c.opts.StartTimeMS) + i*int64(c.opts.BinSizeMS)
+}
+
+//addBin adds share of the volume of r to bin i, bins before the start time
+//or after the end time are dropped
+func (c *Counter) addBin(i int64, r *silk.Record, share float64) {
+	if i < 0 || (c.opts.EndTimeMS != 0 && i > c.binIndex(c.opts.EndTimeMS)) {
+		return
+	}
+	var start = uint64(c.binStartMS(i))
+	var b, ok = c.bins[start]
+	if ok == false {
+		b = &Bin{StartTimeMS: start}
+		c.bins[start] = b
+	}
+	b.Records += share
+	b.Bytes += share * float64(r.Bytes)
+	b.Packets += share * float64(r.Packets)
+}
+
+//Bins returns the bins in time order. Empty bins are included between the
+//first and last bin with volume, or between the start and end time when
+//they are set, unless skipZeroes is set.
+func (c *Counter) Bins(skipZeroes bool) (bins []Bin) {
+	bins = make([]Bin, 0, len(c.bins))
+	for _, b := range c.bins {
+		bins = append(bins, *b)
+	}
+	stdsort.Slice(bins, func(i, j int) bool { return bins[i].StartTimeMS < bins[j].StartTimeMS })
+	if skipZeroes {
+		return
+	}
+
+	var first, last uint64
+	if len(bins) > 0 {
+		first, last = bins[0].StartTimeMS, bins[len(bins)-1].StartTimeMS
+	}
+	if c.opts.StartTimeMS != 0 {
+		first = c.opts.StartTimeMS
+	}
+	if c.opts.EndTimeMS != 0 {
+		last = uint64(c.binStartMS(c.binIndex(c.opts.EndTimeMS)))
+	}
+	if len(bins) == 0 && (c.opts.StartTimeMS == 0 || c.opts.EndTimeMS == 0) {
+		return
+	}
+	var all = make([]Bin, 0, (last-first)/c.opts.BinSizeMS+1)
+	var x int
+	for start := first; start <= last; start += c.opts.BinSizeMS {
+		if x < len(bins) && bins[x].StartTimeMS == start {
+			all = append(all, bins[x])
+			x++
+		} else {
+			all = append(all, Bin{StartTimeMS: start})
+		}
+	}
+	return all
+}
@@ -0,0 +1,123 @@
+package count
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/internal/silktest"
+)
+
+//TestLoadSchemes verifies how a flow is spread across bins by each load scheme
+func TestLoadSchemes(t *testing.T) {
+	//Starts 5 seconds into the 10 second bin at 100s and lasts 20 seconds,
+	//spanning the bins at 100s, 110s and 120s
+	var r = silk.Record{StartTimeMS: 105000, Duration: 20000, Bytes: 400, Packets: 4}
+	var tests = []struct {
+		scheme   LoadScheme
+		expected []Bin
+	}{
+		{LoadProportional, []Bin{{100000, 0.25, 100, 1}, {110000, 0.5, 200, 2}, {120000, 0.25, 100, 1}}},
+		{LoadBinUniform, []Bin{{100000, 1.0 / 3, 400.0 / 3, 4.0 / 3}, {110000, 1.0 / 3, 400.0 / 3, 4.0 / 3}, {120000, 1.0 / 3, 400.0 / 3, 4.0 / 3}}},
+		{LoadStart, []Bin{{100000, 1, 400, 4}}},
+		{LoadEnd, []Bin{{120000, 1, 400, 4}}},
+		{LoadMiddle, []Bin{{110000, 1, 400, 4}}},
+		{LoadMaximum, []Bin{{100000, 1, 400, 4}, {110000, 1, 400, 4}, {120000, 1, 400, 4}}},
+	}
+	for _, test := range tests {
+		var c, err = NewCounter(Options{BinSizeMS: 10000, LoadScheme: test.scheme})
+		if err != nil {
+			t.Fatalf("NewCounter error:%s", err)
+		}
+		c.AddRecord(&r)
+		var bins = c.Bins(true)
+		if len(bins) != len(test.expected) {
+			t.Errorf("Scheme:%s bins:%+v expected:%+v", test.scheme, bins, test.expected)
+			continue
+		}
+		for x := range bins {
+			if equalBins(bins[x], test.expected[x]) == false {
+				t.Errorf("Scheme:%s bin:%d found:%+v expected:%+v", test.scheme, x, bins[x], test.expected[x])
+			}
+		}
+	}
+}
+
+//equalBins compares bins allowing for rounding
+func equalBins(a, b Bin) bool {
+	var near = func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return a.StartTimeMS == b.StartTimeMS && near(a.Records, b.Records) && near(a.Bytes, b.Bytes) && near(a.Packets, b.Packets)
+}
+
+//TestCounterRange verifies start and end times bound the bins and empty bins are filled
+func TestCounterRange(t *testing.T) {
+	var c, err = NewCounter(Options{BinSizeMS: 10000, LoadScheme: LoadProportional, StartTimeMS: 103000, EndTimeMS: 150000})
+	if err != nil {
+		t.Fatalf("NewCounter error:%s", err)
+	}
+	c.AddRecord(&silk.Record{StartTimeMS: 98000, Duration: 10000, Bytes: 100, Packets: 1})
+	c.AddRecord(&silk.Record{StartTimeMS: 140000, Bytes: 50, Packets: 1})
+	c.AddRecord(&silk.Record{StartTimeMS: 160000, Bytes: 70, Packets: 1})
+	var expected = []Bin{
+		{103000, 0.5, 50, 0.5},
+		{113000, 0, 0, 0},
+		{123000, 0, 0, 0},
+		{133000, 1, 50, 1},
+		{143000, 0, 0, 0},
+	}
+	var bins = c.Bins(false)
+	if len(bins) != len(expected) {
+		t.Fatalf("Bins:%+v expected:%+v", bins, expected)
+	}
+	for x := range bins {
+		if equalBins(bins[x], expected[x]) == false {
+			t.Errorf("Bin:%d found:%+v expected:%+v", x, bins[x], expected[x])
+		}
+	}
+	if bins = c.Bins(true); len(bins) != 2 {
+		t.Errorf("Bins skipping zeroes:%+v expected 2", bins)
+	}
+
+	if _, err = NewCounter(Options{StartTimeMS: 2000, EndTimeMS: 1000}); err == nil {
+		t.Errorf("NewCounter end before start should fail")
+	}
+	if _, err = ParseLoadScheme("bogus"); err == nil {
+		t.Errorf("ParseLoadScheme bogus should fail")
+	}
+	for number, expected := range []LoadScheme{LoadBinUniform, LoadStart, LoadEnd, LoadMiddle, LoadProportional, LoadMaximum} {
+		if scheme, _ := ParseLoadScheme(strconv.Itoa(number)); scheme != expected {
+			t.Errorf("ParseLoadScheme %d:%s expected:%s", number, scheme, expected)
+		}
+	}
+}
+
+//TestCounterFile verifies the proportional totals of a file equal its volume
+func TestCounterFile(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var c, err = NewCounter(Options{BinSizeMS: 60000, LoadScheme: LoadProportional})
+	if err != nil {
+		t.Fatalf("NewCounter error:%s", err)
+	}
+	var records, bytes, packets float64
+	for _, r := range silktest.ReadRecords(t, filePath) {
+		c.AddRecord(&r)
+		records++
+		bytes += float64(r.Bytes)
+		packets += float64(r.Packets)
+	}
+
+	var total Bin
+	var bins = c.Bins(false)
+	for x, b := range bins {
+		if x > 0 && b.StartTimeMS != bins[x-1].StartTimeMS+60000 {
+			t.Errorf("File:%s bin:%d start:%d follows:%d", filePath, x, b.StartTimeMS, bins[x-1].StartTimeMS)
+		}
+		total.Records += b.Records
+		total.Bytes += b.Bytes
+		total.Packets += b.Packets
+	}
+	if math.Abs(total.Records-records) > 1e-3 || math.Abs(total.Bytes-bytes)/bytes > 1e-9 || math.Abs(total.Packets-packets)/packets > 1e-9 {
+		t.Errorf("File:%s totals:%+v expected records:%.0f bytes:%.0f packets:%.0f", filePath, total, records, bytes, packets)
+	}
+}
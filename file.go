@@ -3,7 +3,7 @@ package silk
 import (
 	"bufio"
 	"bytes"
-	"compress/zlib"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -11,9 +11,6 @@ import (
 	"math"
 	"net"
 	"os"
-
-	"github.com/golang/snappy"
-	lzo "github.com/rasky/go-lzo"
 )
 
 // SilkTCPStateExpanded constant value defined in silk code
@@ -30,26 +27,25 @@ var isTCPAnd = uint32(math.Pow(2, 23))
 //More details on the Flow stuct fields can be found here:
 //	https://tools.netsa.cert.org/silk/faq.html#file-formats
 type Flow struct {
-	startTimeMS56 uint32
-	StartTimeMS   uint64
-	Duration      uint32
-	SrcIP         net.IP
-	DstIP         net.IP
-	SrcPort       uint16
-	DstPort       uint16
-	Proto         uint8
-	Flags         uint8
-	Packets       uint32
-	Bytes         uint32
-	ClassType     uint8
-	Sensor        uint16
-	InitalFlags   uint8
-	SessionFlags  uint8
-	Attributes    uint8
-	Application   uint16
-	SNMPIn        uint16
-	SNMPOut       uint16
-	NextHopIP     net.IP
+	StartTimeMS  uint64
+	Duration     uint32
+	SrcIP        net.IP
+	DstIP        net.IP
+	SrcPort      uint16
+	DstPort      uint16
+	Proto        uint8
+	Flags        uint8
+	Packets      uint32
+	Bytes        uint32
+	ClassType    uint8
+	Sensor       uint16
+	InitalFlags  uint8
+	SessionFlags uint8
+	Attributes   uint8
+	Application  uint16
+	SNMPIn       uint16
+	SNMPOut      uint16
+	NextHopIP    net.IP
 }
 
 // ErrUnsupportedCompression unknown compression type. Currently supported
@@ -93,6 +89,13 @@ type offsets struct {
 	endSNMPOut        int
 	startNextHopIP    int
 	endNextHopIP      int
+	//packedStartTime is set for records storing the start time and TCP
+	//state in a 32 bit word relative to the file date
+	packedStartTime bool
+	//ipv4 is set for records storing IP addresses as 4 byte values
+	ipv4 bool
+	//routing is set for records with SNMP interfaces and a next hop IP
+	routing bool
 }
 
 func getOffsets(recordSize uint16) (o offsets, err error) {
@@ -130,7 +133,9 @@ func getOffsets(recordSize uint16) (o offsets, err error) {
 		o.endSNMPOut = 32
 		o.startNextHopIP = 72
 		o.endNextHopIP = 88
+		o.routing = true
 	} else if recordSize == 56 {
+		o.packedStartTime = true
 		o.startStartTime = 0
 		o.endStartTime = 4
 		o.startSrcIP = 24
@@ -229,6 +234,8 @@ func getOffsets(recordSize uint16) (o offsets, err error) {
 		o.endSNMPOut = 32
 		o.startNextHopIP = 48
 		o.endNextHopIP = 52
+		o.ipv4 = true
+		o.routing = true
 	} else {
 		err = fmt.Errorf("Unsupported record size:%d", recordSize)
 	}
@@ -243,7 +250,7 @@ type File struct {
 
 func ParseReader(r io.Reader, receiver FlowReceiver) (sf File, err error) {
 	parsedFlows := NewSliceFlowReceiver(4096)
-	err = parseReader(r, parsedFlows)
+	err = parseReader(context.Background(), r, parsedFlows)
 	if err != nil {
 		return File{}, err
 	}
@@ -251,271 +258,117 @@ func ParseReader(r io.Reader, receiver FlowReceiver) (sf File, err error) {
 	return parsedFlows.File, nil
 }
 
-func parseReader(f io.Reader, receiver FlowReceiver) (err error) {
-	defer receiver.Close()
-
-	var n int
-	var x int
-	var start int
-	var end int
-	var readMod float64
-	var shortReadCount = 0
-	var maxShortReadCount = 5
-	var recordsCount int
-	var silkFlow Flow
-	var decompressedBuffer []byte
-	var compressedBlockHeader = make([]byte, 8)
-	var compressedBlockSize uint32
-	var decompressedBlockSize uint32
-	var compressedBuffer []byte
-	var o offsets
-	var ro io.ReadCloser
-	var isTCP uint32
-	var header Header
-
-	if header, err = parseHeader(f); err != nil {
-		return
+func parseReader(ctx context.Context, f io.Reader, receiver FlowReceiver) (err error) {
+	cr, hasContext := receiver.(contextFlowReceiver)
+	if hasContext {
+		cr.setContext(ctx)
 	}
+	defer func() {
+		if hasContext {
+			cr.setErr(err)
+		}
+		receiver.Close()
+	}()
 
-	receiver.HandleHeader(header)
-
-	if o, err = getOffsets(header.RecordSize); err != nil {
+	var sr *Reader
+	if sr, err = NewReader(f); err != nil {
 		return
 	}
 
-	if header.Compression == 0 {
-		mod := math.Floor(float64(defaultReadSize) / float64(header.RecordSize))
-		readSize := (int(mod) * int(header.RecordSize))
-		decompressedBuffer = make([]byte, readSize)
+	receiver.HandleHeader(sr.Header())
+
+	//Filter in the Reader so rejected records are never converted to a Flow
+	var flowReceiver = receiver
+	if fr, ok := receiver.(FilteringReceiver); ok && fr.Filter() != nil {
+		sr.SetFilter(fr.Filter())
+		if mr, ok := receiver.(matchedFlowReceiver); ok {
+			flowReceiver = mr.matchedReceiver()
+		}
 	}
 
-	var blockCount int
-	for {
-		blockCount++
-		// log.Printf("blockCount:%d", blockCount)
-		switch header.Compression {
-		case 0:
-			if n, err = f.Read(decompressedBuffer); n == 0 && err == io.EOF {
-				err = nil
-				return
-			} else if err != nil {
-				err = fmt.Errorf("Read error:%s", err.Error())
-				return
-			} else if n < int(header.RecordSize) {
-				err = fmt.Errorf("Read:%d smaller then record size:%d", n, header.RecordSize)
-				return
-			} else if n < len(decompressedBuffer) {
-				shortReadCount++
-				if shortReadCount > maxShortReadCount {
-					err = fmt.Errorf("Read failed to read requested size:%d %d times in a row", len(decompressedBuffer), maxShortReadCount)
-					return
-				}
-			} else {
-				shortReadCount = 0
-			}
+	var done = ctx.Done()
+	for sr.Next() {
+		select {
+		case <-done:
+			err = ctx.Err()
+			return
+		default:
+		}
+		flowReceiver.HandleFlow(*sr.Flow())
+	}
+	return sr.Err()
+}
 
-			recordsCount = n / int(header.RecordSize)
+//decodeFlow decodes a single silk record into silkFlow
+func decodeFlow(record []byte, h *Header, o offsets, silkFlow *Record) {
+	var order = decodePartialFlow(record, h, o, silkFlow)
 
-			if readMod = math.Mod(float64(n), float64(header.RecordSize)); readMod != 0 {
-				err = ErrUnsupportedPartialRead
-				return
-			}
-		case 3, 2, 1:
-			if _, err = f.Read(compressedBlockHeader); err == io.EOF {
-				err = nil
-				return
-			} else if err != nil {
-				return
-			}
-			compressedBlockSize = binary.BigEndian.Uint32(compressedBlockHeader[0:4])
-			decompressedBlockSize = binary.BigEndian.Uint32(compressedBlockHeader[4:8])
-			if int(compressedBlockSize) > cap(compressedBuffer) {
-				compressedBuffer = make([]byte, compressedBlockSize)
-			}
+	if o.ipv4 {
+		silkFlow.SrcIP = decodeIPv4(record[o.startSrcIP:o.endSrcIP], order)
+		silkFlow.DstIP = decodeIPv4(record[o.startDstIP:o.endDstIP], order)
+	} else {
+		silkFlow.SrcIP = decodeIPv6(record[o.startSrcIP:o.endSrcIP])
+		silkFlow.DstIP = decodeIPv6(record[o.startDstIP:o.endDstIP])
+	}
 
-			if int(decompressedBlockSize) > cap(decompressedBuffer) {
-				decompressedBuffer = make([]byte, decompressedBlockSize)
-			}
+	silkFlow.Application = order.Uint16(record[o.startApplication:o.endApplication])
+	if o.routing {
+		silkFlow.SNMPIn = order.Uint16(record[o.startSNMPIn:o.endSNMPIn])
+		silkFlow.SNMPOut = order.Uint16(record[o.startSNMPOut:o.endSNMPOut])
+		if o.ipv4 {
+			silkFlow.NextHopIP = decodeIPv4(record[o.startNextHopIP:o.endNextHopIP], order)
+		} else {
+			silkFlow.NextHopIP = decodeIPv6(record[o.startNextHopIP:o.endNextHopIP])
+		}
+	}
 
-			if n, err = f.Read(compressedBuffer[:compressedBlockSize]); n == 0 && err == io.EOF {
-				err = nil
-				return
-			} else if err != nil {
-				return
-			}
-			if header.Compression == 3 {
-				if _, err = snappy.Decode(decompressedBuffer, compressedBuffer[:compressedBlockSize]); err != nil {
-					return
-				}
-			} else if header.Compression == 2 {
-				if decompressedBuffer, err = lzo.Decompress1X(bytes.NewReader(compressedBuffer[:compressedBlockSize]), 0, 0); err != nil {
-					return
-				}
-			} else if header.Compression == 1 {
-				if ro, err = zlib.NewReader(bytes.NewReader(compressedBuffer[:compressedBlockSize])); err != nil {
-					return
-				}
-				if _, err = ro.Read(decompressedBuffer); err != nil {
-					ro.Close()
-					return
-				}
-				ro.Close()
-			}
+	if o.packedStartTime == false {
+		silkFlow.ClassType = record[o.startClassType]
+		silkFlow.Sensor = order.Uint16(record[o.startSensor:o.endSensor])
+		silkFlow.InitalFlags = record[o.startInitalFlags]
+		silkFlow.SessionFlags = record[o.startSessionFlags]
+		silkFlow.Attributes = record[o.startAttributes]
+	} else {
+		silkFlow.Sensor = uint16(h.fileSensor)
+	}
+}
 
-			recordsCount = int(decompressedBlockSize) / int(header.RecordSize)
-		default:
-			err = ErrUnsupportedCompression
-			return
-		}
+//decodePartialFlow clears silkFlow and decodes only the partial fields of
+//a record, see PartialFilter. It returns the byte order of the record.
+func decodePartialFlow(record []byte, h *Header, o offsets, silkFlow *Record) (order binary.ByteOrder) {
+	order = binary.LittleEndian
+	if h.FileFlags != 0 {
+		order = binary.BigEndian
+	}
 
-		start = 0
-		end = int(header.RecordSize)
-		for x = 0; x < recordsCount; x++ {
-			//Clear out struct values
-			silkFlow.startTimeMS56 = 0
-			silkFlow.StartTimeMS = 0
-			silkFlow.Duration = 0
-			silkFlow.SrcIP = nil
-			silkFlow.DstIP = nil
-			silkFlow.SrcPort = 0
-			silkFlow.DstPort = 0
-			silkFlow.Proto = 0
-			silkFlow.Flags = 0
-			silkFlow.Packets = 0
-			silkFlow.Bytes = 0
-			silkFlow.ClassType = 0
-			silkFlow.Sensor = 0
-			silkFlow.InitalFlags = 0
-			silkFlow.SessionFlags = 0
-			silkFlow.Attributes = 0
-			silkFlow.Application = 0
-			silkFlow.SNMPIn = 0
-			silkFlow.SNMPOut = 0
-			silkFlow.NextHopIP = nil
-
-			if header.FileFlags == 0 {
-				//little endian
-				if header.RecordSize == 56 {
-					silkFlow.startTimeMS56 = binary.LittleEndian.Uint32(decompressedBuffer[start:end][o.startStartTime:o.endStartTime])
-					silkFlow.StartTimeMS = uint64((calMsec & silkFlow.startTimeMS56)) + header.fileDateMS
-					isTCP = silkFlow.startTimeMS56 & isTCPAnd
-					if isTCP != 0 {
-						silkFlow.Proto = 6
-						if (decompressedBuffer[start:end][5] & SilkTCPStateExpanded) != 0 {
-							silkFlow.Flags = decompressedBuffer[start:end][3] | decompressedBuffer[start:end][4]
-						} else {
-							silkFlow.Flags = decompressedBuffer[start:end][4]
-						}
-					} else {
-						silkFlow.Flags = 0
-						silkFlow.Proto = decompressedBuffer[start:end][4]
-					}
-				} else {
-					silkFlow.Flags = uint8(decompressedBuffer[start:end][o.startTCPFlags])
-					silkFlow.Proto = uint8(decompressedBuffer[start:end][o.startProto])
-					silkFlow.StartTimeMS = binary.LittleEndian.Uint64(decompressedBuffer[start:end][o.startStartTime:o.endStartTime])
-				}
-				if header.RecordSize == 52 {
-					silkFlow.SrcIP = net.ParseIP(intToIP(binary.BigEndian.Uint32(decompressedBuffer[start:end][o.startSrcIP:o.endSrcIP])))
-					silkFlow.DstIP = net.ParseIP(intToIP(binary.BigEndian.Uint32(decompressedBuffer[start:end][o.startDstIP:o.endDstIP])))
-				} else {
-					silkFlow.SrcIP = net.ParseIP(net.IP(decompressedBuffer[start:end][o.startSrcIP:o.endSrcIP]).String())
-					silkFlow.DstIP = net.ParseIP(net.IP(decompressedBuffer[start:end][o.startDstIP:o.endDstIP]).String())
-				}
-
-				silkFlow.SrcPort = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startSrcPort:o.endSrcPort])
-				silkFlow.DstPort = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startDstPort:o.endDstPort])
-				silkFlow.Packets = binary.LittleEndian.Uint32(decompressedBuffer[start:end][o.startPackets:o.endPackets])
-				silkFlow.Bytes = binary.LittleEndian.Uint32(decompressedBuffer[start:end][o.startBytes:o.endBytes])
-				silkFlow.Duration = binary.LittleEndian.Uint32(decompressedBuffer[start:end][o.startDuration:o.endDuration])
-
-				if header.RecordSize == 88 {
-					silkFlow.SNMPIn = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startSNMPIn:o.endSNMPIn])
-					silkFlow.SNMPOut = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startSNMPOut:o.endSNMPOut])
-					silkFlow.NextHopIP = net.ParseIP(net.IP(decompressedBuffer[start:end][o.startNextHopIP:o.endNextHopIP]).String())
-				} else if header.RecordSize == 52 {
-					silkFlow.SNMPIn = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startSNMPIn:o.endSNMPIn])
-					silkFlow.SNMPOut = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startSNMPOut:o.endSNMPOut])
-					silkFlow.NextHopIP = net.ParseIP(intToIP(binary.BigEndian.Uint32(decompressedBuffer[start:end][o.startNextHopIP:o.endNextHopIP])))
-				} else {
-					silkFlow.Application = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startApplication:o.endApplication])
-				}
-
-				if header.RecordSize == 88 || header.RecordSize == 68 || header.RecordSize == 52 {
-					silkFlow.ClassType = decompressedBuffer[o.startClassType]
-					silkFlow.Sensor = binary.LittleEndian.Uint16(decompressedBuffer[start:end][o.startSensor:o.endSensor])
-					silkFlow.InitalFlags = decompressedBuffer[o.startInitalFlags]
-					silkFlow.SessionFlags = decompressedBuffer[o.startSessionFlags]
-					silkFlow.Attributes = decompressedBuffer[o.startAttributes]
-				} else if header.RecordSize == 56 {
-					silkFlow.Sensor = uint16(header.fileSensor)
-				}
+	//Clear out struct values
+	*silkFlow = Record{}
 
+	if o.packedStartTime {
+		var rflagStime = order.Uint32(record[o.startStartTime:o.endStartTime])
+		silkFlow.StartTimeMS = uint64(calMsec&rflagStime) + h.fileDateMS
+		if rflagStime&isTCPAnd != 0 {
+			silkFlow.Proto = 6
+			if (record[5] & SilkTCPStateExpanded) != 0 {
+				silkFlow.Flags = uint8(rflagStime>>24) | record[4]
 			} else {
-				//big endian)
-				if header.RecordSize == 56 {
-					silkFlow.startTimeMS56 = binary.BigEndian.Uint32(decompressedBuffer[start:end][o.startStartTime:o.endStartTime])
-					silkFlow.StartTimeMS = uint64((calMsec & silkFlow.startTimeMS56)) + header.fileDateMS
-					isTCP = silkFlow.startTimeMS56 & isTCPAnd
-					if isTCP != 0 {
-						silkFlow.Proto = 6
-						if (decompressedBuffer[start:end][5] & SilkTCPStateExpanded) != 0 {
-							silkFlow.Flags = decompressedBuffer[start:end][3] | decompressedBuffer[start:end][4]
-						} else {
-							silkFlow.Flags = decompressedBuffer[start:end][4]
-						}
-					} else {
-						silkFlow.Flags = 0
-						silkFlow.Proto = decompressedBuffer[start:end][4]
-					}
-				} else {
-					silkFlow.Flags = uint8(decompressedBuffer[start:end][o.startTCPFlags])
-					silkFlow.Proto = uint8(decompressedBuffer[start:end][o.startProto])
-					silkFlow.StartTimeMS = binary.BigEndian.Uint64(decompressedBuffer[start:end][o.startStartTime:o.endStartTime])
-				}
-				silkFlow.SrcIP = net.ParseIP(net.IP(decompressedBuffer[start:end][o.startSrcIP:o.endSrcIP]).String())
-				silkFlow.DstIP = net.ParseIP(net.IP(decompressedBuffer[start:end][o.startDstIP:o.endDstIP]).String())
-				silkFlow.SrcPort = binary.BigEndian.Uint16(decompressedBuffer[start:end][o.startSrcPort:o.endSrcPort])
-				silkFlow.DstPort = binary.BigEndian.Uint16(decompressedBuffer[start:end][o.startDstPort:o.endDstPort])
-				silkFlow.Packets = binary.BigEndian.Uint32(decompressedBuffer[start:end][o.startPackets:o.endPackets])
-				silkFlow.Bytes = binary.BigEndian.Uint32(decompressedBuffer[start:end][o.startBytes:o.endBytes])
-				silkFlow.Duration = binary.BigEndian.Uint32(decompressedBuffer[start:end][o.startDuration:o.endDuration])
-
-				if header.RecordSize == 88 || header.RecordSize == 52 {
-					silkFlow.SNMPIn = binary.BigEndian.Uint16(decompressedBuffer[start:end][o.startSNMPIn:o.endSNMPIn])
-					silkFlow.SNMPOut = binary.BigEndian.Uint16(decompressedBuffer[start:end][o.startSNMPOut:o.endSNMPOut])
-					copy(silkFlow.NextHopIP, decompressedBuffer[start:end][o.startNextHopIP:o.endNextHopIP])
-					silkFlow.NextHopIP = net.ParseIP(net.IP(decompressedBuffer[start:end][o.startNextHopIP:o.endNextHopIP]).String())
-				} else {
-					silkFlow.Application = binary.BigEndian.Uint16(decompressedBuffer[start:end][o.startApplication:o.endApplication])
-				}
-
-				if header.RecordSize == 88 || header.RecordSize == 68 || header.RecordSize == 52 {
-					silkFlow.Sensor = binary.BigEndian.Uint16(decompressedBuffer[start:end][o.startSensor:o.endSensor])
-					silkFlow.InitalFlags = decompressedBuffer[o.startInitalFlags]
-					silkFlow.SessionFlags = decompressedBuffer[o.startSessionFlags]
-					silkFlow.Attributes = decompressedBuffer[o.startAttributes]
-					silkFlow.ClassType = decompressedBuffer[o.startClassType]
-				} else if header.RecordSize == 56 {
-					silkFlow.Sensor = uint16(header.fileSensor)
-				}
+				silkFlow.Flags = record[4]
 			}
-
-			receiver.HandleFlow(silkFlow)
-			start += int(header.RecordSize)
-			end += int(header.RecordSize)
+		} else {
+			silkFlow.Proto = record[4]
 		}
+	} else {
+		silkFlow.Flags = record[o.startTCPFlags]
+		silkFlow.Proto = record[o.startProto]
+		silkFlow.StartTimeMS = order.Uint64(record[o.startStartTime:o.endStartTime])
 	}
-	return
-}
 
-func intToIP(ip uint32) string {
-	result := make(net.IP, 4)
-	result[0] = byte(ip)
-	result[1] = byte(ip >> 8)
-	result[2] = byte(ip >> 16)
-	result[3] = byte(ip >> 24)
-	return result.String()
+	silkFlow.SrcPort = order.Uint16(record[o.startSrcPort:o.endSrcPort])
+	silkFlow.DstPort = order.Uint16(record[o.startDstPort:o.endDstPort])
+	silkFlow.Packets = order.Uint32(record[o.startPackets:o.endPackets])
+	silkFlow.Bytes = order.Uint32(record[o.startBytes:o.endBytes])
+	silkFlow.Duration = order.Uint32(record[o.startDuration:o.endDuration])
+	return
 }
 
 //OpenFile opens and parses silk file returning silk File struct and Error
@@ -533,7 +386,7 @@ func OpenFile(filePath string) (sf File, err error) {
 	var r2 = bytes.NewReader(data)
 
 	parsedFlows := NewSliceFlowReceiver(4096)
-	err = parseReader(r2, parsedFlows)
+	err = parseReader(context.Background(), r2, parsedFlows)
 	if err != nil {
 		return File{}, err
 	}
@@ -541,8 +394,17 @@ func OpenFile(filePath string) (sf File, err error) {
 	return parsedFlows.File, nil
 }
 
+//Parse parses reader passing the header and each flow to receiver. The
+//receiver is closed when parsing stops.
 func Parse(reader io.Reader, receiver FlowReceiver) (err error) {
-	err = parseReader(reader, receiver)
+	return ParseContext(context.Background(), reader, receiver)
+}
+
+//ParseContext is Parse with a context. Parsing stops with the context error
+//once ctx is cancelled, a ChannelFlowReceiver blocked sending a flow to a
+//consumer that stopped reading is released as well.
+func ParseContext(ctx context.Context, reader io.Reader, receiver FlowReceiver) (err error) {
+	err = parseReader(ctx, reader, receiver)
 	if err != nil {
 		return err
 	}
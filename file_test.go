@@ -1,9 +1,12 @@
 package silk
 
 import (
+	"bytes"
+	"context"
 	"net"
 	"os"
 	"testing"
+	"time"
 )
 
 //getTestDataFTRWIPV6ROUTINGV6V2 88 byte records
@@ -452,6 +455,82 @@ func TestParse(t *testing.T) {
 
 }
 
+//TestParseContextCancel verifies cancelling the context releases a parser
+//blocked on a consumer that stopped reading and reports the context error.
+func TestParseContextCancel(t *testing.T) {
+	var filePath = "testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var parseErr = make(chan error, 1)
+
+	reader, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	receiver := NewChannelFlowReceiver(0)
+	go func() {
+		parseErr <- ParseContext(ctx, reader, receiver)
+	}()
+
+	var rows int
+	for range receiver.Read() {
+		rows++
+		if rows == 10 {
+			break
+		}
+	}
+	cancel()
+
+	select {
+	case err = <-parseErr:
+		if err != context.Canceled {
+			t.Errorf("ParseContext error:%v expected:%s", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ParseContext did not return after cancel")
+	}
+	for range receiver.Read() {
+	}
+	if receiver.Err() != context.Canceled {
+		t.Errorf("Receiver Err:%v expected:%s", receiver.Err(), context.Canceled)
+	}
+}
+
+//TestParseContextError verifies a consumer can tell a corrupt file from a clean end of file
+func TestParseContextError(t *testing.T) {
+	var sf File
+	var err error
+
+	if sf, err = OpenFile("testdata/FT_RWIPV6-v2-c1-B.dat"); err != nil {
+		t.Fatalf("OpenFile error:%s", err)
+	}
+	var h = sf.Header
+	h.Compression = 0
+	buf, _ := writeTestFile(t, h, sf.Flows[:500])
+	var data = buf.Bytes()
+
+	for _, truncate := range []int{0, 20} {
+		var rows int
+		receiver := NewChannelFlowReceiver(10)
+		go ParseContext(context.Background(), bytes.NewReader(data[:len(data)-truncate]), receiver)
+		for range receiver.Read() {
+			rows++
+		}
+		if truncate == 0 {
+			if receiver.Err() != nil {
+				t.Errorf("Receiver Err:%s expected nil", receiver.Err())
+			}
+			if rows != 500 {
+				t.Errorf("Rows found:%d, rows expected:%d", rows, 500)
+			}
+		} else if receiver.Err() != ErrUnsupportedPartialRead {
+			t.Errorf("Receiver truncated file Err:%v expected:%s", receiver.Err(), ErrUnsupportedPartialRead)
+		}
+	}
+}
+
 //getBenchFileList returns list of all supported test files
 func getBenchFileList() []string {
 	return []string{
@@ -511,3 +590,184 @@ func BenchmarkReadFile(b *testing.B) {
 		}
 	}
 }
+
+//getRecordBenchFileList returns compressed test files that are read with no
+//allocations once the Reader buffers have grown to the block size
+func getRecordBenchFileList() []string {
+	return []string{
+		"testdata/FT_RWIPV6-v2-c3-L.dat",
+		"testdata/FT_RWIPV6-v1-c3-B.dat",
+		"testdata/FT_RWIPV6ROUTING-v2-c3-L.dat",
+		"testdata/FT_RWIPV6ROUTING-v2-c3-B.dat",
+	}
+}
+
+//TestApplication verifies the application is decoded by Parse and Reader for
+//every offset format, including the routing formats FT_RWIPV6ROUTING and FT_RWGENERIC
+func TestApplication(t *testing.T) {
+	for _, filePath := range []string{
+		"testdata/FT_RWIPV6ROUTING-v1-c1-L.dat",
+		"testdata/FT_RWIPV6ROUTING-v2-c1-B.dat",
+		"testdata/FT_RWGENERIC-v5-c1-L.dat",
+		"testdata/FT_RWGENERIC-v5-c1-B.dat",
+		"testdata/FT_RWIPV6-v1-c1-L.dat",
+		"testdata/FT_RWIPV6-v2-c1-B.dat",
+	} {
+		var sf File
+		var err error
+		if sf, err = OpenFile(filePath); err != nil {
+			t.Errorf("OpenFile file:%s error:%s", filePath, err)
+			continue
+		}
+		for x := range sf.Flows {
+			sf.Flows[x].Application = uint16(x*7 + 1)
+		}
+		buf, _ := writeTestFile(t, sf.Header, sf.Flows)
+		var data = buf.Bytes()
+
+		receiver := NewSliceFlowReceiver(len(sf.Flows))
+		if err = Parse(bytes.NewReader(data), receiver); err != nil {
+			t.Errorf("Parse file:%s error:%s", filePath, err)
+			continue
+		}
+		for x, flow := range receiver.File.Flows {
+			if flow.Application != uint16(x*7+1) {
+				t.Errorf("Parse file:%s row:%d application:%d expected:%d", filePath, x, flow.Application, x*7+1)
+				break
+			}
+		}
+
+		var sr *Reader
+		if sr, err = NewReader(bytes.NewReader(data)); err != nil {
+			t.Errorf("NewReader file:%s error:%s", filePath, err)
+			continue
+		}
+		for x := 0; sr.Next(); x++ {
+			if sr.Record().Application != uint16(x*7+1) {
+				t.Errorf("Reader file:%s row:%d application:%d expected:%d", filePath, x, sr.Record().Application, x*7+1)
+				break
+			}
+		}
+		if err = sr.Err(); err != nil {
+			t.Errorf("Reader file:%s error:%s", filePath, err)
+		}
+	}
+}
+
+//TestDecodeRecordAllocs verifies decoding a Record does not allocate for every record format
+func TestDecodeRecordAllocs(t *testing.T) {
+	var filePaths = append(getRecordBenchFileList(),
+		"testdata/FT_RWGENERIC-v5-c1-L.dat",
+		"testdata/FT_RWAUGROUTING-v5-c0-B.dat",
+		"testdata/FT_RWAUGWEB-v5-c0-L.dat",
+		"testdata/FT_RWWWW-v5-c0-B.dat",
+	)
+	for _, filePath := range filePaths {
+		var data []byte
+		var sr *Reader
+		var err error
+		if data, err = os.ReadFile(filePath); err != nil {
+			t.Fatalf("ReadFile error:%s", err)
+		}
+		if sr, err = NewReader(bytes.NewReader(data)); err != nil {
+			t.Fatalf("NewReader file:%s error:%s", filePath, err)
+		}
+		if sr.Next() == false {
+			t.Fatalf("File:%s has no records", filePath)
+		}
+		var allocs = testing.AllocsPerRun(100, func() {
+			sr.decode(sr.raw, &sr.header, &sr.record)
+		})
+		if allocs != 0 {
+			t.Errorf("File:%s decode allocs:%f expected:0", filePath, allocs)
+		}
+	}
+}
+
+//benchmarkReader reads b.N records from the test files in memory, calling
+//read for each record
+func benchmarkReader(b *testing.B, read func(sr *Reader)) {
+	var files [][]byte
+	for _, filePath := range getRecordBenchFileList() {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			b.Fatalf("ReadFile error:%s", err)
+		}
+		files = append(files, data)
+	}
+
+	var x int
+	var r = bytes.NewReader(files[x])
+	var sr, err = NewReader(r)
+	if err != nil {
+		b.Fatalf("NewReader error:%s", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for sr.Next() == false {
+			if err = sr.Err(); err != nil {
+				b.Fatalf("Reader error:%s", err)
+			}
+			x = (x + 1) % len(files)
+			r.Reset(files[x])
+			if sr, err = NewReader(r); err != nil {
+				b.Fatalf("NewReader error:%s", err)
+			}
+		}
+		read(sr)
+	}
+}
+
+//BenchmarkReaderRecord decodes one record per op into a reused Record, it reports 0 allocs/op
+func BenchmarkReaderRecord(b *testing.B) {
+	benchmarkReader(b, func(sr *Reader) {
+		if sr.Record().SrcPort == 0 && sr.Record().SrcIP.IsValid() == false {
+			b.Fatalf("Invalid record")
+		}
+	})
+}
+
+//BenchmarkReaderFlow decodes one record per op into a Flow for comparison with BenchmarkReaderRecord
+func BenchmarkReaderFlow(b *testing.B) {
+	benchmarkReader(b, func(sr *Reader) {
+		if sr.Flow().SrcIP == nil {
+			b.Fatalf("Invalid flow")
+		}
+	})
+}
+
+//benchmarkReaderConcurrency reads a whole zlib file per op with workers goroutines
+func benchmarkReaderConcurrency(b *testing.B, workers int) {
+	data, err := os.ReadFile("testdata/FT_RWIPV6ROUTING-v2-c1-L.dat")
+	if err != nil {
+		b.Fatalf("ReadFile error:%s", err)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var sr *Reader
+		if sr, err = NewReaderConcurrency(bytes.NewReader(data), workers); err != nil {
+			b.Fatalf("NewReaderConcurrency error:%s", err)
+		}
+		var x int
+		for sr.Next() {
+			if sr.Record().Packets > 0 {
+				x++
+			}
+		}
+		if x != 245340 {
+			b.Errorf("Rows found:%d, rows expected:%d", x, 245340)
+		}
+	}
+}
+
+//BenchmarkReaderSerial reads a zlib file on one goroutine
+func BenchmarkReaderSerial(b *testing.B) {
+	benchmarkReaderConcurrency(b, 1)
+}
+
+//BenchmarkReaderConcurrency reads a zlib file with 4 workers, compare with
+//BenchmarkReaderSerial using -cpu 4
+func BenchmarkReaderConcurrency(b *testing.B) {
+	benchmarkReaderConcurrency(b, 4)
+}
@@ -0,0 +1,331 @@
+/*
+Package filter selects silk flows with the switches of the SiLK rwfilter tool.
+
+	f, err := filter.Compile([]string{"--proto=6", "--dport=80,443", "--flags-initial=S/SA"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	receiver := silk.NewFilterReceiver(f, silk.NewSliceFlowReceiver(4096))
+
+Every switch must match for a flow to pass. Supported switches:
+
+	--saddress, --daddress, --any-address      IP wildcard, IPv6 address or CIDR block
+	--not-saddress, --not-daddress, --not-any-address
+	--scidr, --dcidr, --any-cidr, --nhcidr     comma separated IP addresses and CIDR blocks
+	--not-scidr, --not-dcidr, --not-any-cidr, --not-nhcidr
+	--next-hop-id                              same as --nhcidr
+	--ip-version                               4 or 6
+	--sport, --dport, --aport                  port list such as 80,443,1024-
+	--proto, --icmp-type, --icmp-code          number list
+	--application                              number list
+	--input-index, --output-index              SNMP interface list
+	--sensors, --flowtypes                     sensor and flowtype (class/type) id list
+	--flags-all, --flags-initial, --flags-session
+	                                           HIGH/MASK list such as S/SA,SA/SA
+	--tcp-flags                                same as --flags-all
+	--attributes                               HIGH/MASK list of F (FIN followed by more packets),
+	                                           S (same size), T (timeout killed) and
+	                                           C (continuation) attributes
+	--packets, --bytes, --bytes-per-packet     MIN-MAX range, MIN- for no upper bound
+	--duration                                 MIN-MAX range in seconds
+	--stime, --etime, --active-time            time range YYYY/MM/DD[:HH[:MM[:SS[.sss]]]]
+	                                           with an optional -END time, times are UTC
+
+Sensors and flowtypes are selected by their numeric ids since names require
+the site's silk.conf.
+*/
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chrispassas/silk"
+)
+
+//Filter is a compiled set of rwfilter switches, it implements
+//silk.PartialFilter so the Reader rejects most records from their protocol,
+//ports, flags, volumes and times before decoding the rest of the record.
+type Filter struct {
+	tests []test
+	//partialTests is the number of tests at the start of tests that only
+	//use the partial fields of silk.PartialFilter
+	partialTests int
+}
+
+//test is one compiled switch
+type test struct {
+	name    string
+	partial bool
+	match   func(r *silk.Record) bool
+}
+
+//partialSwitches are the switches that only test partial record fields
+var partialSwitches = map[string]bool{
+	"sport":            true,
+	"dport":            true,
+	"aport":            true,
+	"proto":            true,
+	"icmp-type":        true,
+	"icmp-code":        true,
+	"flags-all":        true,
+	"tcp-flags":        true,
+	"packets":          true,
+	"bytes":            true,
+	"bytes-per-packet": true,
+	"duration":         true,
+	"stime":            true,
+	"etime":            true,
+	"active-time":      true,
+}
+
+//Compile compiles rwfilter switches. Values can be given as --switch=value
+//or as --switch value.
+func Compile(args []string) (f *Filter, err error) {
+	f = &Filter{}
+	for x := 0; x < len(args); x++ {
+		var name, value = args[x], ""
+		if strings.HasPrefix(name, "--") == false {
+			err = fmt.Errorf("Unexpected argument:%s, switches start with --", name)
+			return nil, err
+		}
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, value = name[:i], name[i+1:]
+		} else if x+1 < len(args) {
+			x++
+			value = args[x]
+		} else {
+			err = fmt.Errorf("Switch:%s requires a value", name)
+			return nil, err
+		}
+
+		var t = test{name: strings.TrimPrefix(name, "--")}
+		if t.match, err = compileSwitch(t.name, value); err != nil {
+			return nil, fmt.Errorf("Switch:%s value:%q error:%s", name, value, err)
+		}
+		t.partial = partialSwitches[t.name]
+		f.tests = append(f.tests, t)
+	}
+
+	//Run the partial tests first, they are usually the most selective too
+	sort.SliceStable(f.tests, func(i, j int) bool {
+		return f.tests[i].partial && f.tests[j].partial == false
+	})
+	for f.partialTests < len(f.tests) && f.tests[f.partialTests].partial {
+		f.partialTests++
+	}
+	return
+}
+
+//Parse compiles an expression of space separated rwfilter switches such as
+//"--proto=17 --dport=53"
+func Parse(expr string) (f *Filter, err error) {
+	return Compile(strings.Fields(expr))
+}
+
+//Match returns true when flow passes every switch
+func (f *Filter) Match(flow *silk.Flow) bool {
+	var r silk.Record
+	r.SetFlow(flow)
+	return f.MatchRecord(&r)
+}
+
+//MatchRecord returns true when r passes every switch, it does not allocate
+func (f *Filter) MatchRecord(r *silk.Record) bool {
+	for x := range f.tests {
+		if f.tests[x].match(r) == false {
+			return false
+		}
+	}
+	return true
+}
+
+//MatchPartial runs the switches testing only the partial fields of r,
+//complete is true when there are no other switches
+func (f *Filter) MatchPartial(r *silk.Record) (match bool, complete bool) {
+	for x := 0; x < f.partialTests; x++ {
+		if f.tests[x].match(r) == false {
+			return false, true
+		}
+	}
+	return true, f.partialTests == len(f.tests)
+}
+
+//compileSwitch returns the test for a switch name without the leading --
+func compileSwitch(name string, value string) (match func(r *silk.Record) bool, err error) {
+	switch name {
+	case "saddress", "daddress", "any-address", "not-saddress", "not-daddress", "not-any-address":
+		var m addressMatcher
+		if m, err = parseAddress(value); err != nil {
+			return
+		}
+		match = addressTest(strings.TrimPrefix(strings.TrimSuffix(name, "address"), "not-"), m, strings.HasPrefix(name, "not-"))
+	case "scidr", "dcidr", "any-cidr", "nhcidr", "next-hop-id", "not-scidr", "not-dcidr", "not-any-cidr", "not-nhcidr":
+		var m addressMatcher
+		if m, err = parseCIDRList(value); err != nil {
+			return
+		}
+		var field = strings.TrimSuffix(strings.TrimPrefix(name, "not-"), "cidr")
+		if name == "next-hop-id" {
+			field = "nh"
+		}
+		match = addressTest(strings.TrimSuffix(field, "-"), m, strings.HasPrefix(name, "not-"))
+	case "ip-version":
+		switch value {
+		case "4":
+			match = func(r *silk.Record) bool { return r.SrcIP.Is4() && r.DstIP.Is4() }
+		case "6":
+			match = func(r *silk.Record) bool { return r.SrcIP.Is6() || r.DstIP.Is6() }
+		default:
+			err = fmt.Errorf("IP version must be 4 or 6")
+		}
+	case "sport", "dport", "aport", "application", "input-index", "output-index", "sensors":
+		var l numberList
+		if l, err = parseNumberList(value, 65535); err != nil {
+			return
+		}
+		match = uint16Test(name, l)
+	case "proto", "icmp-type", "icmp-code", "flowtypes":
+		var l numberList
+		if l, err = parseNumberList(value, 255); err != nil {
+			return
+		}
+		match = uint8Test(name, l)
+	case "flags-all", "tcp-flags", "flags-initial", "flags-session":
+		var l []flagMask
+		if l, err = parseFlagMasks(value, tcpFlagLetters); err != nil {
+			return
+		}
+		match = flagsTest(name, l)
+	case "attributes":
+		var l []flagMask
+		if l, err = parseFlagMasks(value, attributeLetters); err != nil {
+			return
+		}
+		match = func(r *silk.Record) bool { return matchFlagMasks(r.Attributes, l) }
+	case "packets", "bytes":
+		var min, max float64
+		if min, max, err = parseRange(value); err != nil {
+			return
+		}
+		match = countTest(name, min, max)
+	case "bytes-per-packet":
+		var min, max float64
+		if min, max, err = parseRange(value); err != nil {
+			return
+		}
+		match = func(r *silk.Record) bool {
+			if r.Packets == 0 {
+				return false
+			}
+			var bpp = float64(r.Bytes) / float64(r.Packets)
+			return bpp >= min && bpp <= max
+		}
+	case "duration":
+		var min, max float64
+		if min, max, err = parseRange(value); err != nil {
+			return
+		}
+		match = func(r *silk.Record) bool {
+			var seconds = float64(r.Duration) / 1000
+			return seconds >= min && seconds <= max
+		}
+	case "stime", "etime", "active-time":
+		var start, end uint64
+		if start, end, err = parseTimeRange(value); err != nil {
+			return
+		}
+		match = timeTest(name, start, end)
+	default:
+		err = fmt.Errorf("Unsupported switch")
+	}
+	return
+}
+
+//addressTest matches the source, destination, either or next hop address
+func addressTest(field string, m addressMatcher, not bool) func(r *silk.Record) bool {
+	switch field {
+	case "s":
+		return func(r *silk.Record) bool { return m.match(r.SrcIP) != not }
+	case "d":
+		return func(r *silk.Record) bool { return m.match(r.DstIP) != not }
+	case "nh":
+		return func(r *silk.Record) bool { return m.match(r.NextHopIP) != not }
+	}
+	//--not-any-address passes flows where neither address matches
+	return func(r *silk.Record) bool { return (m.match(r.SrcIP) || m.match(r.DstIP)) != not }
+}
+
+//uint16Test matches a 16 bit field against a number list
+func uint16Test(name string, l numberList) func(r *silk.Record) bool {
+	switch name {
+	case "sport":
+		return func(r *silk.Record) bool { return l.contains(uint32(r.SrcPort)) }
+	case "dport":
+		return func(r *silk.Record) bool { return l.contains(uint32(r.DstPort)) }
+	case "aport":
+		return func(r *silk.Record) bool { return l.contains(uint32(r.SrcPort)) || l.contains(uint32(r.DstPort)) }
+	case "application":
+		return func(r *silk.Record) bool { return l.contains(uint32(r.Application)) }
+	case "input-index":
+		return func(r *silk.Record) bool { return l.contains(uint32(r.SNMPIn)) }
+	case "output-index":
+		return func(r *silk.Record) bool { return l.contains(uint32(r.SNMPOut)) }
+	}
+	return func(r *silk.Record) bool { return l.contains(uint32(r.Sensor)) }
+}
+
+//uint8Test matches an 8 bit field against a number list. ICMP type and code
+//are stored in the destination port and only match ICMP flows.
+func uint8Test(name string, l numberList) func(r *silk.Record) bool {
+	switch name {
+	case "proto":
+		return func(r *silk.Record) bool { return l.contains(uint32(r.Proto)) }
+	case "icmp-type":
+		return func(r *silk.Record) bool { return isICMP(r) && l.contains(uint32(r.DstPort>>8)) }
+	case "icmp-code":
+		return func(r *silk.Record) bool { return isICMP(r) && l.contains(uint32(r.DstPort&0xFF)) }
+	}
+	return func(r *silk.Record) bool { return l.contains(uint32(r.ClassType)) }
+}
+
+//isICMP returns true for ICMP and ICMPv6 flows
+func isICMP(r *silk.Record) bool {
+	return r.Proto == 1 || r.Proto == 58
+}
+
+//flagsTest matches TCP flags against HIGH/MASK pairs, only TCP flows match
+func flagsTest(name string, l []flagMask) func(r *silk.Record) bool {
+	switch name {
+	case "flags-initial":
+		return func(r *silk.Record) bool { return r.Proto == 6 && matchFlagMasks(r.InitalFlags, l) }
+	case "flags-session":
+		return func(r *silk.Record) bool { return r.Proto == 6 && matchFlagMasks(r.SessionFlags, l) }
+	}
+	return func(r *silk.Record) bool { return r.Proto == 6 && matchFlagMasks(r.Flags, l) }
+}
+
+//countTest matches the packet or byte count against a range
+func countTest(name string, min float64, max float64) func(r *silk.Record) bool {
+	if name == "packets" {
+		return func(r *silk.Record) bool { return float64(r.Packets) >= min && float64(r.Packets) <= max }
+	}
+	return func(r *silk.Record) bool { return float64(r.Bytes) >= min && float64(r.Bytes) <= max }
+}
+
+//timeTest matches flows starting, ending or active within [start, end] milliseconds
+func timeTest(name string, start uint64, end uint64) func(r *silk.Record) bool {
+	switch name {
+	case "stime":
+		return func(r *silk.Record) bool { return r.StartTimeMS >= start && r.StartTimeMS <= end }
+	case "etime":
+		return func(r *silk.Record) bool {
+			var etime = r.StartTimeMS + uint64(r.Duration)
+			return etime >= start && etime <= end
+		}
+	}
+	return func(r *silk.Record) bool {
+		return r.StartTimeMS <= end && r.StartTimeMS+uint64(r.Duration) >= start
+	}
+}
@@ -0,0 +1,354 @@
+package filter
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/chrispassas/silk"
+)
+
+//testFlow is a TCP flow used by TestMatch, 2009/02/13 23:31:30 UTC for 1.5 seconds
+var testFlow = silk.Flow{
+	StartTimeMS:  1234567890000,
+	Duration:     1500,
+	SrcIP:        net.ParseIP("10.1.2.3"),
+	DstIP:        net.ParseIP("2001:db8::1"),
+	SrcPort:      51000,
+	DstPort:      443,
+	Proto:        6,
+	Flags:        0x1B,
+	Packets:      10,
+	Bytes:        1500,
+	ClassType:    1,
+	Sensor:       7,
+	InitalFlags:  0x02,
+	SessionFlags: 0x19,
+	Attributes:   0x08,
+	Application:  443,
+	SNMPIn:       3,
+	SNMPOut:      4,
+	NextHopIP:    net.ParseIP("192.168.0.1"),
+}
+
+//TestMatch verifies each switch against testFlow
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		match bool
+	}{
+		{"", true},
+		{"--saddress=10.1.2.3", true},
+		{"--saddress 10.1.2.4", false},
+		{"--saddress=10.x.1-5.1,3", true},
+		{"--saddress=10.*.3-5.x", false},
+		{"--saddress=10.0.0.0/8", true},
+		{"--not-saddress=10.0.0.0/8", false},
+		{"--daddress=2001:db8::/32", true},
+		{"--daddress=10.x.x.x", false},
+		{"--any-address=2001:db8::1", true},
+		{"--not-any-address=172.16.0.0/12", true},
+		{"--scidr=172.16.0.0/12,10.1.0.0/16", true},
+		{"--scidr=::ffff:10.1.0.0/112", true},
+		{"--not-scidr=10.1.2.3", false},
+		{"--dcidr=2001:db9::/32", false},
+		{"--any-cidr=2001:db8::/64", true},
+		{"--nhcidr=192.168.0.0/24", true},
+		{"--next-hop-id=192.168.1.0/24", false},
+		{"--ip-version=6", true},
+		{"--ip-version=4", false},
+		{"--sport=1024-", true},
+		{"--sport=0-1023", false},
+		{"--dport=80,443", true},
+		{"--dport=80,8080", false},
+		{"--aport=443", true},
+		{"--proto=6", true},
+		{"--proto=1,17", false},
+		{"--proto=0-5,6", true},
+		{"--icmp-type=0", false},
+		{"--application=443", true},
+		{"--input-index=3", true},
+		{"--output-index=3", false},
+		{"--sensors=1-10", true},
+		{"--flowtypes=0", false},
+		{"--flags-all=S/S", true},
+		{"--flags-all=S/SR", true},
+		{"--flags-all=R/R", false},
+		{"--tcp-flags=SA/SAF", false},
+		{"--flags-initial=S/SA", true},
+		{"--flags-initial=SA/SA,F/F", false},
+		{"--flags-session=FPA/FSRPA", true},
+		{"--attributes=F/FTC", true},
+		{"--attributes=T", false},
+		{"--packets=10", true},
+		{"--packets=11-", false},
+		{"--bytes=1000-2000", true},
+		{"--bytes-per-packet=150", true},
+		{"--bytes-per-packet=0-149.5", false},
+		{"--duration=1-2", true},
+		{"--duration=0-1.49", false},
+		{"--stime=2009/02/13", true},
+		{"--stime=2009/02/13T23", true},
+		{"--stime=2009/02/13:23:31:30.0", true},
+		{"--stime=2009/02/13:23:31:31", false},
+		{"--stime=2009/02/13:23:00-2009/02/13:23:30", false},
+		{"--etime=2009/02/13:23:31:31.5", true},
+		{"--etime=2009/02/13:23:31:30", false},
+		{"--active-time=2009/02/13:23:31:31", true},
+		{"--active-time=2009/02/13:23:32-2009/02/14", false},
+		{"--proto=6 --dport=443 --flags-initial=S/SA", true},
+		{"--proto=6 --dport=80", false},
+	}
+
+	for _, test := range tests {
+		f, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Parse expr:%q error:%s", test.expr, err)
+			continue
+		}
+		if match := f.Match(&testFlow); match != test.match {
+			t.Errorf("Expr:%q match:%t expected:%t", test.expr, match, test.match)
+		}
+	}
+}
+
+//TestICMP verifies the ICMP type and code are read from the destination port
+func TestICMP(t *testing.T) {
+	var flow = silk.Flow{SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.0.2"), Proto: 1, DstPort: 3<<8 | 13}
+	for expr, expected := range map[string]bool{
+		"--icmp-type=3":                true,
+		"--icmp-type=0,8":              false,
+		"--icmp-type=3 --icmp-code=13": true,
+		"--icmp-code=0-12":             false,
+		"--flags-all=S/S":              false,
+	} {
+		f, err := Parse(expr)
+		if err != nil {
+			t.Errorf("Parse expr:%q error:%s", expr, err)
+			continue
+		}
+		if match := f.Match(&flow); match != expected {
+			t.Errorf("Expr:%q match:%t expected:%t", expr, match, expected)
+		}
+	}
+}
+
+//TestAttributes verifies each attribute letter against a record decoded from
+//a file with a known tcp_state byte, using the bits from SiLK's rwrec.h
+func TestAttributes(t *testing.T) {
+	var states = map[byte]uint8{
+		'F': 0x08, //SK_TCPSTATE_FIN_FOLLOWED_NOT_ACK
+		'S': 0x10, //SK_TCPSTATE_UNIFORM_PACKET_SIZE
+		'T': 0x20, //SK_TCPSTATE_TIMEOUT_KILLED
+		'C': 0x40, //SK_TCPSTATE_TIMEOUT_STARTED
+	}
+	for letter, state := range states {
+		var buf bytes.Buffer
+		var sw, err = silk.NewWriter(&buf, silk.Header{RecordFormat: silk.FormatRWIPV6Routing, RecordVersion: 1})
+		if err != nil {
+			t.Fatalf("NewWriter error:%s", err)
+		}
+		var flow = testFlow
+		flow.Attributes = state
+		if err = sw.Write(flow); err != nil {
+			t.Fatalf("Write error:%s", err)
+		}
+		if err = sw.Close(); err != nil {
+			t.Fatalf("Close error:%s", err)
+		}
+
+		var sr *silk.Reader
+		if sr, err = silk.NewReader(&buf); err != nil {
+			t.Fatalf("NewReader error:%s", err)
+		}
+		if sr.Next() == false {
+			t.Fatalf("Next error:%v", sr.Err())
+		}
+		var r = sr.Record()
+		if r.Attributes != state {
+			t.Fatalf("Record tcp_state:%#x expected:%#x", r.Attributes, state)
+		}
+		for other := range states {
+			var expr = "--attributes=" + string(other) + "/" + string(other)
+			var f *Filter
+			if f, err = Parse(expr); err != nil {
+				t.Fatalf("Parse expr:%q error:%s", expr, err)
+			}
+			if match := f.MatchRecord(r); match != (other == letter) {
+				t.Errorf("tcp_state:%#x expr:%q match:%t expected:%t", state, expr, match, other == letter)
+			}
+		}
+	}
+}
+
+//TestCompileErrors verifies invalid switches and values are rejected
+func TestCompileErrors(t *testing.T) {
+	var tests = [][]string{
+		{"--unknown=1"},
+		{"proto=6"},
+		{"--proto"},
+		{"--proto=256"},
+		{"--dport=443-80"},
+		{"--dport=http"},
+		{"--saddress=10.1.2"},
+		{"--saddress=10.1.2.300-301"},
+		{"--scidr=10.0.0.0/33"},
+		{"--flags-all=SA/S"},
+		{"--flags-all=SX"},
+		{"--attributes=Q"},
+		{"--packets=10-1"},
+		{"--bytes=-1"},
+		{"--stime=2009-02-13"},
+		{"--stime=2009/02/14-2009/02/13"},
+		{"--ip-version=5"},
+	}
+
+	for _, args := range tests {
+		if _, err := Compile(args); err == nil {
+			t.Errorf("Compile args:%q expected error", args)
+		}
+	}
+}
+
+//TestFilterReceiver verifies only matching flows from a file reach the wrapped receiver
+func TestFilterReceiver(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var sf silk.File
+	var f *os.File
+	var err error
+
+	if sf, err = silk.OpenFile(filePath); err != nil {
+		t.Fatalf("OpenFile file:%s error:%s", filePath, err)
+	}
+
+	var filter *Filter
+	if filter, err = Compile([]string{"--proto=6", "--dport", "1024-"}); err != nil {
+		t.Fatalf("Compile error:%s", err)
+	}
+	var expected int
+	for x := range sf.Flows {
+		if sf.Flows[x].Proto == 6 && sf.Flows[x].DstPort >= 1024 {
+			expected++
+		}
+	}
+	if expected == 0 || expected == len(sf.Flows) {
+		t.Fatalf("File:%s expected a mix of matching and non matching flows", filePath)
+	}
+
+	if f, err = os.Open(filePath); err != nil {
+		t.Fatalf("Open file:%s error:%s", filePath, err)
+	}
+	defer f.Close()
+
+	var receiver = silk.NewSliceFlowReceiver(len(sf.Flows))
+	if err = silk.Parse(f, silk.NewFilterReceiver(filter, receiver)); err != nil {
+		t.Fatalf("Parse file:%s error:%s", filePath, err)
+	}
+	if len(receiver.Flows) != expected {
+		t.Errorf("File:%s filtered flows:%d expected:%d", filePath, len(receiver.Flows), expected)
+	}
+	for x := range receiver.Flows {
+		if filter.Match(&receiver.Flows[x]) == false {
+			t.Errorf("File:%s flow:%d does not match filter", filePath, x)
+		}
+	}
+	if receiver.Header.RecordFormat != sf.Header.RecordFormat {
+		t.Errorf("File:%s header not forwarded", filePath)
+	}
+}
+
+//TestReaderFilter verifies filtering in the Reader, which tests the partial
+//fields first, returns the same records as testing every decoded record
+func TestReaderFilter(t *testing.T) {
+	var filePaths = []string{
+		"../testdata/FT_RWIPV6-v2-c1-B.dat",
+		"../testdata/FT_RWGENERIC-v5-c1-L.dat",
+		"../testdata/FT_RWAUGMENTED-v5-c0-L.dat",
+	}
+	var exprs = []string{
+		"--proto=17 --dport=53",
+		"--dport=53 --daddress=x.x.x.1-127",
+		"--saddress=x.x.x.0-100",
+		"--sport=1024- --output-index=0",
+	}
+	for _, filePath := range filePaths {
+		var data []byte
+		var err error
+		if data, err = os.ReadFile(filePath); err != nil {
+			t.Fatalf("ReadFile error:%s", err)
+		}
+		for _, expr := range exprs {
+			var f *Filter
+			if f, err = Parse(expr); err != nil {
+				t.Fatalf("Parse expr:%q error:%s", expr, err)
+			}
+
+			var all, filtered *silk.Reader
+			if all, err = silk.NewReader(bytes.NewReader(data)); err != nil {
+				t.Fatalf("NewReader file:%s error:%s", filePath, err)
+			}
+			if filtered, err = silk.NewReader(bytes.NewReader(data)); err != nil {
+				t.Fatalf("NewReader file:%s error:%s", filePath, err)
+			}
+			filtered.SetFilter(f)
+
+			var matches int
+			for all.Next() {
+				if f.MatchRecord(all.Record()) == false {
+					continue
+				}
+				matches++
+				if filtered.Next() == false {
+					t.Errorf("File:%s expr:%q filtered records ended early", filePath, expr)
+					break
+				}
+				if *filtered.Record() != *all.Record() {
+					t.Errorf("File:%s expr:%q record:%+v expected:%+v", filePath, expr, *filtered.Record(), *all.Record())
+					break
+				}
+			}
+			if filtered.Next() {
+				t.Errorf("File:%s expr:%q filtered records after the last match", filePath, expr)
+			}
+			if all.Err() != nil || filtered.Err() != nil {
+				t.Errorf("File:%s expr:%q Err:%v %v", filePath, expr, all.Err(), filtered.Err())
+			}
+			if matches == 0 {
+				t.Errorf("File:%s expr:%q expected matching records", filePath, expr)
+			}
+		}
+	}
+}
+
+//benchmarkParse parses a file into a slice receiver wrapped by a FilterReceiver for expr
+func benchmarkParse(b *testing.B, expr string) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c3-L.dat"
+	var data []byte
+	var f *Filter
+	var err error
+	if data, err = os.ReadFile(filePath); err != nil {
+		b.Fatalf("ReadFile error:%s", err)
+	}
+	if f, err = Parse(expr); err != nil {
+		b.Fatalf("Parse expr:%q error:%s", expr, err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		var receiver = silk.NewSliceFlowReceiver(4096)
+		if err = silk.Parse(bytes.NewReader(data), silk.NewFilterReceiver(f, receiver)); err != nil {
+			b.Fatalf("Parse error:%s", err)
+		}
+	}
+}
+
+//BenchmarkParseFilterPartial filters on the destination port only
+func BenchmarkParseFilterPartial(b *testing.B) {
+	benchmarkParse(b, "--proto=6 --dport=53")
+}
+
+//BenchmarkParseFilterAddress filters on the source address which requires a full decode
+func BenchmarkParseFilterAddress(b *testing.B) {
+	benchmarkParse(b, "--saddress=10.0.0.1")
+}
@@ -0,0 +1,277 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//tcpFlagLetters maps the rwfilter TCP flag letters to their bits
+var tcpFlagLetters = map[byte]uint8{
+	'F': 0x01,
+	'S': 0x02,
+	'R': 0x04,
+	'P': 0x08,
+	'A': 0x10,
+	'U': 0x20,
+	'E': 0x40,
+	'C': 0x80,
+}
+
+//attributeLetters maps the rwfilter attribute letters to their bits in the
+//record's TCP state byte
+var attributeLetters = map[byte]uint8{
+	'F': 0x08,
+	'S': 0x10,
+	'T': 0x20,
+	'C': 0x40,
+}
+
+//addressMatcher matches IP addresses against IPv4 wildcards and prefixes
+type addressMatcher struct {
+	wildcard *[4][256]bool
+	prefixes []netip.Prefix
+}
+
+//match returns true when addr matches the wildcard or any prefix
+func (m *addressMatcher) match(addr netip.Addr) bool {
+	if m.wildcard != nil && addr.Is4() {
+		var b = addr.As4()
+		return m.wildcard[0][b[0]] && m.wildcard[1][b[1]] && m.wildcard[2][b[2]] && m.wildcard[3][b[3]]
+	}
+	for x := range m.prefixes {
+		if m.prefixes[x].Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+//parseAddress parses the value of --saddress and friends: an IPv4 wildcard
+//such as 10.1.x.1-10,20, an IP address or a CIDR block
+func parseAddress(value string) (m addressMatcher, err error) {
+	if strings.ContainsAny(value, "x*,-") == false {
+		return parseCIDRList(value)
+	}
+	var octets = strings.Split(value, ".")
+	if len(octets) != 4 {
+		err = fmt.Errorf("IP wildcards must have 4 octets")
+		return
+	}
+	m.wildcard = &[4][256]bool{}
+	for x, octet := range octets {
+		if octet == "x" || octet == "*" {
+			for y := range m.wildcard[x] {
+				m.wildcard[x][y] = true
+			}
+			continue
+		}
+		var l numberList
+		if l, err = parseNumberList(octet, 255); err != nil {
+			return
+		}
+		for y := range m.wildcard[x] {
+			m.wildcard[x][y] = l.contains(uint32(y))
+		}
+	}
+	return
+}
+
+//parseCIDRList parses a comma separated list of IP addresses and CIDR blocks
+func parseCIDRList(value string) (m addressMatcher, err error) {
+	for _, s := range strings.Split(value, ",") {
+		var prefix netip.Prefix
+		if strings.IndexByte(s, '/') >= 0 {
+			if prefix, err = netip.ParsePrefix(s); err != nil {
+				return
+			}
+		} else {
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(s); err != nil {
+				return
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		//Records hold IPv4 mapped addresses as IPv4 addresses
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		m.prefixes = append(m.prefixes, prefix.Masked())
+	}
+	return
+}
+
+//numberRange is an inclusive range of numbers
+type numberRange struct {
+	min uint32
+	max uint32
+}
+
+//numberList is a list of numbers and number ranges
+type numberList []numberRange
+
+//contains returns true when n is in any of the list's ranges
+func (l numberList) contains(n uint32) bool {
+	for x := range l {
+		if n >= l[x].min && n <= l[x].max {
+			return true
+		}
+	}
+	return false
+}
+
+//parseNumberList parses a comma separated list of numbers and ranges such
+//as 80,443,1024-2048 or 1024- up to max
+func parseNumberList(value string, max uint32) (l numberList, err error) {
+	for _, s := range strings.Split(value, ",") {
+		var r numberRange
+		var low, high = s, s
+		if i := strings.IndexByte(s, '-'); i >= 0 {
+			low, high = s[:i], s[i+1:]
+			if high == "" {
+				high = strconv.FormatUint(uint64(max), 10)
+			}
+		}
+		var n uint64
+		if n, err = strconv.ParseUint(low, 10, 32); err != nil {
+			return
+		}
+		r.min = uint32(n)
+		if n, err = strconv.ParseUint(high, 10, 32); err != nil {
+			return
+		}
+		r.max = uint32(n)
+		if r.min > r.max || r.max > max {
+			err = fmt.Errorf("Range:%s must be between 0 and %d", s, max)
+			return
+		}
+		l = append(l, r)
+	}
+	return
+}
+
+//flagMask matches when the masked bits equal high
+type flagMask struct {
+	high uint8
+	mask uint8
+}
+
+//matchFlagMasks returns true when flags matches any of l
+func matchFlagMasks(flags uint8, l []flagMask) bool {
+	for x := range l {
+		if flags&l[x].mask == l[x].high {
+			return true
+		}
+	}
+	return false
+}
+
+//parseFlagMasks parses a comma separated list of HIGH/MASK letter pairs such
+//as S/SA. A pair without a mask uses HIGH as the mask.
+func parseFlagMasks(value string, letters map[byte]uint8) (l []flagMask, err error) {
+	for _, s := range strings.Split(value, ",") {
+		var fm flagMask
+		var high, mask = s, s
+		if i := strings.IndexByte(s, '/'); i >= 0 {
+			high, mask = s[:i], s[i+1:]
+		}
+		if fm.high, err = parseFlagLetters(high, letters); err != nil {
+			return
+		}
+		if fm.mask, err = parseFlagLetters(mask, letters); err != nil {
+			return
+		}
+		if fm.high&fm.mask != fm.high {
+			err = fmt.Errorf("High flags:%s must be a subset of mask:%s", high, mask)
+			return
+		}
+		l = append(l, fm)
+	}
+	return
+}
+
+//parseFlagLetters returns the bits of flag letters, letters are case insensitive
+func parseFlagLetters(s string, letters map[byte]uint8) (flags uint8, err error) {
+	for _, c := range []byte(strings.ToUpper(s)) {
+		bit, ok := letters[c]
+		if ok == false {
+			err = fmt.Errorf("Unknown flag:%c", c)
+			return
+		}
+		flags |= bit
+	}
+	return
+}
+
+//parseRange parses MIN-MAX, MIN- or a single value
+func parseRange(value string) (min float64, max float64, err error) {
+	var low, high = value, value
+	if i := strings.IndexByte(value, '-'); i >= 0 {
+		low, high = value[:i], value[i+1:]
+	}
+	if min, err = strconv.ParseFloat(low, 64); err != nil {
+		return
+	}
+	if high == "" {
+		max = math.Inf(1)
+	} else if max, err = strconv.ParseFloat(high, 64); err != nil {
+		return
+	}
+	if min < 0 || min > max {
+		err = fmt.Errorf("Range:%s must be MIN-MAX with MIN <= MAX", value)
+	}
+	return
+}
+
+//parseTimeRange parses START-END or a single time, times are milliseconds
+//since the epoch. Times given to a lower precision cover the whole period,
+//2009/02/13:10 ends at 2009/02/13:10:59:59.999
+func parseTimeRange(value string) (start uint64, end uint64, err error) {
+	var low, high = value, value
+	if i := strings.IndexByte(value, '-'); i >= 0 {
+		low, high = value[:i], value[i+1:]
+	}
+	if start, _, err = parseTime(low); err != nil {
+		return
+	}
+	if _, end, err = parseTime(high); err != nil {
+		return
+	}
+	if start > end {
+		err = fmt.Errorf("Start time:%s is after end time:%s", low, high)
+	}
+	return
+}
+
+//parseTime parses YYYY/MM/DD[:HH[:MM[:SS[.sss]]]] in UTC, a T may separate
+//the date from the hour. It returns the first and last millisecond of the
+//period the time covers.
+func parseTime(value string) (start uint64, end uint64, err error) {
+	var layouts = []struct {
+		layout string
+		period time.Duration
+	}{
+		{"2006/01/02", 24 * time.Hour},
+		{"2006/01/02:15", time.Hour},
+		{"2006/01/02:15:04", time.Minute},
+		{"2006/01/02:15:04:05", time.Second},
+		{"2006/01/02:15:04:05.000", time.Millisecond},
+	}
+	var s = strings.Replace(value, "T", ":", 1)
+	if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 < 3 {
+		//Pad fractional seconds to milliseconds
+		s += strings.Repeat("0", 3-(len(s)-i-1))
+	}
+	for _, l := range layouts {
+		if t, e := time.ParseInLocation(l.layout, s, time.UTC); e == nil {
+			start = uint64(t.UnixNano() / int64(time.Millisecond))
+			end = start + uint64(l.period/time.Millisecond) - 1
+			return
+		}
+	}
+	err = fmt.Errorf("Time:%s must be YYYY/MM/DD[:HH[:MM[:SS[.sss]]]]", value)
+	return
+}
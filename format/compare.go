@@ -0,0 +1,105 @@
+package format
+
+import (
+	"bytes"
+	"net/netip"
+
+	"github.com/chrispassas/silk"
+)
+
+//Compare returns -1, 0 or 1 as field f of a is less than, equal to or
+//greater than field f of b. IP addresses compare as IPv6 with IPv4
+//addresses mapped into ::ffff:0:0/96 and the zero netip.Addr as ::, TCP
+//flags and attributes compare as numbers and the ICMP type and code of
+//flows that aren't ICMP are 0.
+func (f Field) Compare(a, b *silk.Record) int {
+	switch f {
+	case FieldSIP:
+		return compareAddr(a.SrcIP, b.SrcIP)
+	case FieldDIP:
+		return compareAddr(a.DstIP, b.DstIP)
+	case FieldNhIP:
+		return compareAddr(a.NextHopIP, b.NextHopIP)
+	case FieldSTime:
+		return compareUint(a.StartTimeMS, b.StartTimeMS)
+	case FieldETime:
+		return compareUint(a.StartTimeMS+uint64(a.Duration), b.StartTimeMS+uint64(b.Duration))
+	}
+	return compareUint(f.Value(a), f.Value(b))
+}
+
+//Value returns field f of r as a number. IP addresses and the end time
+//don't fit and return 0, the start time is milliseconds since the epoch.
+func (f Field) Value(r *silk.Record) uint64 {
+	switch f {
+	case FieldSPort:
+		return uint64(r.SrcPort)
+	case FieldDPort:
+		return uint64(r.DstPort)
+	case FieldProtocol:
+		return uint64(r.Proto)
+	case FieldPackets:
+		return uint64(r.Packets)
+	case FieldBytes:
+		return uint64(r.Bytes)
+	case FieldFlags:
+		return uint64(r.Flags)
+	case FieldSTime:
+		return r.StartTimeMS
+	case FieldDuration:
+		return uint64(r.Duration)
+	case FieldSensor:
+		return uint64(r.Sensor)
+	case FieldIn:
+		return uint64(r.SNMPIn)
+	case FieldOut:
+		return uint64(r.SNMPOut)
+	case FieldInitialFlags:
+		return uint64(r.InitalFlags)
+	case FieldSessionFlags:
+		return uint64(r.SessionFlags)
+	case FieldAttributes:
+		return uint64(r.Attributes)
+	case FieldApplication:
+		return uint64(r.Application)
+	case FieldIType:
+		if isICMP(r) {
+			return uint64(r.DstPort >> 8)
+		}
+	case FieldICode:
+		if isICMP(r) {
+			return uint64(r.DstPort & 0xFF)
+		}
+	case FieldFlowType:
+		return uint64(r.ClassType)
+	}
+	return 0
+}
+
+//CompareFields compares a and b by each of fields in turn, returning the
+//first result that isn't 0
+func CompareFields(fields []Field, a, b *silk.Record) int {
+	for _, f := range fields {
+		if c := f.Compare(a, b); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+//compareAddr compares the 16 byte forms of a and b
+func compareAddr(a, b netip.Addr) int {
+	var x, y = a.As16(), b.As16()
+	return bytes.Compare(x[:], y[:])
+}
+
+//compareUint returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compareUint(a, b uint64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package format
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/chrispassas/silk"
+)
+
+//TestCompare verifies field comparisons
+func TestCompare(t *testing.T) {
+	var a = silk.Record{SrcIP: netip.MustParseAddr("10.0.0.2"), DstIP: netip.MustParseAddr("2001:db8::1"), Proto: 1, DstPort: 0x0300, StartTimeMS: 1000, Duration: 500}
+	var b = silk.Record{SrcIP: netip.MustParseAddr("10.0.0.10"), DstIP: netip.MustParseAddr("10.0.0.1"), Proto: 6, DstPort: 0x0800, StartTimeMS: 1200, Duration: 100}
+	var tests = []struct {
+		field    Field
+		expected int
+	}{
+		{FieldSIP, -1},
+		{FieldDIP, 1},
+		{FieldNhIP, 0},
+		{FieldSTime, -1},
+		{FieldETime, 1},
+		{FieldDPort, -1},
+		{FieldIType, 1},
+		{FieldProtocol, -1},
+	}
+	for _, test := range tests {
+		if found := test.field.Compare(&a, &b); found != test.expected {
+			t.Errorf("Field:%s compare:%d expected:%d", test.field, found, test.expected)
+		}
+		if found := test.field.Compare(&b, &a); found != -test.expected {
+			t.Errorf("Field:%s reversed compare:%d expected:%d", test.field, found, -test.expected)
+		}
+	}
+	if found := CompareFields([]Field{FieldSensor, FieldSIP}, &a, &b); found != -1 {
+		t.Errorf("CompareFields:%d expected:-1", found)
+	}
+}
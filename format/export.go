@@ -0,0 +1,356 @@
+package format
+
+import (
+	"bufio"
+	"io"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/chrispassas/silk"
+)
+
+//protocolNames are the IANA keywords of common IP protocols
+var protocolNames = map[uint8]string{
+	1:   "ICMP",
+	2:   "IGMP",
+	4:   "IPv4",
+	6:   "TCP",
+	17:  "UDP",
+	41:  "IPv6",
+	47:  "GRE",
+	50:  "ESP",
+	51:  "AH",
+	58:  "IPv6-ICMP",
+	89:  "OSPFIGP",
+	103: "PIM",
+	112: "VRRP",
+	132: "SCTP",
+}
+
+//ProtocolName returns the IANA keyword of an IP protocol such as TCP, or
+//the protocol number for protocols without a keyword here
+func ProtocolName(proto uint8) string {
+	if name, ok := protocolNames[proto]; ok {
+		return name
+	}
+	return strconv.Itoa(int(proto))
+}
+
+//appendProtocolName appends ProtocolName(proto) without allocating
+func appendProtocolName(dst []byte, proto uint8) []byte {
+	if name, ok := protocolNames[proto]; ok {
+		return append(dst, name...)
+	}
+	return strconv.AppendUint(dst, uint64(proto), 10)
+}
+
+//exportTimeLayout is the RFC 3339 layout of exported times, always UTC with milliseconds
+const exportTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+//exporter holds the state shared by the JSON and CSV receivers. Each flow
+//is written as it arrives through a fixed size buffer so memory use does
+//not grow with the number of flows.
+type exporter struct {
+	w      *bufio.Writer
+	fields []Field
+	record silk.Record
+	line   []byte
+	err    error
+}
+
+//newExporter returns an exporter of fields, AllFields when empty
+func newExporter(w io.Writer, fields []Field) exporter {
+	if len(fields) == 0 {
+		fields = AllFields
+	}
+	return exporter{w: bufio.NewWriter(w), fields: fields}
+}
+
+//write writes the line unless an earlier write failed
+func (e *exporter) write() error {
+	if e.err == nil {
+		_, e.err = e.w.Write(e.line)
+	}
+	return e.err
+}
+
+//close flushes buffered output
+func (e *exporter) close() {
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+}
+
+//exportValue appends the JSON form of field f of r, strings are quoted.
+//Values that don't apply to the flow are null.
+func exportValue(dst []byte, f Field, r *silk.Record) []byte {
+	switch f {
+	case FieldSIP:
+		return appendQuotedIP(dst, r.SrcIP)
+	case FieldDIP:
+		return appendQuotedIP(dst, r.DstIP)
+	case FieldSPort:
+		return strconv.AppendUint(dst, uint64(r.SrcPort), 10)
+	case FieldDPort:
+		return strconv.AppendUint(dst, uint64(r.DstPort), 10)
+	case FieldProtocol:
+		return strconv.AppendUint(dst, uint64(r.Proto), 10)
+	case FieldPackets:
+		return strconv.AppendUint(dst, uint64(r.Packets), 10)
+	case FieldBytes:
+		return strconv.AppendUint(dst, uint64(r.Bytes), 10)
+	case FieldFlags:
+		return appendQuotedFlags(dst, r.Flags)
+	case FieldSTime:
+		return appendQuotedTime(dst, r.StartTimeMS)
+	case FieldDuration:
+		return strconv.AppendUint(dst, uint64(r.Duration), 10)
+	case FieldETime:
+		return appendQuotedTime(dst, r.StartTimeMS+uint64(r.Duration))
+	case FieldSensor:
+		return strconv.AppendUint(dst, uint64(r.Sensor), 10)
+	case FieldIn:
+		return strconv.AppendUint(dst, uint64(r.SNMPIn), 10)
+	case FieldOut:
+		return strconv.AppendUint(dst, uint64(r.SNMPOut), 10)
+	case FieldNhIP:
+		return appendQuotedIP(dst, r.NextHopIP)
+	case FieldInitialFlags:
+		return appendQuotedFlags(dst, r.InitalFlags)
+	case FieldSessionFlags:
+		return appendQuotedFlags(dst, r.SessionFlags)
+	case FieldAttributes:
+		dst = append(dst, '"')
+		dst = appendAttributes(dst, r.Attributes, false)
+		return append(dst, '"')
+	case FieldApplication:
+		return strconv.AppendUint(dst, uint64(r.Application), 10)
+	case FieldIType:
+		if isICMP(r) {
+			return strconv.AppendUint(dst, uint64(r.DstPort>>8), 10)
+		}
+	case FieldICode:
+		if isICMP(r) {
+			return strconv.AppendUint(dst, uint64(r.DstPort&0xFF), 10)
+		}
+	case FieldFlowType:
+		return strconv.AppendUint(dst, uint64(r.ClassType), 10)
+	}
+	return append(dst, "null"...)
+}
+
+//appendQuotedIP appends the quoted canonical form of addr, null for the zero netip.Addr
+func appendQuotedIP(dst []byte, addr netip.Addr) []byte {
+	if addr.IsValid() == false {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '"')
+	dst = addr.AppendTo(dst)
+	return append(dst, '"')
+}
+
+//appendQuotedFlags appends the quoted letters of the set TCP flags
+func appendQuotedFlags(dst []byte, flags uint8) []byte {
+	dst = append(dst, '"')
+	dst = appendTCPFlags(dst, flags, false)
+	return append(dst, '"')
+}
+
+//appendQuotedTime appends the quoted RFC 3339 time of ms milliseconds since the epoch
+func appendQuotedTime(dst []byte, ms uint64) []byte {
+	dst = append(dst, '"')
+	dst = time.Unix(int64(ms/1000), int64(ms%1000)*int64(time.Millisecond)).UTC().AppendFormat(dst, exportTimeLayout)
+	return append(dst, '"')
+}
+
+//JSONOptions selects the fields of a JSONReceiver, AllFields when empty
+type JSONOptions struct {
+	Fields []Field
+}
+
+//JSONReceiver is a silk.FlowReceiver writing each flow as a JSON object on
+//its own line (JSON Lines). Object keys are the field keys, see Field.Key,
+//times are RFC 3339 UTC strings with milliseconds, TCP flags are letter
+//strings such as "FSPA" and the protocol is followed by a protocol_name
+//key. Close flushes the output but does not close the io.Writer, check Err
+//afterwards since receiver methods can't return errors.
+type JSONReceiver struct {
+	exporter
+}
+
+//NewJSONReceiver returns a JSONReceiver writing to w
+func NewJSONReceiver(w io.Writer, opts JSONOptions) *JSONReceiver {
+	return &JSONReceiver{exporter: newExporter(w, opts.Fields)}
+}
+
+//HandleHeader does nothing, JSON Lines output has no header
+func (jr *JSONReceiver) HandleHeader(h silk.Header) {
+}
+
+//HandleFlow writes f as one line
+func (jr *JSONReceiver) HandleFlow(f silk.Flow) {
+	jr.record.SetFlow(&f)
+	jr.WriteRecord(&jr.record)
+}
+
+//WriteRecord writes r as one line, it does not allocate
+func (jr *JSONReceiver) WriteRecord(r *silk.Record) error {
+	jr.line = append(jr.line[:0], '{')
+	for x, f := range jr.fields {
+		if x > 0 {
+			jr.line = append(jr.line, ',')
+		}
+		jr.line = append(jr.line, '"')
+		jr.line = append(jr.line, f.Key()...)
+		jr.line = append(jr.line, '"', ':')
+		jr.line = exportValue(jr.line, f, r)
+		if f == FieldProtocol {
+			jr.line = append(jr.line, `,"protocol_name":"`...)
+			jr.line = appendProtocolName(jr.line, r.Proto)
+			jr.line = append(jr.line, '"')
+		}
+	}
+	jr.line = append(jr.line, '}', '\n')
+	return jr.write()
+}
+
+//Close flushes buffered output
+func (jr *JSONReceiver) Close() {
+	jr.close()
+}
+
+//Err returns the first write error
+func (jr *JSONReceiver) Err() error {
+	return jr.err
+}
+
+//CSVOptions selects the fields and layout of a CSVReceiver
+type CSVOptions struct {
+	//Fields to write, AllFields when empty
+	Fields []Field
+	//NoHeader omits the header line of field keys
+	NoHeader bool
+	//LineFeed ends lines with \n instead of the RFC 4180 \r\n
+	LineFeed bool
+}
+
+//CSVReceiver is a silk.FlowReceiver writing flows as RFC 4180 CSV. The
+//header line and values are those of JSONReceiver without JSON quoting,
+//values that don't apply to a flow are empty. The header is written once
+//even when several files are parsed into the receiver. Close flushes the
+//output but does not close the io.Writer, check Err afterwards.
+type CSVReceiver struct {
+	exporter
+	opts   CSVOptions
+	header bool
+	value  []byte
+}
+
+//NewCSVReceiver returns a CSVReceiver writing to w
+func NewCSVReceiver(w io.Writer, opts CSVOptions) *CSVReceiver {
+	return &CSVReceiver{
+		exporter: newExporter(w, opts.Fields),
+		opts:     opts,
+		header:   opts.NoHeader == false,
+	}
+}
+
+//HandleHeader writes the header line
+func (cr *CSVReceiver) HandleHeader(h silk.Header) {
+	cr.writeHeader()
+}
+
+//HandleFlow writes f as one line
+func (cr *CSVReceiver) HandleFlow(f silk.Flow) {
+	cr.record.SetFlow(&f)
+	cr.WriteRecord(&cr.record)
+}
+
+//WriteRecord writes r as one line, it does not allocate
+func (cr *CSVReceiver) WriteRecord(r *silk.Record) error {
+	cr.writeHeader()
+	cr.line = cr.line[:0]
+	for x, f := range cr.fields {
+		if x > 0 {
+			cr.line = append(cr.line, ',')
+		}
+		cr.value = exportValue(cr.value[:0], f, r)
+		cr.line = appendCSVValue(cr.line, cr.value)
+		if f == FieldProtocol {
+			cr.line = append(cr.line, ',')
+			cr.line = appendProtocolName(cr.line, r.Proto)
+		}
+	}
+	cr.endLine()
+	return cr.write()
+}
+
+//Close writes the header if nothing was written and flushes buffered output
+func (cr *CSVReceiver) Close() {
+	cr.writeHeader()
+	cr.close()
+}
+
+//Err returns the first write error
+func (cr *CSVReceiver) Err() error {
+	return cr.err
+}
+
+//writeHeader writes the header line the first time it is called
+func (cr *CSVReceiver) writeHeader() {
+	if cr.header == false {
+		return
+	}
+	cr.header = false
+	cr.line = cr.line[:0]
+	for x, f := range cr.fields {
+		if x > 0 {
+			cr.line = append(cr.line, ',')
+		}
+		cr.line = append(cr.line, f.Key()...)
+		if f == FieldProtocol {
+			cr.line = append(cr.line, ",protocol_name"...)
+		}
+	}
+	cr.endLine()
+	cr.write()
+}
+
+//endLine appends the line ending
+func (cr *CSVReceiver) endLine() {
+	if cr.opts.LineFeed == false {
+		cr.line = append(cr.line, '\r')
+	}
+	cr.line = append(cr.line, '\n')
+}
+
+//appendCSVValue appends a JSON value from exportValue as a CSV field. JSON
+//quotes are removed, null becomes an empty field and values holding a
+//comma, quote or line break are quoted as RFC 4180 requires.
+func appendCSVValue(dst []byte, value []byte) []byte {
+	if string(value) == "null" {
+		return dst
+	}
+	if len(value) >= 2 && value[0] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	var quote bool
+	for _, c := range value {
+		if c == ',' || c == '"' || c == '\r' || c == '\n' {
+			quote = true
+			break
+		}
+	}
+	if quote == false {
+		return append(dst, value...)
+	}
+	dst = append(dst, '"')
+	for _, c := range value {
+		if c == '"' {
+			dst = append(dst, '"')
+		}
+		dst = append(dst, c)
+	}
+	return append(dst, '"')
+}
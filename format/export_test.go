@@ -0,0 +1,175 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chrispassas/silk"
+)
+
+//TestJSONReceiver verifies the keys and values of a JSON line
+func TestJSONReceiver(t *testing.T) {
+	var buf bytes.Buffer
+	var jr = NewJSONReceiver(&buf, JSONOptions{})
+	jr.HandleHeader(silk.Header{})
+	jr.HandleFlow(testFlow)
+	jr.Close()
+	if err := jr.Err(); err != nil {
+		t.Fatalf("JSONReceiver error:%s", err)
+	}
+
+	var found map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &found); err != nil {
+		t.Fatalf("Unmarshal:%s error:%s", buf.String(), err)
+	}
+	var expected = map[string]interface{}{
+		"src_ip":            "10.1.2.3",
+		"dst_ip":            "2001:db8::1",
+		"src_port":          51000.0,
+		"dst_port":          443.0,
+		"protocol":          6.0,
+		"protocol_name":     "TCP",
+		"packets":           10.0,
+		"bytes":             1500.0,
+		"tcp_flags":         "FSPA",
+		"start_time":        "2009-02-13T23:31:30.125Z",
+		"duration_ms":       1500.0,
+		"end_time":          "2009-02-13T23:31:31.625Z",
+		"sensor":            7.0,
+		"snmp_in":           3.0,
+		"snmp_out":          4.0,
+		"next_hop_ip":       nil,
+		"initial_tcp_flags": "S",
+		"session_tcp_flags": "FPA",
+		"attributes":        "TF",
+		"application":       443.0,
+		"icmp_type":         nil,
+		"icmp_code":         nil,
+		"flowtype":          1.0,
+	}
+	if reflect.DeepEqual(found, expected) == false {
+		t.Errorf("JSON:%+v expected:%+v", found, expected)
+	}
+
+	buf.Reset()
+	jr = NewJSONReceiver(&buf, JSONOptions{Fields: []Field{FieldProtocol, FieldIType, FieldICode, FieldDIP}})
+	var icmp = silk.Flow{Proto: 58, DstPort: 128 << 8, DstIP: testFlow.DstIP}
+	jr.HandleFlow(icmp)
+	jr.Close()
+	var line = `{"protocol":58,"protocol_name":"IPv6-ICMP","icmp_type":128,"icmp_code":0,"dst_ip":"2001:db8::1"}` + "\n"
+	if buf.String() != line {
+		t.Errorf("JSON:%s expected:%s", buf.String(), line)
+	}
+}
+
+//TestCSVReceiver verifies the header and values parse as CSV
+func TestCSVReceiver(t *testing.T) {
+	var buf bytes.Buffer
+	var cr = NewCSVReceiver(&buf, CSVOptions{Fields: []Field{FieldSIP, FieldProtocol, FieldFlags, FieldSTime, FieldNhIP, FieldIType}})
+	cr.HandleHeader(silk.Header{})
+	cr.HandleFlow(testFlow)
+	cr.HandleHeader(silk.Header{})
+	cr.HandleFlow(silk.Flow{Proto: 200})
+	cr.Close()
+	if err := cr.Err(); err != nil {
+		t.Fatalf("CSVReceiver error:%s", err)
+	}
+
+	var expected = "" +
+		"src_ip,protocol,protocol_name,tcp_flags,start_time,next_hop_ip,icmp_type\r\n" +
+		"10.1.2.3,6,TCP,FSPA,2009-02-13T23:31:30.125Z,,\r\n" +
+		",200,200,,1970-01-01T00:00:00.000Z,,\r\n"
+	if buf.String() != expected {
+		t.Errorf("CSV:%q expected:%q", buf.String(), expected)
+	}
+	var records [][]string
+	var err error
+	if records, err = csv.NewReader(&buf).ReadAll(); err != nil {
+		t.Fatalf("ReadAll error:%s", err)
+	}
+	if len(records) != 3 || len(records[0]) != 7 {
+		t.Errorf("CSV records:%v", records)
+	}
+
+	buf.Reset()
+	cr = NewCSVReceiver(&buf, CSVOptions{Fields: []Field{FieldSPort}, NoHeader: true, LineFeed: true})
+	cr.HandleFlow(testFlow)
+	cr.Close()
+	if buf.String() != "51000\n" {
+		t.Errorf("CSV:%q expected:%q", buf.String(), "51000\n")
+	}
+
+	if found := string(appendCSVValue(nil, []byte(`"a,"b"`))); found != `"a,""b"` {
+		t.Errorf("appendCSVValue:%s expected:%s", found, `"a,""b"`)
+	}
+}
+
+//TestExportFile verifies every flow of a file is exported as one valid line
+func TestExportFile(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var info silk.FileInfo
+	var err error
+	if info, err = silk.Stat(filePath); err != nil {
+		t.Fatalf("Stat file:%s error:%s", filePath, err)
+	}
+
+	for _, jsonLines := range []bool{true, false} {
+		var f *os.File
+		if f, err = os.Open(filePath); err != nil {
+			t.Fatalf("Open file:%s error:%s", filePath, err)
+		}
+		var pr, pw = io.Pipe()
+		var receiver silk.FlowReceiver = NewCSVReceiver(pw, CSVOptions{})
+		if jsonLines {
+			receiver = NewJSONReceiver(pw, JSONOptions{})
+		}
+		go func() {
+			pw.CloseWithError(silk.Parse(f, receiver))
+		}()
+
+		var lines uint64
+		var scanner = bufio.NewScanner(pr)
+		for scanner.Scan() {
+			if jsonLines && json.Valid(scanner.Bytes()) == false {
+				t.Fatalf("File:%s invalid JSON:%s", filePath, scanner.Text())
+			}
+			if jsonLines == false && strings.Count(scanner.Text(), ",") != len(AllFields) {
+				t.Fatalf("File:%s CSV line:%s expected:%d commas", filePath, scanner.Text(), len(AllFields))
+			}
+			lines++
+		}
+		if err = scanner.Err(); err != nil {
+			t.Errorf("File:%s error:%s", filePath, err)
+		}
+		f.Close()
+		if jsonLines == false {
+			lines--
+		}
+		if lines != info.RecordCount {
+			t.Errorf("File:%s JSON:%t lines:%d expected:%d", filePath, jsonLines, lines, info.RecordCount)
+		}
+	}
+}
+
+//TestExportAllocs verifies writing a record does not allocate
+func TestExportAllocs(t *testing.T) {
+	var r silk.Record
+	r.SetFlow(&testFlow)
+	var jr = NewJSONReceiver(io.Discard, JSONOptions{})
+	var cr = NewCSVReceiver(io.Discard, CSVOptions{})
+	jr.WriteRecord(&r)
+	cr.WriteRecord(&r)
+	if allocs := testing.AllocsPerRun(100, func() { jr.WriteRecord(&r) }); allocs != 0 {
+		t.Errorf("JSONReceiver WriteRecord allocs:%.1f expected:0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { cr.WriteRecord(&r) }); allocs != 0 {
+		t.Errorf("CSVReceiver WriteRecord allocs:%.1f expected:0", allocs)
+	}
+}
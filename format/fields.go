@@ -0,0 +1,196 @@
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chrispassas/silk"
+)
+
+//Field is a Flow field printed by the text writer, see ParseFields
+type Field uint8
+
+//Fields in rwcut's order
+const (
+	FieldSIP Field = iota + 1
+	FieldDIP
+	FieldSPort
+	FieldDPort
+	FieldProtocol
+	FieldPackets
+	FieldBytes
+	FieldFlags
+	FieldSTime
+	FieldDuration
+	FieldETime
+	FieldSensor
+	FieldIn
+	FieldOut
+	FieldNhIP
+	FieldInitialFlags
+	FieldSessionFlags
+	FieldAttributes
+	FieldApplication
+	FieldIType
+	FieldICode
+	FieldFlowType
+)
+
+//DefaultFields are the fields rwcut prints by default
+var DefaultFields = []Field{
+	FieldSIP, FieldDIP, FieldSPort, FieldDPort, FieldProtocol, FieldPackets, FieldBytes,
+	FieldFlags, FieldSTime, FieldDuration, FieldETime, FieldSensor,
+}
+
+//AllFields are every field, the default of the JSON and CSV receivers
+var AllFields = []Field{
+	FieldSIP, FieldDIP, FieldSPort, FieldDPort, FieldProtocol, FieldPackets, FieldBytes,
+	FieldFlags, FieldSTime, FieldDuration, FieldETime, FieldSensor, FieldIn, FieldOut,
+	FieldNhIP, FieldInitialFlags, FieldSessionFlags, FieldAttributes, FieldApplication,
+	FieldIType, FieldICode, FieldFlowType,
+}
+
+//fieldInfo describes a field. number is the rwcut field number, 0 for
+//fields only selected by name. width is the rwcut column width, IP address
+//and time widths depend on their format. key is the JSON and CSV name.
+type fieldInfo struct {
+	name    string
+	aliases []string
+	number  int
+	title   string
+	width   int
+	key     string
+}
+
+//fieldInfos is indexed by Field
+var fieldInfos = [...]fieldInfo{
+	FieldSIP:          {name: "sIP", aliases: []string{"sourceIPv4Address", "sourceIPv6Address"}, number: 1, title: "sIP", key: "src_ip"},
+	FieldDIP:          {name: "dIP", aliases: []string{"destinationIPv4Address", "destinationIPv6Address"}, number: 2, title: "dIP", key: "dst_ip"},
+	FieldSPort:        {name: "sPort", aliases: []string{"sourceTransportPort"}, number: 3, title: "sPort", width: 5, key: "src_port"},
+	FieldDPort:        {name: "dPort", aliases: []string{"destinationTransportPort"}, number: 4, title: "dPort", width: 5, key: "dst_port"},
+	FieldProtocol:     {name: "protocol", aliases: []string{"proto"}, number: 5, title: "pro", width: 3, key: "protocol"},
+	FieldPackets:      {name: "packets", aliases: []string{"pkts"}, number: 6, title: "packets", width: 10, key: "packets"},
+	FieldBytes:        {name: "bytes", number: 7, title: "bytes", width: 10, key: "bytes"},
+	FieldFlags:        {name: "flags", aliases: []string{"tcpControlBits"}, number: 8, title: "flags", width: 8, key: "tcp_flags"},
+	FieldSTime:        {name: "sTime", aliases: []string{"startTime"}, number: 9, title: "sTime", key: "start_time"},
+	FieldDuration:     {name: "duration", aliases: []string{"dur"}, number: 10, title: "duration", width: 9, key: "duration_ms"},
+	FieldETime:        {name: "eTime", aliases: []string{"endTime"}, number: 11, title: "eTime", key: "end_time"},
+	FieldSensor:       {name: "sensor", number: 12, title: "sen", width: 3, key: "sensor"},
+	FieldIn:           {name: "in", aliases: []string{"ingressInterface"}, number: 13, title: "in", width: 5, key: "snmp_in"},
+	FieldOut:          {name: "out", aliases: []string{"egressInterface"}, number: 14, title: "out", width: 5, key: "snmp_out"},
+	FieldNhIP:         {name: "nhIP", aliases: []string{"ipNextHopIPv4Address", "ipNextHopIPv6Address"}, number: 15, title: "nhIP", key: "next_hop_ip"},
+	FieldInitialFlags: {name: "initialFlags", number: 26, title: "initialF", width: 8, key: "initial_tcp_flags"},
+	FieldSessionFlags: {name: "sessionFlags", number: 27, title: "sessionF", width: 8, key: "session_tcp_flags"},
+	FieldAttributes:   {name: "attributes", number: 28, title: "attribut", width: 8, key: "attributes"},
+	FieldApplication:  {name: "application", number: 29, title: "appli", width: 5, key: "application"},
+	FieldIType:        {name: "iType", title: "iTy", width: 3, key: "icmp_type"},
+	FieldICode:        {name: "iCode", title: "iCo", width: 3, key: "icmp_code"},
+	FieldFlowType:     {name: "flowtype", title: "flowtype", width: 8, key: "flowtype"},
+}
+
+//String returns the field name used by ParseFields
+func (f Field) String() string {
+	if f == 0 || int(f) >= len(fieldInfos) {
+		return fmt.Sprintf("Field(%d)", uint8(f))
+	}
+	return fieldInfos[f].name
+}
+
+//Key returns the JSON and CSV name of the field such as src_ip
+func (f Field) Key() string {
+	if f == 0 || int(f) >= len(fieldInfos) {
+		return f.String()
+	}
+	return fieldInfos[f].key
+}
+
+//Title returns the rwcut column title of the field
+func (f Field) Title() string {
+	if f == 0 || int(f) >= len(fieldInfos) {
+		return f.String()
+	}
+	return fieldInfos[f].title
+}
+
+//ParseFields parses a comma separated list of field names, rwcut field
+//numbers and number ranges such as "sIP,dIP,3-5,flags". Names are case
+//insensitive, rwcut column titles and JSON keys are accepted too. flowtype
+//is the numeric class/type id since class and type names require the site's
+//silk.conf.
+func ParseFields(s string) (fields []Field, err error) {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			err = fmt.Errorf("Empty field name in:%q", s)
+			return
+		}
+		var low, high = name, name
+		if i := strings.IndexByte(name, '-'); i > 0 {
+			low, high = name[:i], name[i+1:]
+		}
+		var from, to int
+		var e1, e2 error
+		from, e1 = strconv.Atoi(low)
+		to, e2 = strconv.Atoi(high)
+		if e1 != nil || e2 != nil {
+			var f Field
+			if f, err = lookupField(name); err != nil {
+				return
+			}
+			fields = append(fields, f)
+			continue
+		}
+		if from > to {
+			err = fmt.Errorf("Field range:%s is backwards", name)
+			return
+		}
+		for number := from; number <= to; number++ {
+			var f = fieldByNumber(number)
+			if f == 0 {
+				err = fmt.Errorf("Unknown field number:%d", number)
+				return
+			}
+			fields = append(fields, f)
+		}
+	}
+	return
+}
+
+//lookupField returns the field with name, alias, title or key name
+func lookupField(name string) (f Field, err error) {
+	for x := range fieldInfos {
+		if x == 0 {
+			continue
+		}
+		if strings.EqualFold(fieldInfos[x].name, name) || strings.EqualFold(fieldInfos[x].title, name) || strings.EqualFold(fieldInfos[x].key, name) {
+			return Field(x), nil
+		}
+		for _, alias := range fieldInfos[x].aliases {
+			if strings.EqualFold(alias, name) {
+				return Field(x), nil
+			}
+		}
+	}
+	err = fmt.Errorf("Unknown field:%s", name)
+	return
+}
+
+//fieldByNumber returns the field with rwcut field number, 0 when there is none
+func fieldByNumber(number int) Field {
+	if number == 0 {
+		return 0
+	}
+	for x := range fieldInfos {
+		if x != 0 && fieldInfos[x].number == number {
+			return Field(x)
+		}
+	}
+	return 0
+}
+
+//isICMP returns true for ICMP and ICMPv6 flows, their type and code are
+//stored in the destination port
+func isICMP(r *silk.Record) bool {
+	return r.Proto == 1 || r.Proto == 58
+}
@@ -0,0 +1,607 @@
+package format
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrispassas/silk"
+)
+
+//importColumn is a column of imported text. milliseconds is set for a
+//duration_ms column, other durations are seconds with a fraction.
+type importColumn struct {
+	field        Field
+	milliseconds bool
+}
+
+//lookupColumn returns the column named name, an rwcut field name, title or
+//JSON key. protocol_name written by the JSONReceiver is ignored.
+func lookupColumn(name string) (c importColumn, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, "protocol_name") {
+		return
+	}
+	if c.field, err = lookupField(name); err != nil {
+		return
+	}
+	c.milliseconds = strings.EqualFold(name, FieldDuration.Key())
+	return
+}
+
+//importer builds a record from the column values of one line. End times,
+//ICMP types and codes are kept until every column is set since they depend
+//on the start time and protocol.
+type importer struct {
+	ipFormat    IPFormat
+	record      silk.Record
+	endTimeMS   uint64
+	hasEndTime  bool
+	hasDuration bool
+	icmpType    uint8
+	icmpCode    uint8
+	hasICMP     bool
+}
+
+//reset clears the record before the next line
+func (im *importer) reset() {
+	im.record = silk.Record{}
+	im.hasEndTime = false
+	im.hasDuration = false
+	im.hasICMP = false
+	im.icmpType = 0
+	im.icmpCode = 0
+}
+
+//set parses value into column c of the record, an empty value leaves the field zero
+func (im *importer) set(c importColumn, value string) (err error) {
+	var r = &im.record
+	var n uint64
+
+	value = strings.TrimSpace(value)
+	if c.field == 0 || value == "" {
+		return
+	}
+	switch c.field {
+	case FieldSIP:
+		r.SrcIP, err = parseImportIP(value, im.ipFormat)
+	case FieldDIP:
+		r.DstIP, err = parseImportIP(value, im.ipFormat)
+	case FieldNhIP:
+		r.NextHopIP, err = parseImportIP(value, im.ipFormat)
+	case FieldSPort:
+		n, err = strconv.ParseUint(value, 10, 16)
+		r.SrcPort = uint16(n)
+	case FieldDPort:
+		n, err = strconv.ParseUint(value, 10, 16)
+		r.DstPort = uint16(n)
+	case FieldProtocol:
+		r.Proto, err = parseProtocol(value)
+	case FieldPackets:
+		n, err = strconv.ParseUint(value, 10, 32)
+		r.Packets = uint32(n)
+	case FieldBytes:
+		n, err = strconv.ParseUint(value, 10, 32)
+		r.Bytes = uint32(n)
+	case FieldFlags:
+		r.Flags, err = parseTCPFlags(value)
+	case FieldInitialFlags:
+		r.InitalFlags, err = parseTCPFlags(value)
+	case FieldSessionFlags:
+		r.SessionFlags, err = parseTCPFlags(value)
+	case FieldAttributes:
+		r.Attributes, err = parseAttributes(value)
+	case FieldSTime:
+		r.StartTimeMS, err = ParseTime(value)
+	case FieldETime:
+		im.endTimeMS, err = ParseTime(value)
+		im.hasEndTime = true
+	case FieldDuration:
+		if c.milliseconds {
+			n, err = strconv.ParseUint(value, 10, 32)
+		} else {
+			n, err = parseSeconds(value)
+		}
+		if err == nil && n > 0xFFFFFFFF {
+			err = fmt.Errorf("Duration:%s out of range", value)
+		}
+		r.Duration = uint32(n)
+		im.hasDuration = true
+	case FieldSensor:
+		n, err = strconv.ParseUint(value, 10, 16)
+		r.Sensor = uint16(n)
+	case FieldIn:
+		n, err = strconv.ParseUint(value, 10, 16)
+		r.SNMPIn = uint16(n)
+	case FieldOut:
+		n, err = strconv.ParseUint(value, 10, 16)
+		r.SNMPOut = uint16(n)
+	case FieldApplication:
+		n, err = strconv.ParseUint(value, 10, 16)
+		r.Application = uint16(n)
+	case FieldIType:
+		n, err = strconv.ParseUint(value, 10, 8)
+		im.icmpType = uint8(n)
+		im.hasICMP = true
+	case FieldICode:
+		n, err = strconv.ParseUint(value, 10, 8)
+		im.icmpCode = uint8(n)
+		im.hasICMP = true
+	case FieldFlowType:
+		n, err = strconv.ParseUint(value, 10, 8)
+		r.ClassType = uint8(n)
+	}
+	if err != nil {
+		err = fmt.Errorf("Field:%s value:%q error:%s", c.field, value, err)
+	}
+	return
+}
+
+//finish sets the fields that depend on other columns. Without a duration
+//column the duration is the end time less the start time, ICMP type and
+//code columns replace the destination port of ICMP flows.
+func (im *importer) finish() (err error) {
+	if im.hasEndTime && im.hasDuration == false {
+		if im.endTimeMS < im.record.StartTimeMS || im.endTimeMS-im.record.StartTimeMS > 0xFFFFFFFF {
+			err = fmt.Errorf("End time:%d does not follow start time:%d", im.endTimeMS, im.record.StartTimeMS)
+			return
+		}
+		im.record.Duration = uint32(im.endTimeMS - im.record.StartTimeMS)
+	}
+	if im.hasICMP && isICMP(&im.record) {
+		im.record.DstPort = uint16(im.icmpType)<<8 | uint16(im.icmpCode)
+	}
+	return
+}
+
+//parseImportIP parses an address in IP format f. Canonical addresses may
+//also be zero padded, decimal addresses below 2^32 are IPv4 and
+//hexadecimal addresses are IPv4 when 8 digits long.
+func parseImportIP(value string, f IPFormat) (addr netip.Addr, err error) {
+	switch f {
+	case IPDecimal:
+		var hi, lo uint64
+		for x := 0; x < len(value); x++ {
+			var c = value[x]
+			if c < '0' || c > '9' {
+				err = fmt.Errorf("Invalid decimal IP address")
+				return
+			}
+			var carry, hiLow uint64
+			carry, lo = bits.Mul64(lo, 10)
+			var over uint64
+			over, hiLow = bits.Mul64(hi, 10)
+			var c1, c2 uint64
+			lo, c1 = bits.Add64(lo, uint64(c-'0'), 0)
+			hi, c2 = bits.Add64(hiLow, carry, c1)
+			if over != 0 || c2 != 0 {
+				err = fmt.Errorf("Decimal IP address out of range")
+				return
+			}
+		}
+		if hi == 0 && lo <= 0xFFFFFFFF {
+			return netip.AddrFrom4([4]byte{byte(lo >> 24), byte(lo >> 16), byte(lo >> 8), byte(lo)}), nil
+		}
+		var b [16]byte
+		for x := 0; x < 8; x++ {
+			b[x] = byte(hi >> uint(56-8*x))
+			b[8+x] = byte(lo >> uint(56-8*x))
+		}
+		return netip.AddrFrom16(b), nil
+	case IPHexadecimal:
+		var b [16]byte
+		if len(value) != 8 && len(value) != 32 {
+			err = fmt.Errorf("Hexadecimal IP address must be 8 or 32 digits")
+			return
+		}
+		for x := 0; x < len(value); x += 2 {
+			var n uint64
+			if n, err = strconv.ParseUint(value[x:x+2], 16, 8); err != nil {
+				return
+			}
+			b[x/2] = byte(n)
+		}
+		if len(value) == 8 {
+			return netip.AddrFrom4([4]byte{b[0], b[1], b[2], b[3]}), nil
+		}
+		return netip.AddrFrom16(b), nil
+	}
+
+	if strings.IndexByte(value, ':') < 0 {
+		var parts = strings.Split(value, ".")
+		if len(parts) == 4 {
+			var b [4]byte
+			for x := range parts {
+				var n uint64
+				if n, err = strconv.ParseUint(parts[x], 10, 8); err != nil {
+					err = fmt.Errorf("Invalid IPv4 address")
+					return
+				}
+				b[x] = byte(n)
+			}
+			return netip.AddrFrom4(b), nil
+		}
+	}
+	return netip.ParseAddr(value)
+}
+
+//parseProtocol parses a protocol number or a keyword from ProtocolName
+func parseProtocol(value string) (proto uint8, err error) {
+	var n uint64
+	if n, err = strconv.ParseUint(value, 10, 8); err == nil {
+		return uint8(n), nil
+	}
+	for number, name := range protocolNames {
+		if strings.EqualFold(name, value) {
+			return number, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown protocol")
+}
+
+//parseTCPFlags parses TCP flag letters such as "S A" or the flags as a number
+func parseTCPFlags(value string) (flags uint8, err error) {
+	if value[0] >= '0' && value[0] <= '9' {
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 8)
+		return uint8(n), err
+	}
+	for x := 0; x < len(value); x++ {
+		if value[x] == ' ' {
+			continue
+		}
+		var i = strings.IndexByte(tcpFlagLetters, upper(value[x]))
+		if i < 0 {
+			return 0, fmt.Errorf("Unknown TCP flag:%c", value[x])
+		}
+		flags |= 1 << uint(i)
+	}
+	return
+}
+
+//parseAttributes parses attribute letters such as "TC" or the attributes as a number
+func parseAttributes(value string) (attributes uint8, err error) {
+	if value[0] >= '0' && value[0] <= '9' {
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 8)
+		return uint8(n), err
+	}
+	for x := 0; x < len(value); x++ {
+		if value[x] == ' ' {
+			continue
+		}
+		var found bool
+		for _, a := range attributeLetters {
+			if a.letter == upper(value[x]) {
+				attributes |= a.bit
+				found = true
+			}
+		}
+		if found == false {
+			return 0, fmt.Errorf("Unknown attribute:%c", value[x])
+		}
+	}
+	return
+}
+
+//upper returns the upper case of an ASCII letter
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+//timeLayouts are the layouts of text times, all UTC unless an offset is
+//given. Times may be given to the day, hour or minute like rwcut's
+//YYYY/MM/DD[:HH[:MM[:SS[.sss]]]].
+var timeLayouts = []string{
+	"2006/01/02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006/01/02:15:04:05",
+	"2006/01/02:15:04",
+	"2006/01/02T15:04",
+	"2006/01/02:15",
+	"2006/01/02T15",
+	"2006/01/02",
+}
+
+//ParseTime parses a time in any TimestampFormat, RFC 3339 or rwcut's
+//YYYY/MM/DD[:HH[:MM[:SS[.sss]]]] into milliseconds since the epoch.
+//Integers are epoch milliseconds, except those below 10^11 which are epoch
+//seconds.
+func ParseTime(value string) (ms uint64, err error) {
+	if value == "" {
+		return 0, fmt.Errorf("Empty time")
+	}
+	if value[0] >= '0' && value[0] <= '9' && strings.IndexAny(value, "/-") < 0 {
+		if strings.IndexByte(value, '.') >= 0 {
+			return parseSeconds(value)
+		}
+		if ms, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return
+		}
+		if ms < 1e11 {
+			ms *= 1000
+		}
+		return
+	}
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			if t.Unix() < 0 {
+				return 0, fmt.Errorf("Time before 1970")
+			}
+			return uint64(t.UnixNano() / int64(time.Millisecond)), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown time format")
+}
+
+//parseSeconds parses seconds with an optional fraction into milliseconds,
+//digits past milliseconds are truncated
+func parseSeconds(value string) (ms uint64, err error) {
+	var seconds, fraction = value, ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		seconds, fraction = value[:i], value[i+1:]
+	}
+	if ms, err = strconv.ParseUint(seconds, 10, 64); err != nil {
+		return
+	}
+	if ms > (1<<64-1)/1000 {
+		return 0, fmt.Errorf("Seconds out of range")
+	}
+	ms *= 1000
+	for x, scale := 0, uint64(100); x < len(fraction); x++ {
+		if fraction[x] < '0' || fraction[x] > '9' {
+			return 0, fmt.Errorf("Invalid seconds")
+		}
+		ms += uint64(fraction[x]-'0') * scale
+		scale /= 10
+	}
+	return
+}
+
+//CSVReaderOptions configure a CSVReader. Fields are the columns of input
+//without a title line, when empty the first line names the columns.
+//Delimiter 0 uses whichever of '|', ',' and tab is most common in the first
+//line. IPFormat is how addresses are written, IPCanonical also reads zero
+//padded addresses.
+type CSVReaderOptions struct {
+	Fields    []Field
+	Delimiter byte
+	IPFormat  IPFormat
+}
+
+//CSVReader reads records from rwcut text, the CSVReceiver's CSV or any
+//delimited text whose column titles are field names, rwcut titles or JSON
+//keys. Values may be padded with spaces and an empty final column left by
+//a final delimiter is ignored. Durations are seconds with a fraction unless
+//the column is duration_ms. When there is no duration column it is taken
+//from eTime less sTime.
+type CSVReader struct {
+	opts    CSVReaderOptions
+	br      *bufio.Reader
+	csv     *csv.Reader
+	columns []importColumn
+	titles  int
+	im      importer
+	err     error
+}
+
+//NewCSVReader returns a CSVReader of r
+func NewCSVReader(r io.Reader, opts CSVReaderOptions) *CSVReader {
+	var cr = &CSVReader{opts: opts, br: bufio.NewReader(r)}
+	cr.im.ipFormat = opts.IPFormat
+	for _, f := range opts.Fields {
+		cr.columns = append(cr.columns, importColumn{field: f})
+	}
+	return cr
+}
+
+//Next reads the next record, it returns false at the end of the input or
+//on an error
+func (cr *CSVReader) Next() bool {
+	if cr.err != nil {
+		return false
+	}
+	if cr.csv == nil {
+		if cr.err = cr.start(); cr.err != nil {
+			return false
+		}
+	}
+
+	var values []string
+	for {
+		if values, cr.err = cr.csv.Read(); cr.err != nil {
+			return false
+		}
+		if len(values) > 1 || strings.TrimSpace(values[0]) != "" {
+			break
+		}
+	}
+	var line, _ = cr.csv.FieldPos(0)
+	line += cr.titles
+	if len(values) > len(cr.columns) {
+		var extra = values[len(cr.columns):]
+		if len(extra) > 1 || strings.TrimSpace(extra[0]) != "" {
+			cr.err = fmt.Errorf("Line:%d has %d columns, expected:%d", line, len(values), len(cr.columns))
+			return false
+		}
+	}
+	cr.im.reset()
+	for x := range values {
+		if x == len(cr.columns) {
+			break
+		}
+		if cr.err = cr.im.set(cr.columns[x], values[x]); cr.err != nil {
+			cr.err = fmt.Errorf("Line:%d %s", line, cr.err)
+			return false
+		}
+	}
+	if cr.err = cr.im.finish(); cr.err != nil {
+		cr.err = fmt.Errorf("Line:%d %s", line, cr.err)
+		return false
+	}
+	return true
+}
+
+//start detects the delimiter and reads the column titles
+func (cr *CSVReader) start() (err error) {
+	var first string
+	if first, err = cr.br.ReadString('\n'); err != nil && (err != io.EOF || first == "") {
+		return
+	}
+	err = nil
+
+	var delimiter = rune(cr.opts.Delimiter)
+	if delimiter == 0 {
+		delimiter = '|'
+		var most = strings.Count(first, "|")
+		for _, d := range []string{",", "\t"} {
+			if n := strings.Count(first, d); n > most {
+				delimiter, most = rune(d[0]), n
+			}
+		}
+	}
+	var input io.Reader = io.MultiReader(strings.NewReader(first), cr.br)
+	if len(cr.columns) > 0 {
+		cr.csv = newCSV(input, delimiter)
+		return
+	}
+
+	var titles []string
+	cr.csv = newCSV(strings.NewReader(first), delimiter)
+	if titles, err = cr.csv.Read(); err != nil {
+		return
+	}
+	for x, title := range titles {
+		var c importColumn
+		if c, err = lookupColumn(title); err != nil {
+			return
+		}
+		if c.field == 0 && x == len(titles)-1 && strings.TrimSpace(title) == "" {
+			break
+		}
+		cr.columns = append(cr.columns, c)
+	}
+	cr.csv = newCSV(cr.br, delimiter)
+	cr.titles = 1
+	return
+}
+
+//newCSV returns a csv.Reader of delimited text, values are trimmed by
+//importer.set since TrimLeadingSpace would also trim tab delimiters
+func newCSV(r io.Reader, delimiter rune) *csv.Reader {
+	var c = csv.NewReader(r)
+	c.Comma = delimiter
+	c.FieldsPerRecord = -1
+	c.LazyQuotes = true
+	c.ReuseRecord = true
+	return c
+}
+
+//Record returns the record read by Next, it is overwritten by the next call to Next
+func (cr *CSVReader) Record() *silk.Record {
+	return &cr.im.record
+}
+
+//Err returns the error that stopped Next, nil at the end of the input
+func (cr *CSVReader) Err() error {
+	if cr.err == io.EOF {
+		return nil
+	}
+	return cr.err
+}
+
+//JSONReader reads records from JSON Lines written by the JSONReceiver or
+//any stream of JSON objects whose keys are field names, rwcut titles or
+//JSON keys. Values may be strings, numbers or null.
+type JSONReader struct {
+	decoder *json.Decoder
+	columns map[string]importColumn
+	values  map[string]interface{}
+	line    int
+	im      importer
+	err     error
+}
+
+//NewJSONReader returns a JSONReader of r, addresses are read in IP format f
+func NewJSONReader(r io.Reader, f IPFormat) *JSONReader {
+	var jr = &JSONReader{decoder: json.NewDecoder(r), columns: map[string]importColumn{}}
+	jr.decoder.UseNumber()
+	jr.im.ipFormat = f
+	return jr
+}
+
+//Next reads the next record, it returns false at the end of the input or
+//on an error
+func (jr *JSONReader) Next() bool {
+	if jr.err != nil {
+		return false
+	}
+	for key := range jr.values {
+		delete(jr.values, key)
+	}
+	jr.line++
+	if jr.err = jr.decoder.Decode(&jr.values); jr.err != nil {
+		if jr.err != io.EOF {
+			jr.err = fmt.Errorf("Object:%d %s", jr.line, jr.err)
+		}
+		return false
+	}
+
+	jr.im.reset()
+	for key, value := range jr.values {
+		var c, ok = jr.columns[key]
+		if ok == false {
+			if c, jr.err = lookupColumn(key); jr.err != nil {
+				jr.err = fmt.Errorf("Object:%d %s", jr.line, jr.err)
+				return false
+			}
+			jr.columns[key] = c
+		}
+		var text string
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			text = v
+		case json.Number:
+			text = v.String()
+		default:
+			jr.err = fmt.Errorf("Object:%d key:%s has unsupported value:%v", jr.line, key, value)
+			return false
+		}
+		if jr.err = jr.im.set(c, text); jr.err != nil {
+			jr.err = fmt.Errorf("Object:%d %s", jr.line, jr.err)
+			return false
+		}
+	}
+	if jr.err = jr.im.finish(); jr.err != nil {
+		jr.err = fmt.Errorf("Object:%d %s", jr.line, jr.err)
+		return false
+	}
+	return true
+}
+
+//Record returns the record read by Next, it is overwritten by the next call to Next
+func (jr *JSONReader) Record() *silk.Record {
+	return &jr.im.record
+}
+
+//Err returns the error that stopped Next, nil at the end of the input
+func (jr *JSONReader) Err() error {
+	if jr.err == io.EOF {
+		return nil
+	}
+	return jr.err
+}
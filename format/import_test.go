@@ -0,0 +1,271 @@
+package format
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/internal/silktest"
+)
+
+//TestImportRoundTrip verifies records exported as text, CSV and JSON Lines are imported unchanged
+func TestImportRoundTrip(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var records = silktest.ReadRecords(t, filePath)
+	if len(records) > 20000 {
+		records = records[:20000]
+	}
+	var textFields = []Field{
+		FieldSIP, FieldDIP, FieldSPort, FieldDPort, FieldProtocol, FieldPackets, FieldBytes,
+		FieldFlags, FieldSTime, FieldDuration, FieldSensor, FieldIn, FieldOut, FieldNhIP,
+		FieldInitialFlags, FieldSessionFlags, FieldAttributes, FieldApplication, FieldFlowType,
+	}
+
+	var tests = []struct {
+		name  string
+		write func(buf *bytes.Buffer)
+		read  func(buf *bytes.Buffer) recordReader
+	}{
+		{
+			name: "text",
+			write: func(buf *bytes.Buffer) {
+				var tw = NewTextWriter(buf, TextOptions{Fields: textFields})
+				for x := range records {
+					tw.WriteRecord(&records[x])
+				}
+				tw.Flush()
+			},
+			read: func(buf *bytes.Buffer) recordReader {
+				return NewCSVReader(buf, CSVReaderOptions{})
+			},
+		},
+		{
+			name: "text without titles",
+			write: func(buf *bytes.Buffer) {
+				var tw = NewTextWriter(buf, TextOptions{Fields: textFields, NoTitles: true, NoColumns: true, Delimiter: '\t',
+					TimestampFormat: TimestampEpoch, IPFormat: IPDecimal})
+				for x := range records {
+					tw.WriteRecord(&records[x])
+				}
+				tw.Flush()
+			},
+			read: func(buf *bytes.Buffer) recordReader {
+				return NewCSVReader(buf, CSVReaderOptions{Fields: textFields, Delimiter: '\t', IPFormat: IPDecimal})
+			},
+		},
+		{
+			name: "csv",
+			write: func(buf *bytes.Buffer) {
+				var cr = NewCSVReceiver(buf, CSVOptions{})
+				for x := range records {
+					cr.WriteRecord(&records[x])
+				}
+				cr.Close()
+			},
+			read: func(buf *bytes.Buffer) recordReader {
+				return NewCSVReader(buf, CSVReaderOptions{})
+			},
+		},
+		{
+			name: "json",
+			write: func(buf *bytes.Buffer) {
+				var jr = NewJSONReceiver(buf, JSONOptions{})
+				for x := range records {
+					jr.WriteRecord(&records[x])
+				}
+				jr.Close()
+			},
+			read: func(buf *bytes.Buffer) recordReader {
+				return NewJSONReader(buf, IPCanonical)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		test.write(&buf)
+		var rr = test.read(&buf)
+		var n int
+		for rr.Next() {
+			if n >= len(records) {
+				t.Fatalf("File:%s %s read more than:%d records", filePath, test.name, len(records))
+			}
+			var expected = records[n]
+			if expected.NextHopIP.IsValid() == false {
+				expected.NextHopIP = rr.Record().NextHopIP
+			}
+			if *rr.Record() != expected {
+				t.Errorf("File:%s %s record:%d imported:%+v expected:%+v", filePath, test.name, n, *rr.Record(), expected)
+				break
+			}
+			n++
+		}
+		if err := rr.Err(); err != nil {
+			t.Errorf("File:%s %s error:%s", filePath, test.name, err)
+		}
+		if n != len(records) {
+			t.Errorf("File:%s %s records:%d expected:%d", filePath, test.name, n, len(records))
+		}
+	}
+}
+
+//recordReader is the CSVReader and JSONReader
+type recordReader interface {
+	Next() bool
+	Record() *silk.Record
+	Err() error
+}
+
+//TestImportValues verifies the value forms and derived fields of imported text
+func TestImportValues(t *testing.T) {
+	var input = strings.Join([]string{
+		"sIP,dIP,proto,flags,sTime,eTime,iType,iCode,attributes,nhIP",
+		"010.001.002.003,2001:0db8:0000:0000:0000:0000:0000:0001,icmp,,2009-02-13 23:31:30.125,2009-02-13T23:31:31.625Z,3,1,tc,",
+		"10.1.2.3,10.1.2.4,TCP,s a,1234567890.5,1234567891000,,,64,::ffff:10.0.0.1",
+	}, "\n")
+	var expected = []silk.Record{
+		{
+			SrcIP:       netip.MustParseAddr("10.1.2.3"),
+			DstIP:       netip.MustParseAddr("2001:db8::1"),
+			Proto:       1,
+			StartTimeMS: 1234567890125,
+			Duration:    1500,
+			DstPort:     0x0301,
+			Attributes:  0x60,
+		},
+		{
+			SrcIP:       netip.MustParseAddr("10.1.2.3"),
+			DstIP:       netip.MustParseAddr("10.1.2.4"),
+			Proto:       6,
+			Flags:       0x12,
+			StartTimeMS: 1234567890500,
+			Duration:    500,
+			Attributes:  0x40,
+			NextHopIP:   netip.MustParseAddr("::ffff:10.0.0.1"),
+		},
+	}
+
+	var cr = NewCSVReader(strings.NewReader(input), CSVReaderOptions{})
+	var n int
+	for cr.Next() {
+		if n < len(expected) && *cr.Record() != expected[n] {
+			t.Errorf("Record:%d imported:%+v expected:%+v", n, *cr.Record(), expected[n])
+		}
+		n++
+	}
+	if err := cr.Err(); err != nil {
+		t.Errorf("CSVReader error:%s", err)
+	}
+	if n != len(expected) {
+		t.Errorf("Records:%d expected:%d", n, len(expected))
+	}
+
+	var jr = NewJSONReader(strings.NewReader(`{"sip":"10.1.2.3","duration":"1.5","pkts":2,"protocol_name":"TCP","dport":null}`), IPCanonical)
+	if jr.Next() == false {
+		t.Fatalf("JSONReader error:%s", jr.Err())
+	}
+	var found = *jr.Record()
+	if found.SrcIP != netip.MustParseAddr("10.1.2.3") || found.Duration != 1500 || found.Packets != 2 {
+		t.Errorf("JSON record:%+v", found)
+	}
+}
+
+//TestImportErrors verifies invalid input is rejected with its line
+func TestImportErrors(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{input: "sIP,bogus\n10.0.0.1,1\n", expected: "Unknown field:bogus"},
+		{input: "sIP,dIP\n10.0.0.1,10.0.0.2\n10.0.0.1,300.0.0.2\n", expected: "Line:3"},
+		{input: "sPort\n65536\n", expected: "Field:sPort"},
+		{input: "sTime,eTime\n2009/02/13T23:31:30,2009/02/13T23:31:29\n", expected: "End time"},
+		{input: "flags\nSX\n", expected: "Unknown TCP flag:X"},
+		{input: "sIP,dIP\n10.0.0.1,10.0.0.2,10\n", expected: "columns"},
+		{input: "proto\nbogus\n", expected: "Unknown protocol"},
+	}
+	for _, test := range tests {
+		var cr = NewCSVReader(strings.NewReader(test.input), CSVReaderOptions{})
+		for cr.Next() {
+		}
+		if err := cr.Err(); err == nil || strings.Contains(err.Error(), test.expected) == false {
+			t.Errorf("Input:%q error:%v expected:%s", test.input, err, test.expected)
+		}
+	}
+
+	var jr = NewJSONReader(strings.NewReader("{\"sPort\":1}\n{\"sPort\":true}\n"), IPCanonical)
+	for jr.Next() {
+	}
+	if err := jr.Err(); err == nil || strings.HasPrefix(err.Error(), "Object:2") == false {
+		t.Errorf("JSONReader error:%v expected:Object:2", err)
+	}
+}
+
+//TestImportWrite verifies imported records are written to and read back from a silk file
+func TestImportWrite(t *testing.T) {
+	var input = "sIP|dIP|sPort|dPort|pro|packets|bytes|flags|sTime|duration|\n" +
+		"10.1.2.3|2001:db8::1|51000|443|6|10|1500|FS PA|2009/02/13T23:31:30.125|1.500|\n"
+	for _, recordFormat := range []uint8{silk.FormatRWIPV6Routing, silk.FormatRWIPV6} {
+		var buf bytes.Buffer
+		var sw, err = silk.NewWriter(&buf, silk.Header{RecordFormat: recordFormat, RecordVersion: 1})
+		if err != nil {
+			t.Fatalf("NewWriter format:%d error:%s", recordFormat, err)
+		}
+		var cr = NewCSVReader(strings.NewReader(input), CSVReaderOptions{})
+		for cr.Next() {
+			if err = sw.WriteRecord(cr.Record()); err != nil {
+				t.Fatalf("WriteRecord format:%d error:%s", recordFormat, err)
+			}
+		}
+		if err = cr.Err(); err != nil {
+			t.Fatalf("CSVReader error:%s", err)
+		}
+		if err = sw.Close(); err != nil {
+			t.Fatalf("Close format:%d error:%s", recordFormat, err)
+		}
+
+		var sr *silk.Reader
+		if sr, err = silk.NewReader(&buf); err != nil {
+			t.Fatalf("NewReader format:%d error:%s", recordFormat, err)
+		}
+		if sr.Next() == false {
+			t.Fatalf("Format:%d no records error:%v", recordFormat, sr.Err())
+		}
+		var f = sr.Flow()
+		if f.StartTimeMS != testFlow.StartTimeMS || f.Duration != testFlow.Duration || f.Flags != testFlow.Flags ||
+			f.DstIP.Equal(testFlow.DstIP) == false || f.SrcIP.Equal(testFlow.SrcIP) == false || f.Bytes != testFlow.Bytes {
+			t.Errorf("Format:%d flow:%+v expected:%+v", recordFormat, *f, testFlow)
+		}
+	}
+}
+
+//TestParseTime verifies the accepted time forms
+func TestParseTime(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected uint64
+	}{
+		{"2009/02/13T23:31:30.125", 1234567890125},
+		{"2009-02-13 23:31:30.125", 1234567890125},
+		{"2009-02-13T23:31:30.125Z", 1234567890125},
+		{"2009-02-14T00:31:30.125+01:00", 1234567890125},
+		{"2009/02/13:23:31:30", 1234567890000},
+		{"2009/02/13:23", 1234566000000},
+		{"2009/02/13", 1234483200000},
+		{"1234567890.125", 1234567890125},
+		{"1234567890", 1234567890000},
+		{"1234567890125", 1234567890125},
+	}
+	for _, test := range tests {
+		if found, err := ParseTime(test.value); err != nil || found != test.expected {
+			t.Errorf("Time:%s parsed:%d error:%v expected:%d", test.value, found, err, test.expected)
+		}
+	}
+	for _, value := range []string{"", "2009/13/01", "yesterday", "1969-12-31 23:59:59"} {
+		if _, err := ParseTime(value); err == nil {
+			t.Errorf("Time:%q should fail", value)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Package format writes silk flows as text in the layouts of the SiLK tools,
+as JSON Lines and as CSV.
+
+TextWriter prints the columns of rwcut:
+
+	tw := format.NewTextWriter(os.Stdout, format.TextOptions{})
+	for sr.Next() {
+		if err := tw.WriteRecord(sr.Record()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+JSONReceiver and CSVReceiver are silk.FlowReceivers that stream each flow
+to an io.Writer as it is parsed:
+
+	jr := format.NewJSONReceiver(os.Stdout, format.JSONOptions{})
+	if err := silk.Parse(f, jr); err != nil {
+		log.Fatal(err)
+	}
+	if err := jr.Err(); err != nil {
+		log.Fatal(err)
+	}
+*/
+package format
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/chrispassas/silk"
+)
+
+//TextOptions selects the fields and layout of a TextWriter. The zero value
+//prints DefaultFields in rwcut's default layout.
+type TextOptions struct {
+	//Fields to print, DefaultFields when both Fields and Columns are empty
+	Fields []Field
+	//NoTitles omits the title line
+	NoTitles bool
+	//NoColumns prints values without padding them to the column width
+	NoColumns bool
+	//Delimiter separates columns, '|' when 0
+	Delimiter byte
+	//NoFinalDelimiter omits the delimiter after the last column
+	NoFinalDelimiter bool
+	TimestampFormat  TimestampFormat
+	IPFormat         IPFormat
+	//Columns are printed after Fields, their values are passed to WriteRecordValues
+	Columns []Column
+}
+
+//Column is a column of values that aren't record fields, such as the
+//totals printed by rwuniq after its key fields
+type Column struct {
+	Title string
+	Width int
+}
+
+//TextWriter writes records as delimited text columns like rwcut. Output is
+//buffered, Flush must be called once all records are written.
+type TextWriter struct {
+	w      *bufio.Writer
+	opts   TextOptions
+	widths []int
+	line   []byte
+	value  []byte
+	record silk.Record
+	titles bool
+	err    error
+}
+
+//NewTextWriter returns a TextWriter writing to w
+func NewTextWriter(w io.Writer, opts TextOptions) *TextWriter {
+	if len(opts.Fields) == 0 && len(opts.Columns) == 0 {
+		opts.Fields = DefaultFields
+	}
+	if opts.Delimiter == 0 {
+		opts.Delimiter = '|'
+	}
+	var tw = &TextWriter{
+		w:      bufio.NewWriter(w),
+		opts:   opts,
+		widths: make([]int, len(opts.Fields), len(opts.Fields)+len(opts.Columns)),
+		titles: opts.NoTitles == false,
+	}
+	for x, f := range opts.Fields {
+		switch f {
+		case FieldSIP, FieldDIP, FieldNhIP:
+			tw.widths[x] = opts.IPFormat.width()
+		case FieldSTime, FieldETime:
+			tw.widths[x] = opts.TimestampFormat.width()
+		default:
+			if int(f) < len(fieldInfos) {
+				tw.widths[x] = fieldInfos[f].width
+			}
+		}
+	}
+	for _, c := range opts.Columns {
+		tw.widths = append(tw.widths, c.Width)
+	}
+	return tw
+}
+
+//WriteFlow writes f as one line
+func (tw *TextWriter) WriteFlow(f *silk.Flow) error {
+	tw.record.SetFlow(f)
+	return tw.WriteRecord(&tw.record)
+}
+
+//WriteRecord writes r as one line, it does not allocate. Columns are left empty.
+func (tw *TextWriter) WriteRecord(r *silk.Record) error {
+	return tw.WriteRecordValues(r)
+}
+
+//WriteRecordValues writes the fields of r followed by values, the values of
+//Columns in order. Missing values are left empty.
+func (tw *TextWriter) WriteRecordValues(r *silk.Record, values ...[]byte) error {
+	if tw.err != nil {
+		return tw.err
+	}
+	if tw.titles {
+		tw.titles = false
+		tw.writeTitles()
+	}
+	tw.line = tw.line[:0]
+	for x, f := range tw.opts.Fields {
+		tw.value = tw.appendValue(tw.value[:0], f, r)
+		tw.appendColumn(x, tw.value)
+	}
+	for x := range tw.opts.Columns {
+		var value []byte
+		if x < len(values) {
+			value = values[x]
+		}
+		tw.appendColumn(len(tw.opts.Fields)+x, value)
+	}
+	tw.endLine()
+	return tw.err
+}
+
+//WriteValues writes values, the values of Columns in order, as one line.
+//It is used without Fields for lines that aren't records, such as the bins
+//printed by rwcount and rwtotal.
+func (tw *TextWriter) WriteValues(values ...[]byte) error {
+	return tw.WriteRecordValues(&tw.record, values...)
+}
+
+//Flush writes the title line if no records were written and any buffered
+//output to the underlying io.Writer
+func (tw *TextWriter) Flush() error {
+	if tw.err != nil {
+		return tw.err
+	}
+	if tw.titles {
+		tw.titles = false
+		tw.writeTitles()
+	}
+	if tw.err == nil {
+		tw.err = tw.w.Flush()
+	}
+	return tw.err
+}
+
+//writeTitles writes the title line
+func (tw *TextWriter) writeTitles() {
+	tw.line = tw.line[:0]
+	for x, f := range tw.opts.Fields {
+		tw.appendColumn(x, []byte(f.Title()))
+	}
+	for x, c := range tw.opts.Columns {
+		tw.appendColumn(len(tw.opts.Fields)+x, []byte(c.Title))
+	}
+	tw.endLine()
+}
+
+//appendColumn appends value right aligned to the width of column x
+//followed by the delimiter
+func (tw *TextWriter) appendColumn(x int, value []byte) {
+	if x > 0 {
+		tw.line = append(tw.line, tw.opts.Delimiter)
+	}
+	if tw.opts.NoColumns == false {
+		for pad := tw.widths[x] - len(value); pad > 0; pad-- {
+			tw.line = append(tw.line, ' ')
+		}
+	}
+	tw.line = append(tw.line, value...)
+}
+
+//endLine writes the line with its final delimiter
+func (tw *TextWriter) endLine() {
+	if tw.opts.NoFinalDelimiter == false {
+		tw.line = append(tw.line, tw.opts.Delimiter)
+	}
+	tw.line = append(tw.line, '\n')
+	_, tw.err = tw.w.Write(tw.line)
+}
+
+//appendValue appends the text of field f of r
+func (tw *TextWriter) appendValue(dst []byte, f Field, r *silk.Record) []byte {
+	var padded = tw.opts.NoColumns == false
+	switch f {
+	case FieldSIP:
+		return tw.opts.IPFormat.appendIP(dst, r.SrcIP)
+	case FieldDIP:
+		return tw.opts.IPFormat.appendIP(dst, r.DstIP)
+	case FieldSPort:
+		return strconv.AppendUint(dst, uint64(r.SrcPort), 10)
+	case FieldDPort:
+		return strconv.AppendUint(dst, uint64(r.DstPort), 10)
+	case FieldProtocol:
+		return strconv.AppendUint(dst, uint64(r.Proto), 10)
+	case FieldPackets:
+		return strconv.AppendUint(dst, uint64(r.Packets), 10)
+	case FieldBytes:
+		return strconv.AppendUint(dst, uint64(r.Bytes), 10)
+	case FieldFlags:
+		return appendTCPFlags(dst, r.Flags, padded)
+	case FieldSTime:
+		return tw.opts.TimestampFormat.AppendTime(dst, r.StartTimeMS)
+	case FieldDuration:
+		dst = strconv.AppendUint(dst, uint64(r.Duration/1000), 10)
+		return appendMillis(dst, uint64(r.Duration%1000))
+	case FieldETime:
+		return tw.opts.TimestampFormat.AppendTime(dst, r.StartTimeMS+uint64(r.Duration))
+	case FieldSensor:
+		return strconv.AppendUint(dst, uint64(r.Sensor), 10)
+	case FieldIn:
+		return strconv.AppendUint(dst, uint64(r.SNMPIn), 10)
+	case FieldOut:
+		return strconv.AppendUint(dst, uint64(r.SNMPOut), 10)
+	case FieldNhIP:
+		return tw.opts.IPFormat.appendIP(dst, r.NextHopIP)
+	case FieldInitialFlags:
+		return appendTCPFlags(dst, r.InitalFlags, padded)
+	case FieldSessionFlags:
+		return appendTCPFlags(dst, r.SessionFlags, padded)
+	case FieldAttributes:
+		return appendAttributes(dst, r.Attributes, padded)
+	case FieldApplication:
+		return strconv.AppendUint(dst, uint64(r.Application), 10)
+	case FieldIType:
+		if isICMP(r) {
+			dst = strconv.AppendUint(dst, uint64(r.DstPort>>8), 10)
+		}
+		return dst
+	case FieldICode:
+		if isICMP(r) {
+			dst = strconv.AppendUint(dst, uint64(r.DstPort&0xFF), 10)
+		}
+		return dst
+	case FieldFlowType:
+		return strconv.AppendUint(dst, uint64(r.ClassType), 10)
+	}
+	return dst
+}
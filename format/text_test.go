@@ -0,0 +1,228 @@
+package format
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chrispassas/silk"
+)
+
+//testFlow is a TCP flow, 2009/02/13 23:31:30.125 UTC for 1.5 seconds
+var testFlow = silk.Flow{
+	StartTimeMS:  1234567890125,
+	Duration:     1500,
+	SrcIP:        net.ParseIP("10.1.2.3"),
+	DstIP:        net.ParseIP("2001:db8::1"),
+	SrcPort:      51000,
+	DstPort:      443,
+	Proto:        6,
+	Flags:        0x1B,
+	Packets:      10,
+	Bytes:        1500,
+	ClassType:    1,
+	Sensor:       7,
+	InitalFlags:  0x02,
+	SessionFlags: 0x19,
+	Attributes:   0x28,
+	Application:  443,
+	SNMPIn:       3,
+	SNMPOut:      4,
+}
+
+//TestTextWriter verifies the rwcut layout and its options
+func TestTextWriter(t *testing.T) {
+	var tests = []struct {
+		opts     TextOptions
+		expected string
+	}{
+		{
+			opts: TextOptions{},
+			expected: "" +
+				"                                    sIP|                                    dIP|sPort|dPort|pro|   packets|     bytes|   flags|                  sTime| duration|                  eTime|sen|\n" +
+				"                               10.1.2.3|                            2001:db8::1|51000|  443|  6|        10|      1500|FS PA   |2009/02/13T23:31:30.125|    1.500|2009/02/13T23:31:31.625|  7|\n",
+		},
+		{
+			opts: TextOptions{
+				Fields:           []Field{FieldSIP, FieldSTime, FieldDuration, FieldFlags, FieldAttributes, FieldNhIP},
+				NoColumns:        true,
+				NoFinalDelimiter: true,
+				Delimiter:        ',',
+				TimestampFormat:  TimestampISO,
+			},
+			expected: "" +
+				"sIP,sTime,duration,flags,attribut,nhIP\n" +
+				"10.1.2.3,2009-02-13 23:31:30.125,1.500,FSPA,TF,0.0.0.0\n",
+		},
+		{
+			opts: TextOptions{
+				Fields:          []Field{FieldDIP, FieldSTime, FieldETime, FieldInitialFlags, FieldSessionFlags, FieldIType, FieldFlowType},
+				NoTitles:        true,
+				NoColumns:       true,
+				TimestampFormat: TimestampEpoch,
+				IPFormat:        IPHexadecimal,
+			},
+			expected: "20010db8000000000000000000000001|1234567890.125|1234567891.625|S|FPA||1|\n",
+		},
+		{
+			opts: TextOptions{
+				Fields:          []Field{FieldSIP, FieldSTime, FieldIn, FieldOut, FieldApplication},
+				TimestampFormat: TimestampEpochMS,
+				IPFormat:        IPZeroPadded,
+			},
+			expected: "" +
+				"                                    sIP|        sTime|   in|  out|appli|\n" +
+				"                        010.001.002.003|1234567890125|    3|    4|  443|\n",
+		},
+	}
+
+	for x, test := range tests {
+		var buf bytes.Buffer
+		var tw = NewTextWriter(&buf, test.opts)
+		if err := tw.WriteFlow(&testFlow); err != nil {
+			t.Errorf("Test:%d WriteFlow error:%s", x, err)
+		}
+		if err := tw.Flush(); err != nil {
+			t.Errorf("Test:%d Flush error:%s", x, err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("Test:%d output:\n%s expected:\n%s", x, buf.String(), test.expected)
+		}
+	}
+
+	//Titles are written even when there are no records
+	var buf bytes.Buffer
+	var tw = NewTextWriter(&buf, TextOptions{Fields: []Field{FieldSPort, FieldDPort}})
+	if err := tw.Flush(); err != nil || buf.String() != "sPort|dPort|\n" {
+		t.Errorf("Empty output:%q error:%v", buf.String(), err)
+	}
+	//Columns follow the fields
+	buf.Reset()
+	tw = NewTextWriter(&buf, TextOptions{Fields: []Field{FieldSPort, FieldProtocol}, Columns: []Column{{Title: "Records", Width: 8}, {Title: "Bytes", Width: 6}}})
+	var r silk.Record
+	r.SetFlow(&testFlow)
+	tw.WriteRecordValues(&r, []byte("12"), []byte("3400"))
+	tw.WriteRecord(&r)
+	var expected = "sPort|pro| Records| Bytes|\n51000|  6|      12|  3400|\n51000|  6|        |      |\n"
+	if err := tw.Flush(); err != nil || buf.String() != expected {
+		t.Errorf("Columns output:\n%s error:%v expected:\n%s", buf.String(), err, expected)
+	}
+	//Columns without fields
+	buf.Reset()
+	tw = NewTextWriter(&buf, TextOptions{Columns: []Column{{Title: "Date", Width: 6}, {Title: "Records", Width: 8}}, NoFinalDelimiter: true, Delimiter: ','})
+	tw.WriteValues([]byte("1"), []byte("2"))
+	expected = "  Date, Records\n     1,       2\n"
+	if err := tw.Flush(); err != nil || buf.String() != expected {
+		t.Errorf("Values output:\n%s error:%v expected:\n%s", buf.String(), err, expected)
+	}
+}
+
+//TestAttributes verifies the attribute letters against fixed tcp_state values
+//using the bits from SiLK's rwrec.h, in both directions
+func TestAttributes(t *testing.T) {
+	var tests = []struct {
+		state    uint8
+		expected string
+	}{
+		{0x08, "F"}, //SK_TCPSTATE_FIN_FOLLOWED_NOT_ACK
+		{0x10, "S"}, //SK_TCPSTATE_UNIFORM_PACKET_SIZE
+		{0x20, "T"}, //SK_TCPSTATE_TIMEOUT_KILLED
+		{0x40, "C"}, //SK_TCPSTATE_TIMEOUT_STARTED
+		{0x79, "TCFS"},
+		{0x01, ""},
+	}
+	for _, test := range tests {
+		if found := string(appendAttributes(nil, test.state, false)); found != test.expected {
+			t.Errorf("tcp_state:%#x attributes:%q expected:%q", test.state, found, test.expected)
+		}
+		if test.expected == "" {
+			continue
+		}
+		var state, err = parseAttributes(test.expected)
+		if err != nil {
+			t.Errorf("parseAttributes value:%q error:%s", test.expected, err)
+		} else if state != test.state&^0x01 {
+			t.Errorf("parseAttributes value:%q tcp_state:%#x expected:%#x", test.expected, state, test.state&^0x01)
+		}
+	}
+}
+
+//TestIPFormats verifies every IP format for IPv4 and IPv6 addresses
+func TestIPFormats(t *testing.T) {
+	var tests = []struct {
+		ip       string
+		format   IPFormat
+		expected string
+	}{
+		{"10.1.2.3", IPCanonical, "10.1.2.3"},
+		{"10.1.2.3", IPDecimal, "167838211"},
+		{"10.1.2.3", IPHexadecimal, "0a010203"},
+		{"10.1.2.3", IPZeroPadded, "010.001.002.003"},
+		{"2001:db8::1", IPCanonical, "2001:db8::1"},
+		{"2001:db8::1", IPDecimal, "42540766411282592856903984951653826561"},
+		{"::1", IPDecimal, "1"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", IPDecimal, "340282366920938463463374607431768211455"},
+		{"2001:db8::1", IPHexadecimal, "20010db8000000000000000000000001"},
+		{"2001:db8::1", IPZeroPadded, "2001:0db8:0000:0000:0000:0000:0000:0001"},
+	}
+	for _, test := range tests {
+		if found := string(test.format.appendIP(nil, netip.MustParseAddr(test.ip))); found != test.expected {
+			t.Errorf("IP:%s format:%s found:%s expected:%s", test.ip, test.format, found, test.expected)
+		}
+	}
+}
+
+//TestParseFields verifies field names, numbers and ranges
+func TestParseFields(t *testing.T) {
+	var tests = []struct {
+		s        string
+		expected []Field
+	}{
+		{"1-12", DefaultFields},
+		{"sip,DIP,proto,5", []Field{FieldSIP, FieldDIP, FieldProtocol, FieldProtocol}},
+		{"13-15,26-29", []Field{FieldIn, FieldOut, FieldNhIP, FieldInitialFlags, FieldSessionFlags, FieldAttributes, FieldApplication}},
+		{"iType, iCode,flowtype", []Field{FieldIType, FieldICode, FieldFlowType}},
+	}
+	for _, test := range tests {
+		fields, err := ParseFields(test.s)
+		if err != nil {
+			t.Errorf("ParseFields:%q error:%s", test.s, err)
+			continue
+		}
+		if reflect.DeepEqual(fields, test.expected) == false {
+			t.Errorf("ParseFields:%q fields:%v expected:%v", test.s, fields, test.expected)
+		}
+	}
+	for _, s := range []string{"", "sIP,,dIP", "16", "5-3", "0-2", "class"} {
+		if _, err := ParseFields(s); err == nil {
+			t.Errorf("ParseFields:%q expected error", s)
+		}
+	}
+	for _, name := range []string{"default", "iso", "epoch", "epoch-ms"} {
+		if tf, err := ParseTimestampFormat(name); err != nil || tf.String() != name {
+			t.Errorf("ParseTimestampFormat:%s found:%s error:%v", name, tf, err)
+		}
+	}
+	if _, err := ParseIPFormat("octal"); err == nil || strings.Contains(err.Error(), "octal") == false {
+		t.Errorf("ParseIPFormat:octal error:%v", err)
+	}
+}
+
+//TestWriteRecordAllocs verifies writing a record does not allocate
+func TestWriteRecordAllocs(t *testing.T) {
+	var r silk.Record
+	r.SetFlow(&testFlow)
+	var fields = make([]Field, 0, len(fieldInfos))
+	for x := 1; x < len(fieldInfos); x++ {
+		fields = append(fields, Field(x))
+	}
+	var tw = NewTextWriter(io.Discard, TextOptions{Fields: fields, TimestampFormat: TimestampISO, IPFormat: IPDecimal})
+	tw.WriteRecord(&r)
+	if allocs := testing.AllocsPerRun(100, func() { tw.WriteRecord(&r) }); allocs != 0 {
+		t.Errorf("WriteRecord allocs:%.1f expected:0", allocs)
+	}
+}
@@ -0,0 +1,238 @@
+package format
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+//TimestampFormat selects how sTime and eTime are printed
+type TimestampFormat uint8
+
+//Timestamp formats of rwcut --timestamp-format, all times are UTC
+const (
+	//TimestampDefault is 2009/02/13T23:31:30.000
+	TimestampDefault TimestampFormat = iota
+	//TimestampISO is 2009-02-13 23:31:30.000
+	TimestampISO
+	//TimestampEpoch is seconds since the epoch with milliseconds, 1234567890.000
+	TimestampEpoch
+	//TimestampEpochMS is milliseconds since the epoch, 1234567890000
+	TimestampEpochMS
+)
+
+//timestampFormatNames are the --timestamp-format names indexed by TimestampFormat
+var timestampFormatNames = []string{"default", "iso", "epoch", "epoch-ms"}
+
+//ParseTimestampFormat returns the timestamp format named default, iso, epoch or epoch-ms
+func ParseTimestampFormat(name string) (TimestampFormat, error) {
+	for x := range timestampFormatNames {
+		if timestampFormatNames[x] == name {
+			return TimestampFormat(x), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown timestamp format:%s", name)
+}
+
+//String returns the timestamp format name
+func (tf TimestampFormat) String() string {
+	if int(tf) < len(timestampFormatNames) {
+		return timestampFormatNames[tf]
+	}
+	return fmt.Sprintf("TimestampFormat(%d)", uint8(tf))
+}
+
+//width returns the column width of a timestamp
+func (tf TimestampFormat) width() int {
+	switch tf {
+	case TimestampEpoch:
+		return 14
+	case TimestampEpochMS:
+		return 13
+	}
+	return 23
+}
+
+//AppendTime appends the timestamp of ms milliseconds since the epoch
+func (tf TimestampFormat) AppendTime(dst []byte, ms uint64) []byte {
+	switch tf {
+	case TimestampEpoch:
+		dst = strconv.AppendUint(dst, ms/1000, 10)
+		return appendMillis(dst, ms%1000)
+	case TimestampEpochMS:
+		return strconv.AppendUint(dst, ms, 10)
+	}
+	var t = time.Unix(int64(ms/1000), int64(ms%1000)*int64(time.Millisecond)).UTC()
+	if tf == TimestampISO {
+		return t.AppendFormat(dst, "2006-01-02 15:04:05.000")
+	}
+	return t.AppendFormat(dst, "2006/01/02T15:04:05.000")
+}
+
+//appendMillis appends milliseconds as a 3 digit fraction of a second
+func appendMillis(dst []byte, ms uint64) []byte {
+	return append(dst, '.', byte('0'+ms/100), byte('0'+ms/10%10), byte('0'+ms%10))
+}
+
+//IPFormat selects how IP addresses are printed
+type IPFormat uint8
+
+//IP address formats of rwcut --ip-format
+const (
+	//IPCanonical is 10.1.2.3 and 2001:db8::1
+	IPCanonical IPFormat = iota
+	//IPDecimal is the address as an integer, 167838211
+	IPDecimal
+	//IPHexadecimal is the address as hexadecimal digits, 0a010203
+	IPHexadecimal
+	//IPZeroPadded is 010.001.002.003 and 2001:0db8:0000:0000:0000:0000:0000:0001
+	IPZeroPadded
+)
+
+//ipFormatNames are the --ip-format names indexed by IPFormat
+var ipFormatNames = []string{"canonical", "decimal", "hexadecimal", "zero-padded"}
+
+//ParseIPFormat returns the IP format named canonical, decimal, hexadecimal or zero-padded
+func ParseIPFormat(name string) (IPFormat, error) {
+	for x := range ipFormatNames {
+		if ipFormatNames[x] == name {
+			return IPFormat(x), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown IP format:%s", name)
+}
+
+//String returns the IP format name
+func (f IPFormat) String() string {
+	if int(f) < len(ipFormatNames) {
+		return ipFormatNames[f]
+	}
+	return fmt.Sprintf("IPFormat(%d)", uint8(f))
+}
+
+//width returns the column width of an IP address, rwcut sizes IP columns
+//for IPv6 addresses
+func (f IPFormat) width() int {
+	if f == IPHexadecimal {
+		return 32
+	}
+	return 39
+}
+
+//appendIP appends addr, the zero netip.Addr is printed as 0.0.0.0 like a
+//record without a next hop in rwcut
+func (f IPFormat) appendIP(dst []byte, addr netip.Addr) []byte {
+	if addr.IsValid() == false {
+		addr = netip.IPv4Unspecified()
+	}
+	switch f {
+	case IPDecimal:
+		if addr.Is4() {
+			var b = addr.As4()
+			return strconv.AppendUint(dst, uint64(binary.BigEndian.Uint32(b[:])), 10)
+		}
+		var b = addr.As16()
+		return appendUint128(dst, binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:]))
+	case IPHexadecimal:
+		if addr.Is4() {
+			var b = addr.As4()
+			return appendHex(dst, b[:])
+		}
+		var b = addr.As16()
+		return appendHex(dst, b[:])
+	case IPZeroPadded:
+		if addr.Is4() {
+			var b = addr.As4()
+			for x := range b {
+				if x > 0 {
+					dst = append(dst, '.')
+				}
+				dst = append(dst, '0'+b[x]/100, '0'+b[x]/10%10, '0'+b[x]%10)
+			}
+			return dst
+		}
+		var b = addr.As16()
+		for x := 0; x < len(b); x += 2 {
+			if x > 0 {
+				dst = append(dst, ':')
+			}
+			dst = appendHex(dst, b[x:x+2])
+		}
+		return dst
+	}
+	return addr.AppendTo(dst)
+}
+
+//appendHex appends b as lower case hexadecimal digits
+func appendHex(dst []byte, b []byte) []byte {
+	const digits = "0123456789abcdef"
+	for _, c := range b {
+		dst = append(dst, digits[c>>4], digits[c&0x0F])
+	}
+	return dst
+}
+
+//appendUint128 appends the decimal digits of the 128 bit number hi:lo
+func appendUint128(dst []byte, hi uint64, lo uint64) []byte {
+	if hi == 0 {
+		return strconv.AppendUint(dst, lo, 10)
+	}
+	var digits [39]byte
+	var i = len(digits)
+	for hi != 0 || lo != 0 {
+		var rem uint64
+		hi, rem = bits.Div64(0, hi, 10)
+		lo, rem = bits.Div64(rem, lo, 10)
+		i--
+		digits[i] = byte('0' + rem)
+	}
+	return append(dst, digits[i:]...)
+}
+
+//tcpFlagLetters are the TCP flag letters in rwcut's order, bit 0 first
+const tcpFlagLetters = "FSRPAUEC"
+
+//attributeLetters are the letters of the attribute bits printed by rwcut,
+//timeout killed, continuation, FIN followed by data and uniform packet size.
+//The bits are the SK_TCPSTATE values from SiLK's rwrec.h.
+var attributeLetters = []struct {
+	letter byte
+	bit    uint8
+}{
+	{'T', 0x20},
+	{'C', 0x40},
+	{'F', 0x08},
+	{'S', 0x10},
+}
+
+//appendTCPFlags appends the letters of the set TCP flags. padded keeps every
+//letter in its column with spaces for unset flags.
+func appendTCPFlags(dst []byte, flags uint8, padded bool) []byte {
+	for x := 0; x < len(tcpFlagLetters); x++ {
+		if flags&(1<<uint(x)) != 0 {
+			dst = append(dst, tcpFlagLetters[x])
+		} else if padded {
+			dst = append(dst, ' ')
+		}
+	}
+	return dst
+}
+
+//appendAttributes appends the letters of the set attributes, padded to 8
+//columns like TCP flags when padded is set
+func appendAttributes(dst []byte, attributes uint8, padded bool) []byte {
+	for _, a := range attributeLetters {
+		if attributes&a.bit != 0 {
+			dst = append(dst, a.letter)
+		} else if padded {
+			dst = append(dst, ' ')
+		}
+	}
+	if padded {
+		dst = append(dst, "    "...)
+	}
+	return dst
+}
@@ -0,0 +1,166 @@
+package silk
+
+import (
+	"fmt"
+)
+
+//Silk file format ids of the IPv6 capable and generic record formats
+const (
+	FormatRWIPV6        uint8 = 0x0B
+	FormatRWIPV6Routing uint8 = 0x0C
+	FormatRWGeneric     uint8 = 0x16
+)
+
+//FormatIPSet is the silk file format id of IPset files, see the ipset package
+const FormatIPSet uint8 = 0x1D
+
+//formatNames maps silk file format ids to the names used by the silk tools
+var formatNames = map[uint8]string{
+	0x00: "FT_TCPDUMP",
+	0x01: "FT_GRAPH",
+	0x02: "FT_ADDRESSES",
+	0x03: "FT_PORTMAP",
+	0x04: "FT_SERVICEMAP",
+	0x05: "FT_NIDSMAP",
+	0x06: "FT_EXPERIMENT1",
+	0x07: "FT_EXPERIMENT2",
+	0x08: "FT_TEMPFILE",
+	0x09: "FT_AGGREGATEBAG",
+	0x0A: "FT_IPFIX",
+	0x0B: "FT_RWIPV6",
+	0x0C: "FT_RWIPV6ROUTING",
+	0x0D: "FT_RWAUGSNMPOUT",
+	0x0E: "FT_RWAUGROUTING",
+	0x10: "FT_RWROUTED",
+	0x11: "FT_RWNOTROUTED",
+	0x12: "FT_RWSPLIT",
+	0x13: "FT_RWFILTER",
+	0x14: "FT_RWAUGMENTED",
+	0x15: "FT_RWAUGWEB",
+	0x16: "FT_RWGENERIC",
+	0x18: "FT_RWDAILY",
+	0x19: "FT_RWSCAN",
+	0x1A: "FT_RWACL",
+	0x1B: "FT_RWCOUNT",
+	0x1C: "FT_FLOWCAP",
+	0x1D: "FT_IPSET",
+	0x1E: "FT_TAGTREE",
+	0x1F: "FT_RWWWW",
+	0x20: "FT_SHUFFLE",
+	0x21: "FT_RWBAG",
+	0x22: "FT_BLOOM",
+	0x23: "FT_RWPRINTSTATS",
+	0x24: "FT_PDUFILE",
+	0x25: "FT_PREFIXMAP",
+}
+
+//FormatName returns the silk name of a file format id such as FT_RWIPV6ROUTING
+func FormatName(format uint8) string {
+	if name, ok := formatNames[format]; ok {
+		return name
+	}
+	return "FT_UNKNOWN"
+}
+
+//UnsupportedFormatError is returned when a header's record format, version
+//and record size don't match a registered record format. Encode is true when
+//the format can be read but not written.
+type UnsupportedFormatError struct {
+	Format     uint8
+	Version    uint16
+	RecordSize uint16
+	Encode     bool
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	if e.Encode {
+		return fmt.Sprintf("Writing record format:%d version:%d is not supported", e.Format, e.Version)
+	}
+	return fmt.Sprintf("Unsupported record format:%d version:%d size:%d", e.Format, e.Version, e.RecordSize)
+}
+
+//ErrMissingPackedFile is returned for a file in a packed record format
+//without header entry 1, which holds the hour its start times are relative to
+var ErrMissingPackedFile = fmt.Errorf("Packed file header entry 1 is missing")
+
+//formatKey identifies a record layout by its header record format and version
+type formatKey struct {
+	format  uint8
+	version uint16
+}
+
+//recordFormat decodes and encodes the records of one format version.
+//encode is nil for formats that can only be read. decodePartial decodes
+//only the fields tested by a PartialFilter, lookupFormat sets it to decode
+//for formats that pack them with the other fields. packed is set for
+//formats storing start times as an offset from the hour of header entry 1.
+type recordFormat struct {
+	size          uint16
+	packed        bool
+	decode        func(record []byte, h *Header, silkFlow *Record)
+	decodePartial func(record []byte, h *Header, silkFlow *Record)
+	encode        func(record []byte, r *Record, h *Header) error
+}
+
+//recordFormats holds every supported record format, see registerFormat
+var recordFormats = map[formatKey]recordFormat{}
+
+//registerFormat adds a record format version to recordFormats
+func registerFormat(format uint8, version uint16, rf recordFormat) {
+	recordFormats[formatKey{format: format, version: version}] = rf
+}
+
+//lookupFormat returns the record format for the header's record format and
+//version. A header record size of 0 is filled in from the format.
+func lookupFormat(h *Header) (rf recordFormat, err error) {
+	var ok bool
+	if rf, ok = recordFormats[formatKey{format: h.RecordFormat, version: h.RecordVersion}]; ok == false {
+		err = &UnsupportedFormatError{Format: h.RecordFormat, Version: h.RecordVersion, RecordSize: h.RecordSize}
+		return
+	}
+	if h.RecordSize == 0 {
+		h.RecordSize = rf.size
+	}
+	if h.RecordSize != rf.size {
+		err = &UnsupportedFormatError{Format: h.RecordFormat, Version: h.RecordVersion, RecordSize: h.RecordSize}
+		return
+	}
+	if _, ok = h.PackedFile(); rf.packed && ok == false {
+		err = ErrMissingPackedFile
+		return
+	}
+	if rf.decodePartial == nil {
+		rf.decodePartial = rf.decode
+	}
+	return
+}
+
+//offsetFormat returns a record format decoded by decodeFlow and encoded by
+//encodeFlow using the offsets of recordSize
+func offsetFormat(recordSize uint16) recordFormat {
+	o, err := getOffsets(recordSize)
+	if err != nil {
+		panic(err)
+	}
+	return recordFormat{
+		size:   recordSize,
+		packed: o.packedStartTime,
+		decode: func(record []byte, h *Header, silkFlow *Record) {
+			decodeFlow(record, h, o, silkFlow)
+		},
+		decodePartial: func(record []byte, h *Header, silkFlow *Record) {
+			decodePartialFlow(record, h, o, silkFlow)
+		},
+		encode: func(record []byte, r *Record, h *Header) error {
+			return encodeFlow(record, r, h, o)
+		},
+	}
+}
+
+func init() {
+	registerFormat(FormatRWIPV6Routing, 1, offsetFormat(88))
+	registerFormat(FormatRWIPV6Routing, 2, offsetFormat(88))
+	registerFormat(FormatRWIPV6, 1, offsetFormat(68))
+	registerFormat(FormatRWIPV6, 2, offsetFormat(56))
+	registerFormat(FormatRWGeneric, 5, offsetFormat(52))
+}
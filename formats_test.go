@@ -0,0 +1,116 @@
+package silk
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+//TestLookupFormat verifies record formats are chosen by format and version
+//and unknown combinations return an UnsupportedFormatError
+func TestLookupFormat(t *testing.T) {
+	for _, test := range []struct {
+		format  uint8
+		version uint16
+		size    uint16
+		valid   bool
+	}{
+		{FormatRWIPV6Routing, 1, 88, true},
+		{FormatRWIPV6Routing, 2, 88, true},
+		{FormatRWIPV6, 1, 68, true},
+		{FormatRWIPV6, 2, 56, true},
+		{FormatRWGeneric, 5, 52, true},
+		{FormatRWAugmented, 5, 28, true},
+		{FormatRWAugWeb, 5, 26, true},
+		{FormatRWNotRouted, 5, 26, true},
+		{FormatRWIPV6, 2, 0, true},
+		{FormatRWIPV6Routing, 3, 100, false},
+		{FormatRWAugmented, 4, 28, false},
+		{FormatRWRouted, 5, 36, false},
+		{FormatRWFilter, 5, 36, false},
+		{FormatRWIPV6, 1, 56, false},
+		{0xFF, 1, 88, false},
+	} {
+		var packedFile = PackedFileEntry{StartTimeMS: 1434553200000, FlowType: 1, Sensor: 3}.VarLenHeader()
+		var h = Header{RecordFormat: test.format, RecordVersion: test.version, RecordSize: test.size, VarLenHeaders: []VarLenHeader{packedFile}}
+		rf, err := lookupFormat(&h)
+		if test.valid {
+			if err != nil {
+				t.Errorf("Format:%d version:%d size:%d error:%s", test.format, test.version, test.size, err)
+			} else if h.RecordSize != rf.size {
+				t.Errorf("Format:%d version:%d record size:%d expected:%d", test.format, test.version, h.RecordSize, rf.size)
+			}
+			continue
+		}
+		var formatErr *UnsupportedFormatError
+		if errors.As(err, &formatErr) == false {
+			t.Errorf("Format:%d version:%d size:%d error:%v expected UnsupportedFormatError", test.format, test.version, test.size, err)
+		}
+	}
+}
+
+//TestMissingPackedFile verifies formats with start times relative to the
+//hour of header entry 1 can't be read or written without it
+func TestMissingPackedFile(t *testing.T) {
+	for _, test := range []struct {
+		format  uint8
+		version uint16
+		packed  bool
+	}{
+		{FormatRWIPV6, 2, true},
+		{FormatRWAugmented, 5, true},
+		{FormatRWAugRouting, 5, true},
+		{FormatRWAugSNMPOut, 5, true},
+		{FormatRWAugWeb, 5, true},
+		{FormatRWRouted, 5, true},
+		{FormatRWWWW, 5, true},
+		{FormatRWIPV6, 1, false},
+		{FormatRWIPV6Routing, 1, false},
+	} {
+		var h = Header{RecordFormat: test.format, RecordVersion: test.version}
+		var _, err = lookupFormat(&h)
+		if test.packed && err != ErrMissingPackedFile {
+			t.Errorf("Format:%s version:%d error:%v expected:%s", FormatName(test.format), test.version, err, ErrMissingPackedFile)
+		} else if test.packed == false && err != nil {
+			t.Errorf("Format:%s version:%d error:%s", FormatName(test.format), test.version, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	var bw, err = NewBodyWriter(&buf, Header{RecordFormat: FormatRWAugmented, RecordVersion: 5, RecordSize: 28})
+	if err != nil {
+		t.Fatalf("NewBodyWriter error:%s", err)
+	}
+	if _, err = bw.Write(make([]byte, 28)); err != nil {
+		t.Fatalf("Write error:%s", err)
+	}
+	if err = bw.Close(); err != nil {
+		t.Fatalf("Close error:%s", err)
+	}
+	if _, err = NewReader(&buf); err != ErrMissingPackedFile {
+		t.Errorf("NewReader error:%v expected:%s", err, ErrMissingPackedFile)
+	}
+}
+
+//TestUnsupportedFormat verifies the Reader and Writer reject unknown formats
+//with an UnsupportedFormatError
+func TestUnsupportedFormat(t *testing.T) {
+	var sf File
+	var err error
+	var formatErr *UnsupportedFormatError
+
+	if sf, err = OpenFile("testdata/FT_RWIPV6ROUTING-v3-c1-L.dat"); errors.As(err, &formatErr) == false {
+		t.Errorf("OpenFile error:%v expected UnsupportedFormatError", err)
+	} else if formatErr.Format != FormatRWIPV6Routing || formatErr.Version != 3 || formatErr.RecordSize != 100 {
+		t.Errorf("UnsupportedFormatError:%+v", formatErr)
+	}
+
+	if sf, err = OpenFile("testdata/FT_RWAUGMENTED-v5-c1-L.dat"); err != nil {
+		t.Fatalf("OpenFile error:%s", err)
+	}
+	if _, err = NewWriter(&bytes.Buffer{}, sf.Header); errors.As(err, &formatErr) == false {
+		t.Errorf("NewWriter error:%v expected UnsupportedFormatError", err)
+	} else if formatErr.Encode == false {
+		t.Errorf("UnsupportedFormatError:%+v expected Encode", formatErr)
+	}
+}
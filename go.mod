@@ -1,6 +1,6 @@
 module github.com/chrispassas/silk
 
-go 1.14
+go 1.18
 
 require (
 	github.com/golang/snappy v0.0.1
@@ -1,6 +1,7 @@
 package silk
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -20,10 +21,17 @@ type Header struct {
 	VarLenHeaders []VarLenHeader
 	HeaderLength  int
 
-	fileDateMS uint64
-	fileSensor uint32
+	fileDateMS   uint64
+	fileFlowType uint32
+	fileSensor   uint32
 }
 
+//silkMagicNumber is the first 4 bytes of every silk file
+var silkMagicNumber = []byte{0xde, 0xad, 0xbe, 0xef}
+
+//silkFileVersion is the header version that uses variable length headers
+const silkFileVersion uint8 = 16
+
 //VarLenHeader is part of the silk header. They contain different things
 //like the cli command used to create the file. For some file types the
 // variable length header also contains the year/month/day/hour of the file.
@@ -92,7 +100,7 @@ func parseHeader(f io.Reader) (h Header, err error) {
 
 		var varHeaderContent []byte
 
-		if varLengthHeaderLength > 0 {
+		if varLengthHeaderLength > 8 {
 			varHeaderContent = make([]byte, varLengthHeaderLength-8)
 			if _, err = f.Read(varHeaderContent); err != nil {
 				return
@@ -106,7 +114,7 @@ func parseHeader(f io.Reader) (h Header, err error) {
 
 		if id == 1 {
 			h.fileDateMS = binary.BigEndian.Uint64(varHeaderContent[0:8])
-			// h.fileSensor = binary.BigEndian.Uint32(varHeaderContent[8:12]) //Correct value but unknown purpose
+			h.fileFlowType = binary.BigEndian.Uint32(varHeaderContent[8:12])
 			h.fileSensor = binary.BigEndian.Uint32(varHeaderContent[12:16]) //Correct value but unknown purpose
 		}
 
@@ -116,7 +124,7 @@ func parseHeader(f io.Reader) (h Header, err error) {
 		}
 	}
 	h.HeaderLength = counter
-	if (counter % int(h.RecordSize)) == 0 {
+	if h.RecordSize == 0 || (counter%int(h.RecordSize)) == 0 {
 		return
 	}
 
@@ -146,3 +154,290 @@ func parseHeader(f io.Reader) (h Header, err error) {
 
 	return
 }
+
+//writeHeader fills in default header values, replaces any end of header entry
+//with one padded out to a multiple of the record size and writes the header to w.
+//The unexported file date and sensor values are set from header entry 1.
+func writeHeader(w io.Writer, h *Header) (err error) {
+	if h.RecordSize == 0 {
+		err = fmt.Errorf("Header record size must be greater than zero")
+		return
+	}
+	if len(h.MagicNumber) == 0 {
+		h.MagicNumber = silkMagicNumber
+	}
+	if h.FileVersion == 0 {
+		h.FileVersion = silkFileVersion
+	}
+	if len(h.MagicNumber) != 4 {
+		err = fmt.Errorf("Header magic number must be 4 bytes, found:%d", len(h.MagicNumber))
+		return
+	} else if h.FileVersion != silkFileVersion {
+		err = fmt.Errorf("Unsupported header file version:%d", h.FileVersion)
+		return
+	}
+
+	var headerBytes = make([]byte, 16, 256)
+	copy(headerBytes[0:4], h.MagicNumber)
+	headerBytes[4] = h.FileFlags
+	headerBytes[5] = h.RecordFormat
+	headerBytes[6] = h.FileVersion
+	headerBytes[7] = h.Compression
+	binary.BigEndian.PutUint32(headerBytes[8:12], h.SilkVersion)
+	binary.BigEndian.PutUint16(headerBytes[12:14], h.RecordSize)
+	binary.BigEndian.PutUint16(headerBytes[14:16], h.RecordVersion)
+
+	var varLenHeaders = make([]VarLenHeader, 0, len(h.VarLenHeaders)+1)
+	for _, varLenHeader := range h.VarLenHeaders {
+		if varLenHeader.ID == 0 {
+			continue
+		}
+		var length = uint32(8 + len(varLenHeader.Content))
+		switch varLenHeader.ID {
+		case 1:
+			if length != 24 {
+				err = fmt.Errorf("Variable length header id:%d must be 24 bytes, found:%d", varLenHeader.ID, length)
+				return
+			}
+			h.fileDateMS = binary.BigEndian.Uint64(varLenHeader.Content[0:8])
+			h.fileFlowType = binary.BigEndian.Uint32(varLenHeader.Content[8:12])
+			h.fileSensor = binary.BigEndian.Uint32(varLenHeader.Content[12:16])
+		case 2, 3, 4, 5:
+			//variable length content
+		case 6:
+			if length != 16 {
+				err = fmt.Errorf("Variable length header id:%d must be 16 bytes, found:%d", varLenHeader.ID, length)
+				return
+			}
+		case 7:
+			if length != 32 {
+				err = fmt.Errorf("Variable length header id:%d must be 32 bytes, found:%d", varLenHeader.ID, length)
+				return
+			}
+		default:
+			err = fmt.Errorf("Unsupported variable length header id:%d", varLenHeader.ID)
+			return
+		}
+
+		var b = make([]byte, 8)
+		binary.BigEndian.PutUint32(b[0:4], varLenHeader.ID)
+		binary.BigEndian.PutUint32(b[4:8], length)
+		headerBytes = append(headerBytes, b...)
+		headerBytes = append(headerBytes, varLenHeader.Content...)
+
+		varLenHeader.Length = length
+		varLenHeaders = append(varLenHeaders, varLenHeader)
+	}
+
+	//The end of header entry holds the padding so that records start on
+	//a multiple of the record size, this is what parseHeader expects.
+	var endLength = 8
+	if mod := (len(headerBytes) + endLength) % int(h.RecordSize); mod != 0 {
+		endLength += int(h.RecordSize) - mod
+	}
+	var end = make([]byte, endLength)
+	binary.BigEndian.PutUint32(end[4:8], uint32(endLength))
+	headerBytes = append(headerBytes, end...)
+	varLenHeaders = append(varLenHeaders, VarLenHeader{
+		ID:      0,
+		Length:  uint32(endLength),
+		Content: end[8:],
+	})
+
+	h.VarLenHeaders = varLenHeaders
+	h.HeaderLength = len(headerBytes)
+
+	_, err = w.Write(headerBytes)
+	return
+}
+
+//Variable length header entry ids
+const (
+	HeaderEntryEnd        uint32 = 0
+	HeaderEntryPackedFile uint32 = 1
+	HeaderEntryInvocation uint32 = 2
+	HeaderEntryAnnotation uint32 = 3
+	HeaderEntryProbeName  uint32 = 4
+	HeaderEntryPrefixMap  uint32 = 5
+	HeaderEntryBag        uint32 = 6
+	HeaderEntryIPSet      uint32 = 7
+)
+
+//PackedFileEntry is header entry 1, written by rwflowpack to files holding
+//the flows of one sensor and flowtype for an hour.
+type PackedFileEntry struct {
+	StartTimeMS uint64
+	FlowType    uint32
+	Sensor      uint32
+}
+
+//PrefixMapEntry is header entry 5 of a prefix map file
+type PrefixMapEntry struct {
+	Version uint32
+	MapName string
+}
+
+//BagEntry is header entry 6 of a bag file, it describes the key and counter types
+type BagEntry struct {
+	KeyType       uint16
+	KeyLength     uint16
+	CounterType   uint16
+	CounterLength uint16
+}
+
+//IPSetEntry is header entry 7 of an IPset file, it describes the radix tree
+//layout of record version 2 and 3 IPsets.
+type IPSetEntry struct {
+	ChildNode uint32
+	LeafCount uint32
+	LeafSize  uint32
+	NodeCount uint32
+	NodeSize  uint32
+	RootIndex uint32
+}
+
+//PackedFile returns header entry 1 and whether it is present
+func (h Header) PackedFile() (e PackedFileEntry, ok bool) {
+	var content []byte
+	if content, ok = h.entry(HeaderEntryPackedFile, 16); ok {
+		e.StartTimeMS = binary.BigEndian.Uint64(content[0:8])
+		e.FlowType = binary.BigEndian.Uint32(content[8:12])
+		e.Sensor = binary.BigEndian.Uint32(content[12:16])
+	}
+	return
+}
+
+//Invocations returns the command lines of header entry 2. A file has one
+//entry for each tool that created or modified it.
+func (h Header) Invocations() []string {
+	return h.entryStrings(HeaderEntryInvocation)
+}
+
+//Annotations returns the notes of header entry 3
+func (h Header) Annotations() []string {
+	return h.entryStrings(HeaderEntryAnnotation)
+}
+
+//ProbeName returns the probe name of header entry 4 and whether it is present
+func (h Header) ProbeName() (name string, ok bool) {
+	var content []byte
+	if content, ok = h.entry(HeaderEntryProbeName, 0); ok {
+		name = entryString(content)
+	}
+	return
+}
+
+//PrefixMap returns header entry 5 and whether it is present
+func (h Header) PrefixMap() (e PrefixMapEntry, ok bool) {
+	var content []byte
+	if content, ok = h.entry(HeaderEntryPrefixMap, 4); ok {
+		e.Version = binary.BigEndian.Uint32(content[0:4])
+		e.MapName = entryString(content[4:])
+	}
+	return
+}
+
+//Bag returns header entry 6 and whether it is present
+func (h Header) Bag() (e BagEntry, ok bool) {
+	var content []byte
+	if content, ok = h.entry(HeaderEntryBag, 8); ok {
+		e.KeyType = binary.BigEndian.Uint16(content[0:2])
+		e.KeyLength = binary.BigEndian.Uint16(content[2:4])
+		e.CounterType = binary.BigEndian.Uint16(content[4:6])
+		e.CounterLength = binary.BigEndian.Uint16(content[6:8])
+	}
+	return
+}
+
+//IPSet returns header entry 7 and whether it is present
+func (h Header) IPSet() (e IPSetEntry, ok bool) {
+	var content []byte
+	if content, ok = h.entry(HeaderEntryIPSet, 24); ok {
+		e.ChildNode = binary.BigEndian.Uint32(content[0:4])
+		e.LeafCount = binary.BigEndian.Uint32(content[4:8])
+		e.LeafSize = binary.BigEndian.Uint32(content[8:12])
+		e.NodeCount = binary.BigEndian.Uint32(content[12:16])
+		e.NodeSize = binary.BigEndian.Uint32(content[16:20])
+		e.RootIndex = binary.BigEndian.Uint32(content[20:24])
+	}
+	return
+}
+
+//VarLenHeader returns e as header entry 1
+func (e PackedFileEntry) VarLenHeader() VarLenHeader {
+	var content = make([]byte, 16)
+	binary.BigEndian.PutUint64(content[0:8], e.StartTimeMS)
+	binary.BigEndian.PutUint32(content[8:12], e.FlowType)
+	binary.BigEndian.PutUint32(content[12:16], e.Sensor)
+	return VarLenHeader{ID: HeaderEntryPackedFile, Length: 24, Content: content}
+}
+
+//VarLenHeader returns e as header entry 5
+func (e PrefixMapEntry) VarLenHeader() VarLenHeader {
+	var content = make([]byte, 4, 4+len(e.MapName)+1)
+	binary.BigEndian.PutUint32(content[0:4], e.Version)
+	content = append(content, e.MapName...)
+	content = append(content, 0)
+	return VarLenHeader{ID: HeaderEntryPrefixMap, Length: uint32(8 + len(content)), Content: content}
+}
+
+//VarLenHeader returns e as header entry 6
+func (e BagEntry) VarLenHeader() VarLenHeader {
+	var content = make([]byte, 8)
+	binary.BigEndian.PutUint16(content[0:2], e.KeyType)
+	binary.BigEndian.PutUint16(content[2:4], e.KeyLength)
+	binary.BigEndian.PutUint16(content[4:6], e.CounterType)
+	binary.BigEndian.PutUint16(content[6:8], e.CounterLength)
+	return VarLenHeader{ID: HeaderEntryBag, Length: 16, Content: content}
+}
+
+//VarLenHeader returns e as header entry 7
+func (e IPSetEntry) VarLenHeader() VarLenHeader {
+	var content = make([]byte, 24)
+	binary.BigEndian.PutUint32(content[0:4], e.ChildNode)
+	binary.BigEndian.PutUint32(content[4:8], e.LeafCount)
+	binary.BigEndian.PutUint32(content[8:12], e.LeafSize)
+	binary.BigEndian.PutUint32(content[12:16], e.NodeCount)
+	binary.BigEndian.PutUint32(content[16:20], e.NodeSize)
+	binary.BigEndian.PutUint32(content[20:24], e.RootIndex)
+	return VarLenHeader{ID: HeaderEntryIPSet, Length: 32, Content: content}
+}
+
+//NewStringHeader returns a string header entry such as an invocation (2),
+//annotation (3) or probe name (4). The string is stored null terminated.
+func NewStringHeader(id uint32, s string) VarLenHeader {
+	var content = make([]byte, 0, len(s)+1)
+	content = append(content, s...)
+	content = append(content, 0)
+	return VarLenHeader{ID: id, Length: uint32(8 + len(content)), Content: content}
+}
+
+//entry returns the content of the first header entry with id, it is only
+//found when the content is at least minLength bytes.
+func (h Header) entry(id uint32, minLength int) (content []byte, ok bool) {
+	for _, varLenHeader := range h.VarLenHeaders {
+		if varLenHeader.ID == id && len(varLenHeader.Content) >= minLength {
+			return varLenHeader.Content, true
+		}
+	}
+	return
+}
+
+//entryStrings returns the string content of every header entry with id
+func (h Header) entryStrings(id uint32) (values []string) {
+	for _, varLenHeader := range h.VarLenHeaders {
+		if varLenHeader.ID == id {
+			values = append(values, entryString(varLenHeader.Content))
+		}
+	}
+	return
+}
+
+//entryString returns content up to the first null byte. String entries are
+//null terminated and may be padded with more null bytes.
+func entryString(content []byte) string {
+	if i := bytes.IndexByte(content, 0); i >= 0 {
+		content = content[:i]
+	}
+	return string(content)
+}
@@ -0,0 +1,103 @@
+package silk
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+//TestHeaderEntries verifies the typed header entries of a rwflowpack test file
+func TestHeaderEntries(t *testing.T) {
+	var sf File
+	var err error
+
+	if sf, err = OpenFile("testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"); err != nil {
+		t.Fatalf("OpenFile error:%s", err)
+	}
+	var h = sf.Header
+
+	packedFile, ok := h.PackedFile()
+	if ok == false {
+		t.Errorf("PackedFile() not found")
+	} else if packedFile != (PackedFileEntry{StartTimeMS: 1434553200000, FlowType: 1, Sensor: 3}) {
+		t.Errorf("PackedFile():%+v", packedFile)
+	}
+	if probeName, ok := h.ProbeName(); ok == false || probeName != "DUMMY_PROBE" {
+		t.Errorf("ProbeName():%q found:%t expected:%q", probeName, ok, "DUMMY_PROBE")
+	}
+	var invocation = "rwallformats --site-config-file=/usr/local/share/silk/generic-silk.conf ../../../FCCX-silk/S3/out/2015/06/17/out-S3_20150617.15"
+	if invocations := h.Invocations(); reflect.DeepEqual(invocations, []string{invocation}) == false {
+		t.Errorf("Invocations():%q expected:%q", invocations, invocation)
+	}
+	if annotations := h.Annotations(); len(annotations) != 0 {
+		t.Errorf("Annotations():%q expected none", annotations)
+	}
+	if _, ok = h.PrefixMap(); ok {
+		t.Errorf("PrefixMap() should not be found")
+	}
+	if _, ok = h.Bag(); ok {
+		t.Errorf("Bag() should not be found")
+	}
+	if _, ok = h.IPSet(); ok {
+		t.Errorf("IPSet() should not be found")
+	}
+}
+
+//TestHeaderEntriesRoundTrip verifies every typed header entry survives writing and parsing
+func TestHeaderEntriesRoundTrip(t *testing.T) {
+	var packedFile = PackedFileEntry{StartTimeMS: 1434553200000, FlowType: 4, Sensor: 12}
+	var prefixMap = PrefixMapEntry{Version: 1, MapName: "country"}
+	var bag = BagEntry{KeyType: 1, KeyLength: 4, CounterType: 0, CounterLength: 8}
+	var ipSet = IPSetEntry{ChildNode: 16, LeafCount: 3, LeafSize: 8, NodeCount: 2, NodeSize: 72, RootIndex: 0}
+
+	var h = Header{
+		RecordFormat:  FormatRWIPV6Routing,
+		RecordVersion: 1,
+		VarLenHeaders: []VarLenHeader{
+			NewStringHeader(HeaderEntryProbeName, "P0"),
+			packedFile.VarLenHeader(),
+			NewStringHeader(HeaderEntryInvocation, "rwfilter --type=all"),
+			NewStringHeader(HeaderEntryInvocation, "rwsort --fields=stime"),
+			NewStringHeader(HeaderEntryAnnotation, "test note"),
+			prefixMap.VarLenHeader(),
+			bag.VarLenHeader(),
+			ipSet.VarLenHeader(),
+		},
+	}
+	var buf bytes.Buffer
+	var sw *Writer
+	var sr *Reader
+	var err error
+	if sw, err = NewWriter(&buf, h); err != nil {
+		t.Fatalf("NewWriter error:%s", err)
+	}
+	if err = sw.Close(); err != nil {
+		t.Fatalf("Close error:%s", err)
+	}
+	if sr, err = NewReader(&buf); err != nil {
+		t.Fatalf("NewReader error:%s", err)
+	}
+	h = sr.Header()
+
+	if found, _ := h.PackedFile(); found != packedFile {
+		t.Errorf("PackedFile():%+v expected:%+v", found, packedFile)
+	}
+	if found, _ := h.ProbeName(); found != "P0" {
+		t.Errorf("ProbeName():%q expected:%q", found, "P0")
+	}
+	if found := h.Invocations(); reflect.DeepEqual(found, []string{"rwfilter --type=all", "rwsort --fields=stime"}) == false {
+		t.Errorf("Invocations():%q", found)
+	}
+	if found := h.Annotations(); reflect.DeepEqual(found, []string{"test note"}) == false {
+		t.Errorf("Annotations():%q", found)
+	}
+	if found, _ := h.PrefixMap(); found != prefixMap {
+		t.Errorf("PrefixMap():%+v expected:%+v", found, prefixMap)
+	}
+	if found, _ := h.Bag(); found != bag {
+		t.Errorf("Bag():%+v expected:%+v", found, bag)
+	}
+	if found, _ := h.IPSet(); found != ipSet {
+		t.Errorf("IPSet():%+v expected:%+v", found, ipSet)
+	}
+}
@@ -0,0 +1,201 @@
+package silk
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+//ErrNoIndex is returned by SeekRecord and ReadRecords for a Reader not created by NewIndexedReader
+var ErrNoIndex = fmt.Errorf("Reader has no index, use NewIndexedReader")
+
+//IndexBlock describes one block of records. For compressed files a block is
+//a compressed block and Offset is the file offset of its 8 byte block
+//header. Uncompressed files are indexed in blocks of DefaultBlockSize bytes
+//of records and Offset is the file offset of the first record.
+//StartTimeMS and EndTimeMS are the earliest flow start and latest flow end
+//time of the block's records.
+type IndexBlock struct {
+	Offset      int64
+	FirstRecord uint64
+	RecordCount uint64
+	StartTimeMS uint64
+	EndTimeMS   uint64
+}
+
+//Index is a block index of a silk file built by BuildIndex
+type Index struct {
+	Header      Header
+	DataOffset  int64
+	RecordCount uint64
+	Blocks      []IndexBlock
+}
+
+//BuildIndex reads the file in r once from the start and returns its block
+//index. Records are decoded to find the time range of each block.
+func BuildIndex(r io.ReadSeeker) (idx *Index, err error) {
+	var sr *Reader
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if sr, err = NewReader(r); err != nil {
+		return
+	}
+	idx = &Index{Header: sr.header}
+	if idx.DataOffset, err = r.Seek(0, io.SeekCurrent); err != nil {
+		idx = nil
+		return
+	}
+
+	var recordSize = int(sr.header.RecordSize)
+	if sr.header.Compression == 0 {
+		var recordsPerBlock = uint64(DefaultBlockSize / recordSize)
+		var block IndexBlock
+		for sr.Next() {
+			if block.RecordCount == recordsPerBlock {
+				idx.Blocks = append(idx.Blocks, block)
+				block = IndexBlock{}
+			}
+			if block.RecordCount == 0 {
+				block.Offset = idx.DataOffset + int64(idx.RecordCount)*int64(recordSize)
+				block.FirstRecord = idx.RecordCount
+			}
+			block.add(sr.Record())
+			idx.RecordCount++
+		}
+		if block.RecordCount > 0 {
+			idx.Blocks = append(idx.Blocks, block)
+		}
+		if err = sr.Err(); err != nil {
+			idx = nil
+		}
+		return
+	}
+
+	var offset = idx.DataOffset
+	for {
+		if err = sr.readBlock(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			idx = nil
+			return
+		}
+		var block = IndexBlock{Offset: offset, FirstRecord: idx.RecordCount}
+		offset += 8 + int64(len(sr.compressed))
+		for pos := 0; pos < sr.end; pos += recordSize {
+			sr.decode(sr.buf[pos:pos+recordSize], &sr.header, &sr.record)
+			block.add(&sr.record)
+		}
+		idx.RecordCount += block.RecordCount
+		idx.Blocks = append(idx.Blocks, block)
+	}
+	return
+}
+
+//add counts r in the block and extends the block time range to include it
+func (b *IndexBlock) add(r *Record) {
+	var endTimeMS = r.StartTimeMS + uint64(r.Duration)
+	if b.RecordCount == 0 || r.StartTimeMS < b.StartTimeMS {
+		b.StartTimeMS = r.StartTimeMS
+	}
+	if b.RecordCount == 0 || endTimeMS > b.EndTimeMS {
+		b.EndTimeMS = endTimeMS
+	}
+	b.RecordCount++
+}
+
+//Block returns the position in Blocks of the block holding record n
+func (idx *Index) Block(n uint64) (i int, ok bool) {
+	if n >= idx.RecordCount {
+		return
+	}
+	i = sort.Search(len(idx.Blocks), func(i int) bool {
+		return idx.Blocks[i].FirstRecord+idx.Blocks[i].RecordCount > n
+	})
+	return i, i < len(idx.Blocks)
+}
+
+//NewIndexedReader parses the silk header from r and returns a Reader that
+//supports SeekRecord and ReadRecords using idx, which must have been built
+//by BuildIndex from the same file. The Reader starts at the first record.
+func NewIndexedReader(r io.ReadSeeker, idx *Index) (sr *Reader, err error) {
+	var dataOffset int64
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if sr, err = NewReader(r); err != nil {
+		return
+	}
+	if dataOffset, err = r.Seek(0, io.SeekCurrent); err != nil {
+		sr = nil
+		return
+	}
+	if dataOffset != idx.DataOffset || sr.header.Compression != idx.Header.Compression {
+		sr = nil
+		err = fmt.Errorf("Index does not match file, data offset:%d expected:%d", dataOffset, idx.DataOffset)
+		return
+	}
+	sr.seeker = r
+	sr.index = idx
+	return
+}
+
+//SeekRecord positions the Reader so the next call to Next returns record n,
+//counting from 0. Seeking to the record count positions the Reader at the
+//end of the file.
+func (sr *Reader) SeekRecord(n uint64) (err error) {
+	if sr.index == nil {
+		return ErrNoIndex
+	}
+	if n > sr.index.RecordCount {
+		return fmt.Errorf("Record:%d out of range, file has:%d records", n, sr.index.RecordCount)
+	}
+
+	var recordSize = int(sr.header.RecordSize)
+	sr.err = nil
+	sr.raw = nil
+	sr.partial = false
+	sr.pos = 0
+	sr.end = 0
+
+	if n == sr.index.RecordCount {
+		sr.err = io.EOF
+		return
+	}
+	if sr.header.Compression == 0 {
+		_, err = sr.seeker.Seek(sr.index.DataOffset+int64(n)*int64(recordSize), io.SeekStart)
+		return
+	}
+
+	var i, _ = sr.index.Block(n)
+	var block = sr.index.Blocks[i]
+	if _, err = sr.seeker.Seek(block.Offset, io.SeekStart); err != nil {
+		return
+	}
+	if err = sr.readBlock(); err != nil {
+		if err == io.EOF {
+			err = ErrUnsupportedPartialRead
+		}
+		return
+	}
+	sr.pos = int(n-block.FirstRecord) * recordSize
+	return
+}
+
+//ReadRecords seeks to record start and reads up to len(records) records
+//into records, it returns the number of records read. Fewer records are
+//only read at the end of the file or on an error.
+func (sr *Reader) ReadRecords(start uint64, records []Record) (n int, err error) {
+	if err = sr.SeekRecord(start); err != nil {
+		return
+	}
+	for n < len(records) && sr.Next() {
+		records[n] = *sr.Record()
+		n++
+	}
+	err = sr.Err()
+	return
+}
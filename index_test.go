@@ -0,0 +1,139 @@
+package silk
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+//getIndexTestFiles returns uncompressed, zlib and snappy files with several
+//blocks of records and the flows they contain
+func getIndexTestFiles(t *testing.T) (files map[string][]byte, flows []Flow) {
+	var sf File
+	var err error
+
+	if sf, err = OpenFile("testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"); err != nil {
+		t.Fatalf("OpenFile error:%s", err)
+	}
+	flows = sf.Flows[:5000]
+	files = make(map[string][]byte)
+	for _, compression := range []uint8{0, 1, 3} {
+		var h = sf.Header
+		h.Compression = compression
+		buf, _ := writeTestFileSize(t, h, flows, 88*300)
+		files[[]string{"none", "zlib", "lzo", "snappy"}[compression]] = buf.Bytes()
+	}
+	return
+}
+
+//TestBuildIndex verifies the index covers every record with contiguous
+//blocks and time ranges that contain each block's records
+func TestBuildIndex(t *testing.T) {
+	var files, flows = getIndexTestFiles(t)
+	for name, data := range files {
+		idx, err := BuildIndex(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("File:%s BuildIndex error:%s", name, err)
+		}
+		if idx.RecordCount != uint64(len(flows)) {
+			t.Errorf("File:%s RecordCount:%d expected:%d", name, idx.RecordCount, len(flows))
+		}
+		if len(idx.Blocks) < 2 {
+			t.Errorf("File:%s Blocks:%d expected more than one", name, len(idx.Blocks))
+		}
+
+		var next uint64
+		for x, block := range idx.Blocks {
+			if block.FirstRecord != next {
+				t.Errorf("File:%s block:%d FirstRecord:%d expected:%d", name, x, block.FirstRecord, next)
+			}
+			next += block.RecordCount
+			for _, flow := range flows[block.FirstRecord:next] {
+				if flow.StartTimeMS < block.StartTimeMS || flow.StartTimeMS+uint64(flow.Duration) > block.EndTimeMS {
+					t.Errorf("File:%s block:%d time range:%d-%d does not contain flow start:%d", name, x, block.StartTimeMS, block.EndTimeMS, flow.StartTimeMS)
+					break
+				}
+			}
+			if i, ok := idx.Block(block.FirstRecord + block.RecordCount - 1); ok == false || i != x {
+				t.Errorf("File:%s Block(%d):%d expected:%d", name, block.FirstRecord+block.RecordCount-1, i, x)
+			}
+		}
+		if _, ok := idx.Block(idx.RecordCount); ok {
+			t.Errorf("File:%s Block(%d) past the last record should not be found", name, idx.RecordCount)
+		}
+	}
+}
+
+//TestSeekRecord verifies SeekRecord and ReadRecords return the same records as reading from the start
+func TestSeekRecord(t *testing.T) {
+	var files, flows = getIndexTestFiles(t)
+	for name, data := range files {
+		var r = bytes.NewReader(data)
+		idx, err := BuildIndex(r)
+		if err != nil {
+			t.Fatalf("File:%s BuildIndex error:%s", name, err)
+		}
+		sr, err := NewIndexedReader(r, idx)
+		if err != nil {
+			t.Fatalf("File:%s NewIndexedReader error:%s", name, err)
+		}
+
+		for _, n := range []uint64{4999, 0, 299, 300, 2500, 1, 4000} {
+			if err = sr.SeekRecord(n); err != nil {
+				t.Errorf("File:%s SeekRecord(%d) error:%s", name, n, err)
+				continue
+			}
+			if sr.Next() == false {
+				t.Errorf("File:%s SeekRecord(%d) Next() false Err:%v", name, n, sr.Err())
+				continue
+			}
+			if reflect.DeepEqual(*sr.Flow(), flows[n]) == false {
+				t.Errorf("File:%s SeekRecord(%d) flow:%+v expected:%+v", name, n, *sr.Flow(), flows[n])
+			}
+		}
+
+		var records = make([]Record, 700)
+		var count int
+		if count, err = sr.ReadRecords(4500, records); err != nil {
+			t.Errorf("File:%s ReadRecords error:%s", name, err)
+		}
+		if count != 500 {
+			t.Errorf("File:%s ReadRecords count:%d expected:%d", name, count, 500)
+		}
+		for x := 0; x < count; x++ {
+			if reflect.DeepEqual(records[x].Flow(), flows[4500+x]) == false {
+				t.Errorf("File:%s ReadRecords row:%d flow:%+v expected:%+v", name, 4500+x, records[x].Flow(), flows[4500+x])
+				break
+			}
+		}
+
+		if err = sr.SeekRecord(idx.RecordCount); err != nil || sr.Next() {
+			t.Errorf("File:%s SeekRecord(%d) error:%v should be at the end of the file", name, idx.RecordCount, err)
+		}
+		if err = sr.SeekRecord(idx.RecordCount + 1); err == nil {
+			t.Errorf("File:%s SeekRecord(%d) past the end should fail", name, idx.RecordCount+1)
+		}
+	}
+}
+
+//TestSeekRecordNoIndex verifies a Reader without an index can't seek
+func TestSeekRecordNoIndex(t *testing.T) {
+	var files, _ = getIndexTestFiles(t)
+	sr, err := NewReader(bytes.NewReader(files["zlib"]))
+	if err != nil {
+		t.Fatalf("NewReader error:%s", err)
+	}
+	if err = sr.SeekRecord(1); err != ErrNoIndex {
+		t.Errorf("SeekRecord error:%v expected:%s", err, ErrNoIndex)
+	}
+
+	idx, err := BuildIndex(bytes.NewReader(files["zlib"]))
+	if err != nil {
+		t.Fatalf("BuildIndex error:%s", err)
+	}
+	var other io.ReadSeeker = bytes.NewReader(files["none"])
+	if _, err = NewIndexedReader(other, idx); err == nil {
+		t.Errorf("NewIndexedReader with the index of another file should fail")
+	}
+}
@@ -0,0 +1,32 @@
+//Package cli holds the flag parsing shared by the silk commands
+package cli
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+//ParseSize parses a byte count with an optional K, M or G suffix such as
+//the --buffer-size of rwsort
+func ParseSize(value string) (size int, err error) {
+	var s = value
+	var multiplier = 1
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			multiplier = 1 << 10
+		case 'm', 'M':
+			multiplier = 1 << 20
+		case 'g', 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	if size, err = strconv.Atoi(s); err != nil || size <= 0 || size > math.MaxInt/multiplier {
+		return 0, fmt.Errorf("Invalid size:%q", value)
+	}
+	return size * multiplier, nil
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+//TestParseSize verifies byte counts with and without suffixes
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int{"512": 512, "4k": 4 << 10, "10M": 10 << 20, "2G": 2 << 30} {
+		if size, err := ParseSize(s); err != nil || size != expected {
+			t.Errorf("ParseSize:%q size:%d error:%v expected:%d", s, size, err, expected)
+		}
+	}
+	for _, s := range []string{"", "G", "0", "-1M", "1.5G", "1T", "9223372036854775807G", "xM"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize:%q expected error", s)
+		} else if strings.Contains(err.Error(), "\""+s+"\"") == false {
+			t.Errorf("ParseSize:%q error:%s expected the original value", s, err)
+		}
+	}
+}
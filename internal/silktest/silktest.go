@@ -0,0 +1,30 @@
+//Package silktest holds the test helpers shared by the silk packages
+package silktest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chrispassas/silk"
+)
+
+//ReadRecords returns every record of the silk file at filePath
+func ReadRecords(t testing.TB, filePath string) (records []silk.Record) {
+	t.Helper()
+	var f, err = os.Open(filePath)
+	if err != nil {
+		t.Fatalf("Open file:%s error:%s", filePath, err)
+	}
+	defer f.Close()
+	var sr *silk.Reader
+	if sr, err = silk.NewReader(f); err != nil {
+		t.Fatalf("NewReader file:%s error:%s", filePath, err)
+	}
+	for sr.Next() {
+		records = append(records, *sr.Record())
+	}
+	if err = sr.Err(); err != nil {
+		t.Fatalf("File:%s error:%s", filePath, err)
+	}
+	return
+}
@@ -0,0 +1,276 @@
+package ipset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/chrispassas/silk"
+)
+
+//writeTestSet returns an IPset file of body with header h
+func writeTestSet(t *testing.T, h silk.Header, body []byte) []byte {
+	var buf bytes.Buffer
+	h.RecordFormat = silk.FormatIPSet
+	var bw, err = silk.NewBodyWriter(&buf, h)
+	if err != nil {
+		t.Fatalf("NewBodyWriter error:%s", err)
+	}
+	if _, err = bw.Write(body); err != nil {
+		t.Fatalf("Write error:%s", err)
+	}
+	if err = bw.Close(); err != nil {
+		t.Fatalf("Close error:%s", err)
+	}
+	return buf.Bytes()
+}
+
+//testBody builds an IPset body in a byte order
+type testBody struct {
+	order binary.ByteOrder
+	b     []byte
+}
+
+func (tb *testBody) uint8(v uint8) *testBody {
+	tb.b = append(tb.b, v)
+	return tb
+}
+
+func (tb *testBody) uint32(v uint32) *testBody {
+	var b [4]byte
+	tb.order.PutUint32(b[:], v)
+	tb.b = append(tb.b, b[:]...)
+	return tb
+}
+
+func (tb *testBody) uint64(v uint64) *testBody {
+	var b [8]byte
+	tb.order.PutUint64(b[:], v)
+	tb.b = append(tb.b, b[:]...)
+	return tb
+}
+
+func (tb *testBody) ipv4(s string) *testBody {
+	var b = netip.MustParseAddr(s).As4()
+	return tb.uint32(binary.BigEndian.Uint32(b[:]))
+}
+
+func (tb *testBody) ipv6(s string) *testBody {
+	var b = netip.MustParseAddr(s).As16()
+	tb.b = append(tb.b, b[:]...)
+	return tb
+}
+
+func (tb *testBody) ipv6Halves(s string) *testBody {
+	var b = netip.MustParseAddr(s).As16()
+	return tb.uint64(binary.BigEndian.Uint64(b[:8])).uint64(binary.BigEndian.Uint64(b[8:]))
+}
+
+func (tb *testBody) padding(n int) *testBody {
+	tb.b = append(tb.b, make([]byte, n)...)
+	return tb
+}
+
+//bitmap appends a 256 bit bitmap with bits from to to set
+func (tb *testBody) bitmap(ranges ...[2]int) *testBody {
+	var words [8]uint32
+	for _, r := range ranges {
+		for n := r[0]; n <= r[1]; n++ {
+			words[n/32] |= 1 << uint(n%32)
+		}
+	}
+	for _, w := range words {
+		tb.uint32(w)
+	}
+	return tb
+}
+
+//fileFlags returns the header file flags of a byte order
+func fileFlags(order binary.ByteOrder) uint8 {
+	if order == binary.BigEndian {
+		return 1
+	}
+	return 0
+}
+
+//TestRead verifies each record version reads into the expected CIDR blocks
+func TestRead(t *testing.T) {
+	var le, be = binary.LittleEndian, binary.BigEndian
+	var tests = []struct {
+		name        string
+		header      silk.Header
+		body        *testBody
+		ipv6        bool
+		expected    string
+		count       string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:   "classic",
+			header: silk.Header{RecordVersion: 2},
+			body: (&testBody{order: le}).
+				ipv4("10.0.0.0").bitmap([2]int{0, 15}, [2]int{255, 255}).
+				ipv4("10.0.1.0").bitmap([2]int{0, 255}),
+			expected:    "10.0.0.0/28 10.0.0.255/32 10.0.1.0/24",
+			count:       "273",
+			contains:    []string{"10.0.0.15", "10.0.0.255", "10.0.1.128", "::ffff:10.0.0.1"},
+			notContains: []string{"10.0.0.16", "10.0.2.0", "2001:db8::1"},
+		},
+		{
+			name:   "classic big endian zlib",
+			header: silk.Header{RecordVersion: 1, Compression: 1},
+			body: (&testBody{order: be}).
+				ipv4("10.0.0.0").bitmap([2]int{0, 15}, [2]int{255, 255}).
+				ipv4("10.0.1.0").bitmap([2]int{0, 255}),
+			expected: "10.0.0.0/28 10.0.0.255/32 10.0.1.0/24",
+			count:    "273",
+		},
+		{
+			name: "radix IPv4",
+			header: silk.Header{RecordVersion: 3, VarLenHeaders: []silk.VarLenHeader{
+				silk.IPSetEntry{ChildNode: 2, LeafCount: 2, LeafSize: 8, NodeCount: 1, NodeSize: 16}.VarLenHeader(),
+			}},
+			//ipset_leaf_v4_t is the prefix, 3 reserved bytes and the address
+			body: (&testBody{order: le}).padding(16).
+				uint8(16).padding(3).ipv4("192.168.0.0").
+				uint8(8).padding(3).ipv4("10.0.0.0"),
+			expected:    "10.0.0.0/8 192.168.0.0/16",
+			count:       "16842752",
+			contains:    []string{"10.255.255.255", "192.168.3.4"},
+			notContains: []string{"11.0.0.0", "192.169.0.0"},
+		},
+		{
+			name: "radix IPv6 snappy",
+			header: silk.Header{RecordVersion: 3, Compression: 3, FileFlags: 1, VarLenHeaders: []silk.VarLenHeader{
+				silk.IPSetEntry{ChildNode: 2, LeafCount: 2, LeafSize: 24, NodeCount: 2, NodeSize: 32}.VarLenHeader(),
+			}},
+			//ipset_leaf_v6_t is the prefix, 7 reserved bytes and the address
+			body: (&testBody{order: be}).padding(64).
+				uint8(32).padding(7).ipv6Halves("2001:db8::").
+				uint8(120).padding(7).ipv6Halves("::ffff:1.2.3.0"),
+			ipv6:        true,
+			expected:    "::ffff:1.2.3.0/120 2001:db8::/32",
+			contains:    []string{"1.2.3.4", "2001:db8:ffff::1"},
+			notContains: []string{"1.2.4.0", "2001:db9::"},
+		},
+		{
+			name:   "CIDR bitmap IPv4 lzo",
+			header: silk.Header{RecordVersion: 4, RecordSize: 5, Compression: 2},
+			body: (&testBody{order: le}).
+				ipv4("172.16.0.0").uint8(12).
+				ipv4("172.32.0.0").uint8(11).
+				ipv4("10.9.8.0").uint8(0x81).bitmap([2]int{1, 2}),
+			expected:    "10.9.8.1/32 10.9.8.2/32 172.16.0.0/12 172.32.0.0/11",
+			contains:    []string{"10.9.8.2", "172.63.255.255"},
+			notContains: []string{"10.9.8.0", "10.9.8.3", "172.64.0.0"},
+		},
+		{
+			name:   "CIDR bitmap IPv6",
+			header: silk.Header{RecordVersion: 4, RecordSize: 17, FileFlags: 1},
+			body: (&testBody{order: be}).
+				ipv6("2001:db8:1::").uint8(48).
+				ipv6("2001:db8::100").uint8(0x81).bitmap([2]int{0, 255}),
+			ipv6:     true,
+			expected: "2001:db8::100/120 2001:db8:1::/48",
+			contains: []string{"2001:db8::1ff", "2001:db8:1:ffff::"},
+		},
+		{
+			name:   "slash64",
+			header: silk.Header{RecordVersion: 5},
+			body: (&testBody{order: le}).
+				uint64(0x20010db800000001).uint8(64).
+				uint64(0x20010db800000002).uint8(0x82).uint32(2).
+				uint64(1).uint8(128).
+				uint64(0x200).uint8(0x81).bitmap([2]int{0, 127}).
+				uint64(0x20010db900000000).uint8(48),
+			ipv6:        true,
+			expected:    "2001:db8:0:1::/64 2001:db8:0:2::1/128 2001:db8:0:2::200/121 2001:db9::/48",
+			contains:    []string{"2001:db8:0:1:ffff::", "2001:db8:0:2::27f"},
+			notContains: []string{"2001:db8:0:2::2", "2001:db8:0:2::280"},
+		},
+		{
+			name:     "slash64 everything",
+			header:   silk.Header{RecordVersion: 5, Compression: 1},
+			body:     (&testBody{order: le}).uint64(0).uint8(0),
+			ipv6:     true,
+			expected: "::/0",
+			count:    "340282366920938463463374607431768211456",
+			contains: []string{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "10.0.0.1"},
+		},
+	}
+	for _, test := range tests {
+		test.header.FileFlags |= fileFlags(test.body.order)
+		var s, err = Read(bytes.NewReader(writeTestSet(t, test.header, test.body.b)))
+		if err != nil {
+			t.Errorf("Set:%s error:%s", test.name, err)
+			continue
+		}
+		if s.IPv6() != test.ipv6 {
+			t.Errorf("Set:%s IPv6:%t expected:%t", test.name, s.IPv6(), test.ipv6)
+		}
+
+		var prefixes []string
+		var count = new(big.Int)
+		var it = s.Iterator()
+		for it.Next() {
+			prefixes = append(prefixes, it.Prefix().String())
+			var size = new(big.Int).Lsh(big.NewInt(1), uint(it.Prefix().Addr().BitLen()-it.Prefix().Bits()))
+			count.Add(count, size)
+		}
+		if strings.Join(prefixes, " ") != test.expected {
+			t.Errorf("Set:%s prefixes:%s expected:%s", test.name, strings.Join(prefixes, " "), test.expected)
+		}
+		if s.Blocks() != len(prefixes) {
+			t.Errorf("Set:%s blocks:%d expected:%d", test.name, s.Blocks(), len(prefixes))
+		}
+		if s.Count().Cmp(count) != 0 || (test.count != "" && s.Count().String() != test.count) {
+			t.Errorf("Set:%s count:%s expected:%s blocks:%s", test.name, s.Count(), test.count, count)
+		}
+		for _, addr := range test.contains {
+			if s.Contains(netip.MustParseAddr(addr)) == false {
+				t.Errorf("Set:%s contains:%s expected true", test.name, addr)
+			}
+		}
+		for _, addr := range test.notContains {
+			if s.Contains(netip.MustParseAddr(addr)) {
+				t.Errorf("Set:%s contains:%s expected false", test.name, addr)
+			}
+		}
+	}
+}
+
+//TestReadErrors verifies files that aren't valid IPsets return errors
+func TestReadErrors(t *testing.T) {
+	var le = binary.LittleEndian
+	var tests = []struct {
+		name   string
+		header silk.Header
+		body   []byte
+		err    string
+	}{
+		{name: "version", header: silk.Header{RecordVersion: 9}, err: "Unsupported IPset record version:9"},
+		{name: "truncated", header: silk.Header{RecordVersion: 2}, body: (&testBody{order: le}).ipv4("10.0.0.0").padding(10).b, err: "truncated"},
+		{name: "prefix", header: silk.Header{RecordVersion: 4, RecordSize: 5}, body: (&testBody{order: le}).ipv4("10.0.0.0").uint8(33).b, err: "Invalid IPset IPv4 prefix:33"},
+		{name: "slash64 prefix", header: silk.Header{RecordVersion: 5}, body: (&testBody{order: le}).uint64(1).uint8(0x82).uint32(1).uint64(1).uint8(10).b, err: "Invalid IPset prefix:10"},
+		{name: "radix entry", header: silk.Header{RecordVersion: 3}, err: "header entry 7 is missing"},
+		{name: "radix leaf size", header: silk.Header{RecordVersion: 3, VarLenHeaders: []silk.VarLenHeader{
+			silk.IPSetEntry{ChildNode: 2, LeafCount: 1, LeafSize: 17, NodeCount: 1, NodeSize: 32}.VarLenHeader(),
+		}}, err: "Unsupported IPset leaf size:17"},
+	}
+	for _, test := range tests {
+		var _, err = Read(bytes.NewReader(writeTestSet(t, test.header, test.body)))
+		if err == nil || strings.Contains(err.Error(), test.err) == false {
+			t.Errorf("Set:%s error:%v expected:%s", test.name, err, test.err)
+		}
+	}
+
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	if _, err := Open(filePath); err == nil || strings.Contains(err.Error(), "FT_RWIPV6ROUTING") == false {
+		t.Errorf("File:%s error:%v expected:%s", filePath, err, fmt.Sprintf("File format:%s is not FT_IPSET", "FT_RWIPV6ROUTING"))
+	}
+}
@@ -0,0 +1,313 @@
+/*
+Package ipset reads SiLK IPset files, FT_IPSET, written by rwset and
+rwsetbuild into an in-memory Set.
+
+	set, err := ipset.Open("watchlist.set")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if set.Contains(netip.MustParseAddr("10.1.2.3")) {
+		//...
+	}
+	it := set.Iterator()
+	for it.Next() {
+		fmt.Println(it.Prefix())
+	}
+
+Every record version is read, with any block compression. Numbers are in
+the byte order of the file flags, IPv6 addresses of version 4 are in network
+byte order.
+
+Versions 0 to 2 are the classic IPv4 format, a series of /24 blocks each
+written as the block's address followed by a 256 bit bitmap of 8 uint32s.
+Bit n of word w is the address 32*w+n of the block.
+
+Version 3 is the radix tree, header entry 7 gives the number and size of the
+tree's nodes and leaves. The nodes come first and are skipped, each leaf is
+a CIDR block laid out like skipset.c's ipset_leaf_v4_t and ipset_leaf_v6_t:
+the prefix length, padding, then the address. An 8 byte IPv4 leaf holds the
+address as a uint32 at offset 4, a 24 byte IPv6 leaf as two uint64 halves
+at offset 8.
+
+Version 4 is a series of CIDR blocks and bitmaps, each entry is an address,
+a uint32 for IPv4 or 16 bytes for IPv6 when the record size is 17, and a
+prefix length. A prefix of 0x81 is followed by the 256 bit bitmap of the /24
+or /120 block at the address.
+
+Version 5 is IPv6 only, a series of entries starting with the upper 64 bits
+of an address as a uint64 and a prefix length. A prefix up to 64 is a CIDR
+block. A prefix of 0x82 is followed by a uint32 count of entries within the
+/64, each the lower 64 bits as a uint64 and a prefix length from 65 to 128,
+or 0x81 followed by the 256 bit bitmap of the /120 block.
+
+These layouts follow skipset.c but the tests build their bodies by hand,
+no file written by rwset or rwsetbuild has been read yet.
+*/
+package ipset
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+
+	"github.com/chrispassas/silk"
+)
+
+//Prefix values that mark a bitmap or a /64 of entries instead of a CIDR block
+const (
+	prefixBitmap  uint8 = 0x81
+	prefixSlash64 uint8 = 0x82
+)
+
+//Radix tree leaf sizes, sizeof(ipset_leaf_v4_t) and sizeof(ipset_leaf_v6_t)
+const (
+	leafSizeIPv4 = 8
+	leafSizeIPv6 = 24
+)
+
+//Open reads the IPset file at filePath
+func Open(filePath string) (s *Set, err error) {
+	var f *os.File
+	if f, err = os.Open(filePath); err != nil {
+		return
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+//Read reads an IPset file from r
+func Read(r io.Reader) (s *Set, err error) {
+	var br *silk.BodyReader
+	if br, err = silk.NewBodyReader(r); err != nil {
+		return
+	}
+	var h = br.Header()
+	if h.RecordFormat != silk.FormatIPSet {
+		err = fmt.Errorf("File format:%s is not FT_IPSET", silk.FormatName(h.RecordFormat))
+		return
+	}
+
+	var d = decoder{r: bufio.NewReader(br), order: binary.LittleEndian}
+	if h.FileFlags != 0 {
+		d.order = binary.BigEndian
+	}
+	var ipv6 bool
+	switch h.RecordVersion {
+	case 0, 1, 2:
+		err = d.readClassic()
+	case 3:
+		ipv6, err = d.readRadix(h)
+	case 4:
+		ipv6 = h.RecordSize == 17
+		err = d.readCIDRBitmap(ipv6)
+	case 5:
+		ipv6 = true
+		err = d.readSlash64()
+	default:
+		err = fmt.Errorf("Unsupported IPset record version:%d", h.RecordVersion)
+	}
+	if err != nil {
+		return
+	}
+	return newSet(d.ranges, ipv6), nil
+}
+
+//decoder collects the ranges of an IPset body
+type decoder struct {
+	r      *bufio.Reader
+	order  binary.ByteOrder
+	buf    [36]byte
+	ranges []ipRange
+}
+
+//read reads n bytes, io.EOF is only returned when no bytes remain and
+//first is set, the start of an entry
+func (d *decoder) read(n int, first bool) (b []byte, err error) {
+	b = d.buf[:n]
+	if _, err = io.ReadFull(d.r, b); err == io.EOF && first {
+		return
+	} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("IPset file is truncated")
+	}
+	return
+}
+
+//addPrefix adds the CIDR block of ip with prefix bits of 128
+func (d *decoder) addPrefix(ip uint128, prefix int) {
+	d.ranges = append(d.ranges, prefixRange(ip, prefix))
+}
+
+//addBitmap adds the addresses of a 256 bit bitmap of 8 words for the block
+//at base, consecutive addresses are added as one range
+func (d *decoder) addBitmap(base uint128, words []byte) {
+	base = prefixRange(base, 120).start
+	var start = -1
+	for n := 0; n <= 256; n++ {
+		var set = n < 256 && d.order.Uint32(words[n/32*4:])&(1<<uint(n%32)) != 0
+		if set && start < 0 {
+			start = n
+		} else if set == false && start >= 0 {
+			d.ranges = append(d.ranges, ipRange{
+				start: base.or(uint128{lo: uint64(start)}),
+				end:   base.or(uint128{lo: uint64(n - 1)}),
+			})
+			start = -1
+		}
+	}
+}
+
+//readClassic reads versions 0 to 2, /24 blocks of a uint32 address and bitmap
+func (d *decoder) readClassic() (err error) {
+	for {
+		var b []byte
+		if b, err = d.read(36, true); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return
+		}
+		d.addBitmap(fromIPv4(d.order.Uint32(b[0:4])), b[4:36])
+	}
+}
+
+//readRadix reads version 3, skipping the nodes and adding the leaves
+func (d *decoder) readRadix(h silk.Header) (ipv6 bool, err error) {
+	var e, ok = h.IPSet()
+	if ok == false {
+		err = fmt.Errorf("IPset header entry 7 is missing")
+		return
+	}
+	switch e.LeafSize {
+	case leafSizeIPv4:
+	case leafSizeIPv6:
+		ipv6 = true
+	default:
+		err = fmt.Errorf("Unsupported IPset leaf size:%d", e.LeafSize)
+		return
+	}
+	var overflow, nodes = bits.Mul64(uint64(e.NodeCount), uint64(e.NodeSize))
+	if overflow != 0 {
+		err = fmt.Errorf("Invalid IPset node count:%d", e.NodeCount)
+		return
+	}
+	if _, err = io.CopyN(io.Discard, d.r, int64(nodes)); err != nil {
+		err = fmt.Errorf("IPset file is truncated")
+		return
+	}
+
+	for x := uint32(0); x < e.LeafCount; x++ {
+		var b []byte
+		if b, err = d.read(int(e.LeafSize), false); err != nil {
+			return
+		}
+		if ipv6 {
+			err = d.addIPv6(uint128{hi: d.order.Uint64(b[8:16]), lo: d.order.Uint64(b[16:24])}, b[0])
+		} else {
+			err = d.addIPv4(d.order.Uint32(b[4:8]), b[0])
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//addIPv4 adds the CIDR block of an IPv4 address
+func (d *decoder) addIPv4(ip uint32, prefix uint8) error {
+	if prefix > 32 {
+		return fmt.Errorf("Invalid IPset IPv4 prefix:%d", prefix)
+	}
+	d.addPrefix(fromIPv4(ip), int(prefix)+96)
+	return nil
+}
+
+//addIPv6 adds the CIDR block of an IPv6 address
+func (d *decoder) addIPv6(ip uint128, prefix uint8) error {
+	if prefix > 128 {
+		return fmt.Errorf("Invalid IPset IPv6 prefix:%d", prefix)
+	}
+	d.addPrefix(ip, int(prefix))
+	return nil
+}
+
+//readCIDRBitmap reads version 4, CIDR blocks and bitmaps
+func (d *decoder) readCIDRBitmap(ipv6 bool) (err error) {
+	var size = 5
+	if ipv6 {
+		size = 17
+	}
+	for {
+		var b []byte
+		if b, err = d.read(size, true); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return
+		}
+		var ip uint128
+		if ipv6 {
+			ip = uint128{hi: binary.BigEndian.Uint64(b[0:8]), lo: binary.BigEndian.Uint64(b[8:16])}
+		} else {
+			ip = fromIPv4(d.order.Uint32(b[0:4]))
+		}
+		var prefix = b[size-1]
+
+		switch {
+		case prefix == prefixBitmap:
+			if b, err = d.read(32, false); err != nil {
+				return
+			}
+			d.addBitmap(ip, b)
+		case ipv6:
+			err = d.addIPv6(ip, prefix)
+		default:
+			err = d.addIPv4(uint32(ip.lo), prefix)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+//readSlash64 reads version 5, /64 blocks and the entries within a /64
+func (d *decoder) readSlash64() (err error) {
+	for {
+		var b []byte
+		if b, err = d.read(9, true); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return
+		}
+		var hi = d.order.Uint64(b[0:8])
+		var prefix = b[8]
+		if prefix <= 64 {
+			d.addPrefix(uint128{hi: hi}, int(prefix))
+			continue
+		} else if prefix != prefixSlash64 {
+			return fmt.Errorf("Invalid IPset /64 prefix:%d", prefix)
+		}
+
+		if b, err = d.read(4, false); err != nil {
+			return
+		}
+		for count := d.order.Uint32(b); count > 0; count-- {
+			if b, err = d.read(9, false); err != nil {
+				return
+			}
+			var ip = uint128{hi: hi, lo: d.order.Uint64(b[0:8])}
+			prefix = b[8]
+			switch {
+			case prefix == prefixBitmap:
+				if b, err = d.read(32, false); err != nil {
+					return
+				}
+				d.addBitmap(ip, b)
+			case prefix > 64 && prefix <= 128:
+				d.addPrefix(ip, int(prefix))
+			default:
+				return fmt.Errorf("Invalid IPset prefix:%d within a /64", prefix)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package ipset
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	stdsort "sort"
+)
+
+//uint128 is an IPv6 address as a number, IPv4 addresses are IPv4 mapped
+type uint128 struct {
+	hi uint64
+	lo uint64
+}
+
+//maxUint128 is the last IPv6 address
+var maxUint128 = uint128{hi: ^uint64(0), lo: ^uint64(0)}
+
+//fromAddr returns addr as a number, IPv4 addresses are mapped into ::ffff:0:0/96
+func fromAddr(addr netip.Addr) uint128 {
+	var b = addr.As16()
+	return uint128{hi: binary.BigEndian.Uint64(b[:8]), lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+//fromIPv4 returns an IPv4 address as a number mapped into ::ffff:0:0/96
+func fromIPv4(ip uint32) uint128 {
+	return uint128{lo: 0xFFFF<<32 | uint64(ip)}
+}
+
+//addr returns the address of the number, unmapped when ipv4 is set
+func (u uint128) addr(ipv4 bool) netip.Addr {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.hi)
+	binary.BigEndian.PutUint64(b[8:], u.lo)
+	var addr = netip.AddrFrom16(b)
+	if ipv4 {
+		return addr.Unmap()
+	}
+	return addr
+}
+
+func (u uint128) less(v uint128) bool {
+	return u.hi < v.hi || (u.hi == v.hi && u.lo < v.lo)
+}
+
+func (u uint128) addOne() uint128 {
+	var lo, carry = bits.Add64(u.lo, 1, 0)
+	return uint128{hi: u.hi + carry, lo: lo}
+}
+
+func (u uint128) sub(v uint128) uint128 {
+	var lo, borrow = bits.Sub64(u.lo, v.lo, 0)
+	return uint128{hi: u.hi - v.hi - borrow, lo: lo}
+}
+
+func (u uint128) or(v uint128) uint128 {
+	return uint128{hi: u.hi | v.hi, lo: u.lo | v.lo}
+}
+
+//mask returns a number with the low n bits set
+func mask(n int) uint128 {
+	switch {
+	case n <= 0:
+		return uint128{}
+	case n < 64:
+		return uint128{lo: 1<<uint(n) - 1}
+	case n < 128:
+		return uint128{hi: 1<<uint(n-64) - 1, lo: ^uint64(0)}
+	}
+	return maxUint128
+}
+
+//trailingZeros returns the number of low zero bits, 128 for zero
+func (u uint128) trailingZeros() int {
+	if u.lo != 0 {
+		return bits.TrailingZeros64(u.lo)
+	}
+	return 64 + bits.TrailingZeros64(u.hi)
+}
+
+//ipRange is an inclusive range of addresses
+type ipRange struct {
+	start uint128
+	end   uint128
+}
+
+//prefixRange returns the range of the block at ip with prefix bits of 128,
+//host bits of ip are ignored
+func prefixRange(ip uint128, prefix int) ipRange {
+	var host = mask(128 - prefix)
+	var start = uint128{hi: ip.hi &^ host.hi, lo: ip.lo &^ host.lo}
+	return ipRange{start: start, end: start.or(host)}
+}
+
+//Set is an IPset held in memory as sorted, non overlapping address ranges.
+//IPv4 sets hold IPv4 addresses, IPv6 sets hold IPv6 addresses with IPv4
+//addresses IPv4 mapped like SiLK.
+type Set struct {
+	ranges []ipRange
+	ipv6   bool
+}
+
+//newSet returns a Set of the ranges sorted and merged
+func newSet(ranges []ipRange, ipv6 bool) *Set {
+	stdsort.Slice(ranges, func(i, j int) bool { return ranges[i].start.less(ranges[j].start) })
+	var merged = ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			var last = &merged[n-1]
+			if last.end == maxUint128 || r.start.less(last.end.addOne()) || r.start == last.end.addOne() {
+				if last.end.less(r.end) {
+					last.end = r.end
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return &Set{ranges: merged, ipv6: ipv6}
+}
+
+//IPv6 returns true for an IPv6 set
+func (s *Set) IPv6() bool {
+	return s.ipv6
+}
+
+//Contains returns true when addr is in the set. An IPv4 address is found in
+//an IPv6 set as its IPv4 mapped address and an IPv4 mapped address is found
+//in an IPv4 set as its IPv4 address.
+func (s *Set) Contains(addr netip.Addr) bool {
+	if s.ipv6 == false && addr.Unmap().Is4() == false {
+		return false
+	}
+	var u = fromAddr(addr)
+	var i = stdsort.Search(len(s.ranges), func(i int) bool { return u.less(s.ranges[i].end) || u == s.ranges[i].end })
+	return i < len(s.ranges) && (s.ranges[i].start.less(u) || s.ranges[i].start == u)
+}
+
+//Count returns the number of addresses in the set, an IPv6 set may hold up to 2^128
+func (s *Set) Count() *big.Int {
+	var count, size = new(big.Int), new(big.Int)
+	var hi = new(big.Int)
+	for _, r := range s.ranges {
+		var n = r.end.sub(r.start)
+		hi.SetUint64(n.hi)
+		size.Lsh(hi, 64)
+		size.Add(size, new(big.Int).SetUint64(n.lo))
+		count.Add(count, size)
+		count.Add(count, big.NewInt(1))
+	}
+	return count
+}
+
+//Blocks returns the number of CIDR blocks the Iterator returns
+func (s *Set) Blocks() (n int) {
+	var it = s.Iterator()
+	for it.Next() {
+		n++
+	}
+	return
+}
+
+//Iterator returns an Iterator of the set's CIDR blocks
+func (s *Set) Iterator() *Iterator {
+	var it = &Iterator{set: s}
+	if len(s.ranges) > 0 {
+		it.cursor = s.ranges[0].start
+	}
+	return it
+}
+
+//Iterator returns the fewest CIDR blocks covering a Set in address order
+//in the style of bufio.Scanner
+//
+//	it := set.Iterator()
+//	for it.Next() {
+//		fmt.Println(it.Prefix())
+//	}
+type Iterator struct {
+	set    *Set
+	index  int
+	cursor uint128
+	prefix netip.Prefix
+}
+
+//Next advances to the next CIDR block, it returns false after the last one
+func (it *Iterator) Next() bool {
+	if it.index >= len(it.set.ranges) {
+		return false
+	}
+	var r = it.set.ranges[it.index]
+
+	//the largest block aligned at the cursor that ends within the range
+	var size = it.cursor.trailingZeros()
+	if size > 128 {
+		size = 128
+	}
+	var last = it.cursor.or(mask(size))
+	for r.end.less(last) {
+		size--
+		last = it.cursor.or(mask(size))
+	}
+
+	var bits = 128 - size
+	if it.set.ipv6 {
+		it.prefix = netip.PrefixFrom(it.cursor.addr(false), bits)
+	} else {
+		it.prefix = netip.PrefixFrom(it.cursor.addr(true), bits-96)
+	}
+
+	if last == r.end {
+		it.index++
+		if it.index < len(it.set.ranges) {
+			it.cursor = it.set.ranges[it.index].start
+		}
+	} else {
+		it.cursor = last.addOne()
+	}
+	return true
+}
+
+//Prefix returns the current CIDR block
+func (it *Iterator) Prefix() netip.Prefix {
+	return it.prefix
+}
@@ -0,0 +1,117 @@
+package silk
+
+import (
+	"encoding/binary"
+)
+
+//Silk file format ids of the legacy IPv4 packed record formats written by
+//rwflowpack before IPv6 support. Like the augmented formats their start
+//times are an offset from the hour in variable length header entry 1.
+const (
+	FormatRWRouted    uint8 = 0x10
+	FormatRWNotRouted uint8 = 0x11
+	FormatRWSplit     uint8 = 0x12
+	FormatRWFilter    uint8 = 0x13 //not decoded, reading it returns an UnsupportedFormatError
+	FormatRWWWW       uint8 = 0x1F
+)
+
+//legacyVersion is the record version of the legacy packed formats that is supported
+const legacyVersion uint16 = 5
+
+//legacyRecordSizes maps each legacy packed format to its version 5 record size.
+//Every format starts with the stime_bb1, bb2_elapsed and pro_flg_pkts words
+//decoded by unpackStimeBppPkts. None of them store TCP state so the packed
+//protocol/flags byte holds the flags of all packets for TCP flows.
+//
+//FT_RWFILTER is not decoded, its layout has not been verified against
+//rwfilterio.c or files written by rwfilter and reading it returns an
+//UnsupportedFormatError.
+//
+//	FT_RWSPLIT      24 bytes
+//	12-13 sPort
+//	14-15 dPort
+//	16-19 sIP
+//	20-23 dIP
+//
+//	FT_RWNOTROUTED  26 bytes
+//	12-13 sPort
+//	14-15 dPort
+//	16-17 input
+//	18-21 sIP
+//	22-25 dIP
+//
+//	FT_RWROUTED     32 bytes
+//	12-13 sPort
+//	14-15 dPort
+//	16-17 input
+//	18-19 output
+//	20-23 sIP
+//	24-27 dIP
+//	28-31 nhIP
+//
+//	FT_RWWWW        22 bytes, pro_flg_pkts padding is the server port index
+//	and is_tcp is set when the source is the server
+//	12-15 sIP
+//	16-19 dIP
+//	20-21 client port
+var legacyRecordSizes = map[uint8]uint16{
+	FormatRWSplit:     24,
+	FormatRWNotRouted: 26,
+	FormatRWRouted:    32,
+	FormatRWWWW:       22,
+}
+
+func init() {
+	for format, size := range legacyRecordSizes {
+		registerFormat(format, legacyVersion, recordFormat{size: size, packed: true, decode: decodeLegacy})
+	}
+}
+
+//decodeLegacy decodes a version 5 legacy packed record into silkFlow
+func decodeLegacy(record []byte, h *Header, silkFlow *Record) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if h.FileFlags != 0 {
+		order = binary.BigEndian
+	}
+
+	*silkFlow = Record{}
+	protFlags, isTCP, padding := unpackStimeBppPkts(record, order, h, silkFlow)
+	silkFlow.Sensor = uint16(h.fileSensor)
+	silkFlow.ClassType = uint8(h.fileFlowType)
+
+	if h.RecordFormat == FormatRWWWW {
+		//Web flows are always TCP, the is_tcp bit says which side is the server
+		unpackProtoFlags(silkFlow, true, protFlags, 0, 0)
+		silkFlow.SrcIP = decodeIPv4(record[12:16], order)
+		silkFlow.DstIP = decodeIPv4(record[16:20], order)
+		var clientPort = order.Uint16(record[20:22])
+		if isTCP {
+			silkFlow.SrcPort = webPorts[padding]
+			silkFlow.DstPort = clientPort
+		} else {
+			silkFlow.SrcPort = clientPort
+			silkFlow.DstPort = webPorts[padding]
+		}
+		return
+	}
+
+	unpackProtoFlags(silkFlow, isTCP, protFlags, 0, 0)
+	silkFlow.SrcPort = order.Uint16(record[12:14])
+	silkFlow.DstPort = order.Uint16(record[14:16])
+
+	switch h.RecordFormat {
+	case FormatRWNotRouted:
+		silkFlow.SNMPIn = order.Uint16(record[16:18])
+		silkFlow.SrcIP = decodeIPv4(record[18:22], order)
+		silkFlow.DstIP = decodeIPv4(record[22:26], order)
+	case FormatRWRouted:
+		silkFlow.SNMPIn = order.Uint16(record[16:18])
+		silkFlow.SNMPOut = order.Uint16(record[18:20])
+		silkFlow.SrcIP = decodeIPv4(record[20:24], order)
+		silkFlow.DstIP = decodeIPv4(record[24:28], order)
+		silkFlow.NextHopIP = decodeIPv4(record[28:32], order)
+	default:
+		silkFlow.SrcIP = decodeIPv4(record[16:20], order)
+		silkFlow.DstIP = decodeIPv4(record[20:24], order)
+	}
+}
@@ -0,0 +1,128 @@
+package silk
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+//getLegacyTestFlows returns the flows stored in the FT_RWROUTED test files. Fields a format does not store are cleared by the caller.
+func getLegacyTestFlows() []Flow {
+	return []Flow{
+		{
+			SrcIP:       net.ParseIP("192.168.40.20"),
+			DstIP:       net.ParseIP("10.0.40.54"),
+			SrcPort:     88,
+			DstPort:     60339,
+			Proto:       6,
+			Packets:     4,
+			Bytes:       373,
+			Flags:       30,
+			StartTimeMS: 1434553200013,
+			Duration:    6,
+			Sensor:      3,
+			SNMPIn:      7,
+			SNMPOut:     9,
+			NextHopIP:   net.ParseIP("10.0.0.254"),
+			ClassType:   1,
+		},
+		{
+			SrcIP:       net.ParseIP("192.168.20.58"),
+			DstIP:       net.ParseIP("128.63.2.53"),
+			SrcPort:     29070,
+			DstPort:     53,
+			Proto:       17,
+			Packets:     1,
+			Bytes:       74,
+			StartTimeMS: 1434553200025,
+			Sensor:      3,
+			SNMPIn:      7,
+			SNMPOut:     9,
+			NextHopIP:   net.ParseIP("10.0.0.254"),
+			ClassType:   1,
+		},
+		{
+			SrcIP:       net.ParseIP("10.0.0.1"),
+			DstIP:       net.ParseIP("10.0.0.2"),
+			SrcPort:     443,
+			DstPort:     51000,
+			Proto:       6,
+			Packets:     2000000,
+			Bytes:       3000000000,
+			Flags:       0x13,
+			StartTimeMS: 1434556799999,
+			Duration:    4000000,
+			Sensor:      3,
+			SNMPIn:      65535,
+			SNMPOut:     1,
+			NextHopIP:   net.ParseIP("0.0.0.0"),
+			ClassType:   1,
+		},
+	}
+}
+
+//getTestDataLegacy returns the legacy packed test files and their expected flows
+func getTestDataLegacy() []testDetails {
+	var routed, notRouted, split, www []Flow
+	for _, flow := range getLegacyTestFlows() {
+		routed = append(routed, flow)
+		flow.SNMPOut = 0
+		flow.NextHopIP = nil
+		notRouted = append(notRouted, flow)
+		flow.SNMPIn = 0
+		split = append(split, flow)
+	}
+	//The web format shares its layout with FT_RWAUGWEB without the TCP state bytes
+	for _, flow := range getAugmentedWebTestFlows() {
+		flow.InitalFlags = 0
+		flow.SessionFlags = 0
+		flow.Attributes = 0
+		flow.Application = 0
+		www = append(www, flow)
+	}
+
+	var testData []testDetails
+	for _, test := range []struct {
+		name  string
+		flows []Flow
+	}{
+		{"FT_RWROUTED", routed},
+		{"FT_RWNOTROUTED", notRouted},
+		{"FT_RWSPLIT", split},
+		{"FT_RWWWW", www},
+	} {
+		testData = append(testData, testDetails{
+			files: []string{
+				"testdata/" + test.name + "-v5-c0-L.dat",
+				"testdata/" + test.name + "-v5-c0-B.dat",
+				"testdata/" + test.name + "-v5-c1-L.dat",
+				"testdata/" + test.name + "-v5-c1-B.dat",
+			},
+			flows: test.flows,
+		})
+	}
+	return testData
+}
+
+//TestLegacyFiles reads each legacy packed format and byte order and verifies every field
+func TestLegacyFiles(t *testing.T) {
+	for _, testFlowData := range getTestDataLegacy() {
+		for _, filePath := range testFlowData.files {
+			var sf File
+			var err error
+			if sf, err = OpenFile(filePath); err != nil {
+				t.Errorf("OpenFile file:%s error:%s", filePath, err)
+				continue
+			}
+			if len(sf.Flows) != len(testFlowData.flows) {
+				t.Errorf("File:%s Rows found:%d, rows expected:%d", filePath, len(sf.Flows), len(testFlowData.flows))
+				continue
+			}
+			for x := range testFlowData.flows {
+				if reflect.DeepEqual(sf.Flows[x], testFlowData.flows[x]) == false {
+					t.Errorf("File:%s row:%d flow:%+v expected:%+v", filePath, x, sf.Flows[x], testFlowData.flows[x])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package silk
+
+import (
+	"bufio"
+	"container/heap"
+	"os"
+)
+
+//CompareFunc returns -1, 0 or 1 as record a sorts before, with or after record b
+type CompareFunc func(a, b *Record) int
+
+//CompareStartTime orders records by StartTimeMS, the order of silk files
+//written by rwflowpack
+func CompareStartTime(a, b *Record) int {
+	if a.StartTimeMS < b.StartTimeMS {
+		return -1
+	}
+	if a.StartTimeMS > b.StartTimeMS {
+		return 1
+	}
+	return 0
+}
+
+//MergeReader reads the records of several Readers, each already sorted by
+//the same order, as a single sorted stream. Only the current block of each
+//Reader is held in memory. Records that compare equal are returned in the
+//order of their Readers.
+//
+//	mr, err := silk.OpenMergeReader(paths, silk.CompareStartTime)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer mr.Close()
+//	for mr.Next() {
+//		record := mr.Record()
+//		//...
+//	}
+//	if err = mr.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+type MergeReader struct {
+	readers []*Reader
+	compare CompareFunc
+	heap    mergeHeap
+	files   []*os.File
+	started bool
+	current *Reader
+	err     error
+}
+
+//NewMergeReader returns a MergeReader of readers ordered by compare,
+//CompareStartTime when nil. Filters set on the readers with SetFilter
+//apply to the merged records.
+func NewMergeReader(readers []*Reader, compare CompareFunc) *MergeReader {
+	if compare == nil {
+		compare = CompareStartTime
+	}
+	var mr = &MergeReader{readers: readers, compare: compare}
+	mr.heap.mr = mr
+	return mr
+}
+
+//OpenMergeReader opens the silk files at paths and returns a MergeReader
+//of them ordered by compare, CompareStartTime when nil. Close closes the files.
+func OpenMergeReader(paths []string, compare CompareFunc) (mr *MergeReader, err error) {
+	var readers = make([]*Reader, 0, len(paths))
+	var files = make([]*os.File, 0, len(paths))
+	for _, path := range paths {
+		var f *os.File
+		var sr *Reader
+		if f, err = os.Open(path); err == nil {
+			files = append(files, f)
+			sr, err = NewReader(bufio.NewReader(f))
+		}
+		if err != nil {
+			for _, f = range files {
+				f.Close()
+			}
+			return
+		}
+		readers = append(readers, sr)
+	}
+	mr = NewMergeReader(readers, compare)
+	mr.files = files
+	return
+}
+
+//Next advances to the next record in merged order. It returns false after
+//the last record of every Reader or when a Reader fails, use Err to tell
+//them apart.
+func (mr *MergeReader) Next() bool {
+	if mr.err != nil {
+		return false
+	}
+	if mr.started == false {
+		mr.started = true
+		for x, sr := range mr.readers {
+			if mr.advance(sr) {
+				mr.heap.order = append(mr.heap.order, x)
+			} else if mr.err != nil {
+				return false
+			}
+		}
+		heap.Init(&mr.heap)
+	} else if mr.current != nil {
+		//the current record is the top of the heap until its Reader advances
+		if mr.advance(mr.current) {
+			heap.Fix(&mr.heap, 0)
+		} else if mr.err != nil {
+			return false
+		} else {
+			heap.Pop(&mr.heap)
+		}
+	}
+	if len(mr.heap.order) == 0 {
+		mr.current = nil
+		return false
+	}
+	mr.current = mr.readers[mr.heap.order[0]]
+	return true
+}
+
+//advance moves sr to its next record, it returns false at the end of the
+//Reader and sets err when the Reader failed
+func (mr *MergeReader) advance(sr *Reader) bool {
+	if sr.Next() {
+		return true
+	}
+	mr.err = sr.Err()
+	return false
+}
+
+//Record returns the current record, it is only valid until the next call to Next
+func (mr *MergeReader) Record() *Record {
+	if mr.current == nil {
+		return nil
+	}
+	return mr.current.Record()
+}
+
+//Flow returns the current record as a Flow, it is only valid until the next call to Next
+func (mr *MergeReader) Flow() *Flow {
+	if mr.current == nil {
+		return nil
+	}
+	return mr.current.Flow()
+}
+
+//Source returns the position in the readers or paths of the Reader of the
+//current record, -1 when there is no current record
+func (mr *MergeReader) Source() int {
+	if mr.current == nil {
+		return -1
+	}
+	return mr.heap.order[0]
+}
+
+//Err returns the first error of any Reader
+func (mr *MergeReader) Err() error {
+	return mr.err
+}
+
+//Close closes the Readers and the files opened by OpenMergeReader
+func (mr *MergeReader) Close() (err error) {
+	for _, sr := range mr.readers {
+		sr.Close()
+	}
+	for _, f := range mr.files {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	mr.files = nil
+	mr.current = nil
+	mr.heap.order = nil
+	return
+}
+
+//mergeHeap orders the positions of the readers with a current record by
+//that record, ties by position
+type mergeHeap struct {
+	mr    *MergeReader
+	order []int
+}
+
+func (h mergeHeap) Len() int { return len(h.order) }
+func (h mergeHeap) Less(i, j int) bool {
+	var a, b = h.order[i], h.order[j]
+	if c := h.mr.compare(h.mr.readers[a].Record(), h.mr.readers[b].Record()); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+func (h mergeHeap) Swap(i, j int)       { h.order[i], h.order[j] = h.order[j], h.order[i] }
+func (h *mergeHeap) Push(x interface{}) { h.order = append(h.order, x.(int)) }
+func (h *mergeHeap) Pop() interface{} {
+	var x = h.order[len(h.order)-1]
+	h.order = h.order[:len(h.order)-1]
+	return x
+}
@@ -0,0 +1,152 @@
+package silk
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+//mergeTestRecord is a record and the position of the file it was written to
+type mergeTestRecord struct {
+	record Record
+	file   int
+}
+
+//writeMergeTestFiles splits the records of a test file round robin into
+//start time sorted files in dir, it returns their paths and every
+//record in the order they should merge in
+func writeMergeTestFiles(t *testing.T, filePath string, dir string, files int) (paths []string, expected []mergeTestRecord) {
+	var f, err = os.Open(filePath)
+	if err != nil {
+		t.Fatalf("Open file:%s error:%s", filePath, err)
+	}
+	defer f.Close()
+	var sr *Reader
+	if sr, err = NewReader(f); err != nil {
+		t.Fatalf("NewReader file:%s error:%s", filePath, err)
+	}
+	var split = make([][]Record, files)
+	for sr.Next() && len(expected) < 50000 {
+		var file = len(expected) % files
+		split[file] = append(split[file], *sr.Record())
+		expected = append(expected, mergeTestRecord{record: *sr.Record(), file: file})
+	}
+	if err = sr.Err(); err != nil {
+		t.Fatalf("File:%s error:%s", filePath, err)
+	}
+
+	for x, records := range split {
+		sort.SliceStable(records, func(i, j int) bool { return records[i].StartTimeMS < records[j].StartTimeMS })
+		var buf bytes.Buffer
+		var sw *Writer
+		if sw, err = NewWriter(&buf, Header{RecordFormat: FormatRWIPV6Routing, RecordVersion: 1, Compression: 3}); err != nil {
+			t.Fatalf("NewWriter error:%s", err)
+		}
+		for y := range records {
+			if err = sw.WriteRecord(&records[y]); err != nil {
+				t.Fatalf("WriteRecord error:%s", err)
+			}
+		}
+		if err = sw.Close(); err != nil {
+			t.Fatalf("Close error:%s", err)
+		}
+		var path = filepath.Join(dir, fmt.Sprintf("%s-%d", filepath.Base(filePath), x))
+		if err = os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("WriteFile:%s error:%s", path, err)
+		}
+		paths = append(paths, path)
+	}
+	sort.SliceStable(expected, func(i, j int) bool {
+		if expected[i].record.StartTimeMS != expected[j].record.StartTimeMS {
+			return expected[i].record.StartTimeMS < expected[j].record.StartTimeMS
+		}
+		return expected[i].file < expected[j].file
+	})
+	return
+}
+
+//TestMergeReader verifies files merge into start time order with ties in file order
+func TestMergeReader(t *testing.T) {
+	var filePath = "testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var paths, expected = writeMergeTestFiles(t, filePath, t.TempDir(), 5)
+
+	var mr, err = OpenMergeReader(paths, nil)
+	if err != nil {
+		t.Fatalf("OpenMergeReader error:%s", err)
+	}
+	if mr.Record() != nil || mr.Source() != -1 {
+		t.Errorf("File:%s Record() before Next() should be nil", filePath)
+	}
+	var x int
+	for mr.Next() {
+		if x < len(expected) && (*mr.Record() != expected[x].record || mr.Source() != expected[x].file) {
+			t.Fatalf("File:%s row:%d record:%+v source:%d expected:%+v source:%d", filePath, x, *mr.Record(), mr.Source(), expected[x].record, expected[x].file)
+		}
+		if mr.Flow().StartTimeMS != mr.Record().StartTimeMS {
+			t.Fatalf("File:%s row:%d flow:%+v record:%+v", filePath, x, *mr.Flow(), *mr.Record())
+		}
+		x++
+	}
+	if err = mr.Err(); err != nil {
+		t.Errorf("File:%s error:%s", filePath, err)
+	}
+	if x != len(expected) {
+		t.Errorf("File:%s rows:%d expected:%d", filePath, x, len(expected))
+	}
+	if mr.Next() {
+		t.Errorf("File:%s Next() after the end should be false", filePath)
+	}
+	if err = mr.Close(); err != nil {
+		t.Errorf("Close error:%s", err)
+	}
+}
+
+//TestMergeReaderCompare verifies a custom order and readers without records
+func TestMergeReaderCompare(t *testing.T) {
+	var readers []*Reader
+	for _, values := range [][]uint32{{9, 5, 1}, {}, {8, 5, 2}} {
+		var buf bytes.Buffer
+		var sw, err = NewWriter(&buf, Header{RecordFormat: FormatRWIPV6Routing, RecordVersion: 1})
+		if err != nil {
+			t.Fatalf("NewWriter error:%s", err)
+		}
+		for _, bytes := range values {
+			sw.WriteRecord(&Record{Bytes: bytes})
+		}
+		sw.Close()
+		var sr *Reader
+		if sr, err = NewReader(&buf); err != nil {
+			t.Fatalf("NewReader error:%s", err)
+		}
+		readers = append(readers, sr)
+	}
+
+	var mr = NewMergeReader(readers, func(a, b *Record) int { return int(b.Bytes) - int(a.Bytes) })
+	var found []uint32
+	var sources []int
+	for mr.Next() {
+		found = append(found, mr.Record().Bytes)
+		sources = append(sources, mr.Source())
+	}
+	var expected = []uint32{9, 8, 5, 5, 2, 1}
+	var expectedSources = []int{0, 2, 0, 2, 2, 0}
+	if len(found) != len(expected) {
+		t.Fatalf("Merged:%v expected:%v", found, expected)
+	}
+	for x := range expected {
+		if found[x] != expected[x] || sources[x] != expectedSources[x] {
+			t.Errorf("Merged:%v sources:%v expected:%v sources:%v", found, sources, expected, expectedSources)
+			break
+		}
+	}
+}
+
+//TestOpenMergeReaderError verifies a missing file is reported
+func TestOpenMergeReaderError(t *testing.T) {
+	if _, err := OpenMergeReader([]string{"testdata/FT_RWIPV6-v1-c1-L.dat", "testdata/missing.dat"}, nil); err == nil {
+		t.Errorf("OpenMergeReader of a missing file should fail")
+	}
+}
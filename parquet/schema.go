@@ -0,0 +1,151 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	"github.com/chrispassas/silk"
+)
+
+//Parquet physical types
+const (
+	typeInt32             int32 = 1
+	typeInt64             int32 = 2
+	typeFixedLenByteArray int32 = 7
+)
+
+//Parquet converted types, the legacy form of the logical types
+const (
+	convertedTimestampMillis int32 = 9
+	convertedUint8           int32 = 11
+	convertedUint16          int32 = 12
+	convertedUint32          int32 = 13
+)
+
+//column is one column of the fixed flow schema. Unsigned integers are
+//INT32 columns annotated with their bit width, IP addresses are 16 byte
+//IPv6 addresses with IPv4 addresses mapped into ::ffff:0:0/96.
+type column struct {
+	name string
+	//typ is the physical type and size the PLAIN encoded size of a value
+	typ  int32
+	size int
+	//converted is the converted type, bitWidth the unsigned integer width
+	//and timestamp is set for the timestamp-millis column
+	converted int32
+	bitWidth  int8
+	timestamp bool
+	//put appends the PLAIN encoded value of the column
+	put func(dst []byte, r *silk.Record) []byte
+}
+
+//Column names match the keys of the format package's JSON and CSV receivers
+var columns = []column{
+	{name: "start_time", typ: typeInt64, size: 8, converted: convertedTimestampMillis, timestamp: true,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt64(dst, r.StartTimeMS) }},
+	{name: "duration_ms", typ: typeInt32, size: 4, converted: convertedUint32, bitWidth: 32,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, r.Duration) }},
+	{name: "src_ip", typ: typeFixedLenByteArray, size: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putIP(dst, r.SrcIP) }},
+	{name: "dst_ip", typ: typeFixedLenByteArray, size: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putIP(dst, r.DstIP) }},
+	{name: "src_port", typ: typeInt32, size: 4, converted: convertedUint16, bitWidth: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.SrcPort)) }},
+	{name: "dst_port", typ: typeInt32, size: 4, converted: convertedUint16, bitWidth: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.DstPort)) }},
+	{name: "protocol", typ: typeInt32, size: 4, converted: convertedUint8, bitWidth: 8,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.Proto)) }},
+	{name: "tcp_flags", typ: typeInt32, size: 4, converted: convertedUint8, bitWidth: 8,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.Flags)) }},
+	{name: "packets", typ: typeInt32, size: 4, converted: convertedUint32, bitWidth: 32,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, r.Packets) }},
+	{name: "bytes", typ: typeInt32, size: 4, converted: convertedUint32, bitWidth: 32,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, r.Bytes) }},
+	{name: "flowtype", typ: typeInt32, size: 4, converted: convertedUint8, bitWidth: 8,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.ClassType)) }},
+	{name: "sensor", typ: typeInt32, size: 4, converted: convertedUint16, bitWidth: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.Sensor)) }},
+	{name: "initial_tcp_flags", typ: typeInt32, size: 4, converted: convertedUint8, bitWidth: 8,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.InitalFlags)) }},
+	{name: "session_tcp_flags", typ: typeInt32, size: 4, converted: convertedUint8, bitWidth: 8,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.SessionFlags)) }},
+	{name: "attributes", typ: typeInt32, size: 4, converted: convertedUint8, bitWidth: 8,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.Attributes)) }},
+	{name: "application", typ: typeInt32, size: 4, converted: convertedUint16, bitWidth: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.Application)) }},
+	{name: "snmp_in", typ: typeInt32, size: 4, converted: convertedUint16, bitWidth: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.SNMPIn)) }},
+	{name: "snmp_out", typ: typeInt32, size: 4, converted: convertedUint16, bitWidth: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putInt32(dst, uint32(r.SNMPOut)) }},
+	{name: "next_hop_ip", typ: typeFixedLenByteArray, size: 16,
+		put: func(dst []byte, r *silk.Record) []byte { return putIP(dst, r.NextHopIP) }},
+}
+
+//putInt32 appends v as a PLAIN INT32, unsigned values keep their bits
+func putInt32(dst []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(dst, b[:]...)
+}
+
+//putInt64 appends v as a PLAIN INT64
+func putInt64(dst []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(dst, b[:]...)
+}
+
+//putIP appends addr as a 16 byte address, the zero netip.Addr of records
+//without a next hop is written as 16 zero bytes
+func putIP(dst []byte, addr netip.Addr) []byte {
+	if addr.IsValid() == false {
+		var zero [16]byte
+		return append(dst, zero[:]...)
+	}
+	var b = addr.As16()
+	return append(dst, b[:]...)
+}
+
+//writeSchema writes the schema list of the file metadata, a root element
+//followed by a required element for each column
+func writeSchema(t *thriftWriter) {
+	t.list(2, thriftStruct, len(columns)+1)
+	t.beginElement()
+	t.string(4, "schema")
+	t.i32(5, int32(len(columns)))
+	t.endStruct()
+	for _, c := range columns {
+		t.beginElement()
+		t.i32(1, c.typ)
+		if c.typ == typeFixedLenByteArray {
+			t.i32(2, int32(c.size))
+		}
+		//Repetition type REQUIRED
+		t.i32(3, 0)
+		t.string(4, c.name)
+		if c.converted != 0 {
+			t.i32(6, c.converted)
+		}
+		if c.timestamp {
+			//LogicalType TIMESTAMP(isAdjustedToUTC=true, unit=MILLIS)
+			t.beginStruct(10)
+			t.beginStruct(8)
+			t.bool(1, true)
+			t.beginStruct(2)
+			t.beginStruct(1)
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		} else if c.bitWidth != 0 {
+			//LogicalType INTEGER(bitWidth, isSigned=false)
+			t.beginStruct(10)
+			t.beginStruct(10)
+			t.byte(1, c.bitWidth)
+			t.bool(2, false)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.endStruct()
+	}
+}
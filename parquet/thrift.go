@@ -0,0 +1,127 @@
+package parquet
+
+//Thrift compact protocol type ids
+const (
+	thriftBoolTrue  byte = 1
+	thriftBoolFalse byte = 2
+	thriftByte      byte = 3
+	thriftI16       byte = 4
+	thriftI32       byte = 5
+	thriftI64       byte = 6
+	thriftBinary    byte = 8
+	thriftList      byte = 9
+	thriftStruct    byte = 12
+)
+
+//thriftWriter encodes structs with the thrift compact protocol, the
+//encoding of the parquet file metadata and page headers. Fields must be
+//written in increasing id order within each struct.
+type thriftWriter struct {
+	buf       []byte
+	lastField int16
+	stack     []int16
+}
+
+//field writes a field header, the id is written as a delta from the last
+//field id when it fits in 4 bits
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.lastField; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(uint64(zigzag(int64(id))))
+	}
+	t.lastField = id
+}
+
+//varint appends v as an unsigned LEB128 varint
+func (t *thriftWriter) varint(v uint64) {
+	for v >= 0x80 {
+		t.buf = append(t.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	t.buf = append(t.buf, byte(v))
+}
+
+//zigzag maps signed integers to unsigned so small magnitudes stay small
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) byte(id int16, v int8) {
+	t.field(id, thriftByte)
+	t.buf = append(t.buf, byte(v))
+}
+
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.field(id, thriftBoolTrue)
+	} else {
+		t.field(id, thriftBoolFalse)
+	}
+}
+
+func (t *thriftWriter) binary(id int16, b []byte) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(b)))
+	t.buf = append(t.buf, b...)
+}
+
+func (t *thriftWriter) string(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+//list writes a list field header for size elements of elemType, the
+//elements follow using the element methods
+func (t *thriftWriter) list(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elemType)
+	} else {
+		t.buf = append(t.buf, 0xF0|elemType)
+		t.varint(uint64(size))
+	}
+}
+
+//i32Element writes an i32 list element
+func (t *thriftWriter) i32Element(v int32) {
+	t.varint(zigzag(int64(v)))
+}
+
+//stringElement writes a string list element
+func (t *thriftWriter) stringElement(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+//beginStruct starts a struct field, end it with endStruct
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+//beginElement starts a struct list element or the top level struct, end
+//it with endStruct
+func (t *thriftWriter) beginElement() {
+	t.stack = append(t.stack, t.lastField)
+	t.lastField = 0
+}
+
+//endStruct writes the stop field of the current struct
+func (t *thriftWriter) endStruct() {
+	t.buf = append(t.buf, 0)
+	t.lastField = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
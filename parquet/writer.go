@@ -0,0 +1,392 @@
+/*
+Package parquet writes silk flows as Apache Parquet files with a fixed
+schema matching silk.Flow.
+
+	pw, err := parquet.NewWriter(out, parquet.Options{Compression: parquet.CompressionSnappy})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for sr.Next() {
+		if err = pw.WriteRecord(sr.Record()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err = pw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+Every column is REQUIRED and PLAIN encoded:
+
+	start_time         INT64 TIMESTAMP(MILLIS, UTC)
+	duration_ms        INT32 UINT_32
+	src_ip             FIXED_LEN_BYTE_ARRAY(16)
+	dst_ip             FIXED_LEN_BYTE_ARRAY(16)
+	src_port           INT32 UINT_16
+	dst_port           INT32 UINT_16
+	protocol           INT32 UINT_8
+	tcp_flags          INT32 UINT_8
+	packets            INT32 UINT_32
+	bytes              INT32 UINT_32
+	flowtype           INT32 UINT_8
+	sensor             INT32 UINT_16
+	initial_tcp_flags  INT32 UINT_8
+	session_tcp_flags  INT32 UINT_8
+	attributes         INT32 UINT_8
+	application        INT32 UINT_16
+	snmp_in            INT32 UINT_16
+	snmp_out           INT32 UINT_16
+	next_hop_ip        FIXED_LEN_BYTE_ARRAY(16)
+
+IP addresses are 16 byte IPv6 addresses, IPv4 addresses are mapped into
+::ffff:0:0/96 and a missing next hop is 16 zero bytes.
+*/
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/chrispassas/silk"
+	"github.com/golang/snappy"
+)
+
+//magic starts and ends every parquet file
+const magic = "PAR1"
+
+//Default row group and page sizes
+const (
+	DefaultRowGroupSize = 256 * 1024
+	DefaultPageSize     = 1024 * 1024
+)
+
+//Compression is a parquet compression codec, the values are the parquet codec ids
+type Compression int32
+
+//Supported compression codecs
+const (
+	CompressionNone   Compression = 0
+	CompressionSnappy Compression = 1
+	CompressionGzip   Compression = 2
+)
+
+//ParseCompression returns the codec named none, snappy or gzip
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "none", "uncompressed":
+		return CompressionNone, nil
+	case "snappy":
+		return CompressionSnappy, nil
+	case "gzip":
+		return CompressionGzip, nil
+	}
+	return 0, fmt.Errorf("Unsupported parquet compression:%s", name)
+}
+
+//Options sets the layout of a parquet file, the zero value uses the default
+//sizes without compression
+type Options struct {
+	//RowGroupSize is the number of rows in each row group, rows are
+	//buffered in memory until a row group is full
+	RowGroupSize int
+	//PageSize is the target uncompressed size in bytes of a data page
+	PageSize    int
+	Compression Compression
+	//CreatedBy is written to the file metadata
+	CreatedBy string
+}
+
+//columnChunk is the metadata of one column of a written row group
+type columnChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+	minValue         []byte
+	maxValue         []byte
+}
+
+//rowGroup is the metadata of a written row group
+type rowGroup struct {
+	rows    int64
+	columns []columnChunk
+}
+
+//Writer writes flows to a parquet file. Close must be called to write the
+//file metadata, a file without it can't be read.
+type Writer struct {
+	w         io.Writer
+	opts      Options
+	offset    int64
+	values    [][]byte
+	rows      int
+	rowGroups []rowGroup
+	record    silk.Record
+	thrift    thriftWriter
+	page      []byte
+	gzipBuf   bytes.Buffer
+	gzip      *gzip.Writer
+	minTime   uint64
+	maxTime   uint64
+	closed    bool
+	err       error
+}
+
+//NewWriter writes the parquet magic to w and returns a Writer
+func NewWriter(w io.Writer, opts Options) (pw *Writer, err error) {
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = DefaultRowGroupSize
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	if opts.CreatedBy == "" {
+		opts.CreatedBy = "github.com/chrispassas/silk/parquet"
+	}
+	switch opts.Compression {
+	case CompressionNone, CompressionSnappy, CompressionGzip:
+	default:
+		err = fmt.Errorf("Unsupported parquet compression:%d", opts.Compression)
+		return
+	}
+
+	pw = &Writer{
+		w:      w,
+		opts:   opts,
+		values: make([][]byte, len(columns)),
+	}
+	if err = pw.write([]byte(magic)); err != nil {
+		pw = nil
+	}
+	return
+}
+
+//Write adds f to the current row group
+func (pw *Writer) Write(f silk.Flow) (err error) {
+	pw.record.SetFlow(&f)
+	return pw.WriteRecord(&pw.record)
+}
+
+//WriteRecord adds r to the current row group, the row group is written once
+//it holds RowGroupSize rows
+func (pw *Writer) WriteRecord(r *silk.Record) (err error) {
+	if pw.err != nil {
+		return pw.err
+	}
+	if pw.closed {
+		return fmt.Errorf("Write to closed parquet Writer")
+	}
+	for x := range columns {
+		pw.values[x] = columns[x].put(pw.values[x], r)
+	}
+	if pw.rows == 0 || r.StartTimeMS < pw.minTime {
+		pw.minTime = r.StartTimeMS
+	}
+	if pw.rows == 0 || r.StartTimeMS > pw.maxTime {
+		pw.maxTime = r.StartTimeMS
+	}
+	pw.rows++
+	if pw.rows >= pw.opts.RowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return
+}
+
+//Close writes the buffered rows and the file metadata. It does not close
+//the underlying io.Writer.
+func (pw *Writer) Close() (err error) {
+	if pw.closed {
+		return pw.err
+	}
+	if pw.rows > 0 {
+		if err = pw.flushRowGroup(); err != nil {
+			return
+		}
+	}
+	pw.closed = true
+	if pw.err != nil {
+		return pw.err
+	}
+
+	pw.thrift.buf = pw.thrift.buf[:0]
+	pw.writeFileMetaData(&pw.thrift)
+	var footer = pw.thrift.buf
+	footer = append(footer, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(footer[len(footer)-4:], uint32(len(pw.thrift.buf)))
+	footer = append(footer, magic...)
+	return pw.write(footer)
+}
+
+//write writes b and advances the file offset
+func (pw *Writer) write(b []byte) (err error) {
+	if pw.err != nil {
+		return pw.err
+	}
+	var n int
+	n, pw.err = pw.w.Write(b)
+	pw.offset += int64(n)
+	return pw.err
+}
+
+//flushRowGroup writes each column of the buffered rows as a column chunk
+//of data pages
+func (pw *Writer) flushRowGroup() (err error) {
+	var rg = rowGroup{rows: int64(pw.rows), columns: make([]columnChunk, len(columns))}
+	for x := range columns {
+		var chunk = &rg.columns[x]
+		chunk.offset = pw.offset
+		var valuesPerPage = pw.opts.PageSize / columns[x].size
+		if valuesPerPage < 1 {
+			valuesPerPage = 1
+		}
+		var pageBytes = valuesPerPage * columns[x].size
+		for values := pw.values[x]; len(values) > 0; {
+			var data = values
+			if len(data) > pageBytes {
+				data = data[:pageBytes]
+			}
+			values = values[len(data):]
+			if err = pw.writePage(chunk, data, len(data)/columns[x].size); err != nil {
+				return
+			}
+		}
+		if columns[x].timestamp {
+			chunk.minValue = putInt64(nil, pw.minTime)
+			chunk.maxValue = putInt64(nil, pw.maxTime)
+		}
+		pw.values[x] = pw.values[x][:0]
+	}
+	pw.rowGroups = append(pw.rowGroups, rg)
+	pw.rows = 0
+	return
+}
+
+//writePage compresses and writes a PLAIN data page holding count values.
+//Required columns have no repetition or definition levels.
+func (pw *Writer) writePage(chunk *columnChunk, data []byte, count int) (err error) {
+	var compressed []byte
+	if compressed, err = pw.compress(data); err != nil {
+		pw.err = err
+		return
+	}
+
+	var t = &pw.thrift
+	t.buf = t.buf[:0]
+	t.beginElement()
+	//PageHeader type DATA_PAGE
+	t.i32(1, 0)
+	t.i32(2, int32(len(data)))
+	t.i32(3, int32(len(compressed)))
+	t.beginStruct(5)
+	t.i32(1, int32(count))
+	//PLAIN values, RLE levels
+	t.i32(2, 0)
+	t.i32(3, 3)
+	t.i32(4, 3)
+	t.endStruct()
+	t.endStruct()
+
+	chunk.uncompressedSize += int64(len(t.buf) + len(data))
+	chunk.compressedSize += int64(len(t.buf) + len(compressed))
+	if err = pw.write(t.buf); err != nil {
+		return
+	}
+	return pw.write(compressed)
+}
+
+//compress returns data compressed with the writer's codec
+func (pw *Writer) compress(data []byte) (compressed []byte, err error) {
+	switch pw.opts.Compression {
+	case CompressionSnappy:
+		pw.page = snappy.Encode(pw.page[:cap(pw.page)], data)
+		return pw.page, nil
+	case CompressionGzip:
+		pw.gzipBuf.Reset()
+		if pw.gzip == nil {
+			pw.gzip = gzip.NewWriter(&pw.gzipBuf)
+		} else {
+			pw.gzip.Reset(&pw.gzipBuf)
+		}
+		if _, err = pw.gzip.Write(data); err != nil {
+			return
+		}
+		if err = pw.gzip.Close(); err != nil {
+			return
+		}
+		return pw.gzipBuf.Bytes(), nil
+	}
+	return data, nil
+}
+
+//writeFileMetaData encodes the FileMetaData struct of the footer
+func (pw *Writer) writeFileMetaData(t *thriftWriter) {
+	var numRows int64
+	for _, rg := range pw.rowGroups {
+		numRows += rg.rows
+	}
+
+	t.beginElement()
+	t.i32(1, 1)
+	writeSchema(t)
+	t.i64(3, numRows)
+	t.list(4, thriftStruct, len(pw.rowGroups))
+	for x, rg := range pw.rowGroups {
+		var uncompressed, compressed int64
+		t.beginElement()
+		t.list(1, thriftStruct, len(rg.columns))
+		for y, chunk := range rg.columns {
+			uncompressed += chunk.uncompressedSize
+			compressed += chunk.compressedSize
+			//ColumnChunk
+			t.beginElement()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			//ColumnMetaData
+			t.i32(1, columns[y].typ)
+			//PLAIN values and RLE levels
+			t.list(2, thriftI32, 2)
+			t.i32Element(0)
+			t.i32Element(3)
+			t.list(3, thriftBinary, 1)
+			t.stringElement(columns[y].name)
+			t.i32(4, int32(pw.opts.Compression))
+			t.i64(5, rg.rows)
+			t.i64(6, chunk.uncompressedSize)
+			t.i64(7, chunk.compressedSize)
+			t.i64(9, chunk.offset)
+			//Statistics
+			t.beginStruct(12)
+			if chunk.maxValue != nil {
+				//The deprecated max and min are valid for signed types
+				t.binary(1, chunk.maxValue)
+				t.binary(2, chunk.minValue)
+			}
+			t.i64(3, 0)
+			if chunk.maxValue != nil {
+				t.binary(5, chunk.maxValue)
+				t.binary(6, chunk.minValue)
+			}
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, uncompressed)
+		t.i64(3, rg.rows)
+		t.i64(5, rg.columns[0].offset)
+		t.i64(6, compressed)
+		t.field(7, thriftI16)
+		t.varint(zigzag(int64(x)))
+		t.endStruct()
+	}
+	t.string(6, pw.opts.CreatedBy)
+	//Every column uses the sort order of its type, TYPE_ORDER
+	t.list(7, thriftStruct, len(columns))
+	for range columns {
+		t.beginElement()
+		t.beginStruct(1)
+		t.endStruct()
+		t.endStruct()
+	}
+	t.endStruct()
+}
@@ -0,0 +1,374 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/internal/silktest"
+	"github.com/golang/snappy"
+)
+
+//thriftReader decodes thrift compact protocol structs into maps of field id
+//to value so tests can check the metadata the Writer encodes
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (t *thriftReader) varint() uint64 {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		var b = t.buf[t.pos]
+		t.pos++
+		v |= uint64(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+}
+
+func (t *thriftReader) zigzag() int64 {
+	var v = t.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+//value decodes a value of typ, integers are int64, binary is []byte, lists
+//are []interface{} and structs are map[int16]interface{}
+func (t *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftBoolTrue:
+		return true
+	case thriftBoolFalse:
+		return false
+	case thriftByte:
+		t.pos++
+		return int64(int8(t.buf[t.pos-1]))
+	case thriftI16, thriftI32, thriftI64:
+		return t.zigzag()
+	case thriftBinary:
+		var n = int(t.varint())
+		t.pos += n
+		return t.buf[t.pos-n : t.pos]
+	case thriftList:
+		var header = t.buf[t.pos]
+		t.pos++
+		var size = int(header >> 4)
+		if size == 15 {
+			size = int(t.varint())
+		}
+		var list = make([]interface{}, size)
+		for x := range list {
+			if header&0x0F == thriftBoolTrue {
+				t.pos++
+				list[x] = t.buf[t.pos-1] == 1
+				continue
+			}
+			list[x] = t.value(header & 0x0F)
+		}
+		return list
+	case thriftStruct:
+		var fields = map[int16]interface{}{}
+		var last int16
+		for {
+			var header = t.buf[t.pos]
+			t.pos++
+			if header == 0 {
+				return fields
+			}
+			var id = last + int16(header>>4)
+			if header>>4 == 0 {
+				id = int16(t.zigzag())
+			}
+			fields[id] = t.value(header & 0x0F)
+			last = id
+		}
+	}
+	panic(fmt.Sprintf("Unsupported thrift type:%d", typ))
+}
+
+//field returns the struct field at path
+func field(v interface{}, path ...int16) interface{} {
+	for _, id := range path {
+		v = v.(map[int16]interface{})[id]
+	}
+	return v
+}
+
+//readColumn returns the PLAIN values of a column chunk
+func readColumn(t *testing.T, file []byte, chunk interface{}) []byte {
+	var meta = field(chunk, 3)
+	var codec = field(meta, 4).(int64)
+	var numValues = field(meta, 5).(int64)
+	var offset = field(meta, 9).(int64)
+	var end = offset + field(meta, 7).(int64)
+	var values []byte
+	var count int64
+
+	for offset < end {
+		var tr = &thriftReader{buf: file, pos: int(offset)}
+		var header = tr.value(thriftStruct)
+		if field(header, 1).(int64) != 0 || field(header, 5, 2).(int64) != 0 {
+			t.Fatalf("Page header:%v expected a PLAIN data page", header)
+		}
+		var size = int(field(header, 3).(int64))
+		var data = file[tr.pos : tr.pos+size]
+		offset = int64(tr.pos + size)
+
+		switch codec {
+		case 1:
+			var err error
+			if data, err = snappy.Decode(nil, data); err != nil {
+				t.Fatalf("Snappy decode error:%s", err)
+			}
+		case 2:
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Gzip reader error:%s", err)
+			}
+			if data, err = io.ReadAll(zr); err != nil {
+				t.Fatalf("Gzip read error:%s", err)
+			}
+		}
+		if int64(len(data)) != field(header, 2).(int64) {
+			t.Fatalf("Page size:%d expected:%d", len(data), field(header, 2))
+		}
+		values = append(values, data...)
+		count += field(header, 5, 1).(int64)
+	}
+	if count != numValues {
+		t.Fatalf("Column values:%d expected:%d", count, numValues)
+	}
+	return values
+}
+
+//TestWriterRoundTrip writes a file with each codec and decodes its metadata
+//and every column value
+func TestWriterRoundTrip(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var records = silktest.ReadRecords(t, filePath)[:2500]
+	var err error
+	//A record without a next hop and an IPv6 record
+	records[1].NextHopIP = netip.Addr{}
+	records[2].SrcIP = netip.MustParseAddr("2001:db8::1")
+
+	for _, compression := range []Compression{CompressionNone, CompressionSnappy, CompressionGzip} {
+		var buf bytes.Buffer
+		var pw *Writer
+		if pw, err = NewWriter(&buf, Options{RowGroupSize: 1000, PageSize: 1000, Compression: compression}); err != nil {
+			t.Fatalf("NewWriter error:%s", err)
+		}
+		for x := range records {
+			if x%2 == 0 {
+				err = pw.WriteRecord(&records[x])
+			} else {
+				err = pw.Write(records[x].Flow())
+			}
+			if err != nil {
+				t.Fatalf("Write error:%s", err)
+			}
+		}
+		if err = pw.Close(); err != nil {
+			t.Fatalf("Close error:%s", err)
+		}
+
+		var file = buf.Bytes()
+		if string(file[:4]) != magic || string(file[len(file)-4:]) != magic {
+			t.Fatalf("Compression:%d missing magic", compression)
+		}
+		var footerSize = int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+		var tr = &thriftReader{buf: file[len(file)-8-footerSize : len(file)-8]}
+		var meta = tr.value(thriftStruct)
+		if tr.pos != footerSize {
+			t.Errorf("Compression:%d footer decoded:%d bytes expected:%d", compression, tr.pos, footerSize)
+		}
+		if field(meta, 3).(int64) != int64(len(records)) {
+			t.Errorf("Compression:%d rows:%d expected:%d", compression, field(meta, 3), len(records))
+		}
+		var schema = field(meta, 2).([]interface{})
+		if len(schema) != len(columns)+1 || field(schema[0], 5).(int64) != int64(len(columns)) {
+			t.Fatalf("Compression:%d schema:%v", compression, schema)
+		}
+		for x, c := range columns {
+			if string(field(schema[x+1], 4).([]byte)) != c.name || field(schema[x+1], 1).(int64) != int64(c.typ) {
+				t.Errorf("Compression:%d schema element:%v expected column:%s", compression, schema[x+1], c.name)
+			}
+		}
+		if unit := field(schema[1], 10, 8, 2, 1); unit == nil || field(schema[1], 10, 8, 1) != true {
+			t.Errorf("Compression:%d start_time logical type:%v", compression, field(schema[1], 10))
+		}
+		if width := field(schema[2], 10, 10, 1); width != int64(32) {
+			t.Errorf("Compression:%d duration_ms bit width:%v", compression, width)
+		}
+
+		var rowGroups = field(meta, 4).([]interface{})
+		if len(rowGroups) != 3 {
+			t.Fatalf("Compression:%d row groups:%d expected:3", compression, len(rowGroups))
+		}
+		var row int
+		for y, rg := range rowGroups {
+			var rows = int(field(rg, 3).(int64))
+			var chunks = field(rg, 1).([]interface{})
+			var values = make([][]byte, len(chunks))
+			for x := range chunks {
+				if string(field(chunks[x], 3, 3).([]interface{})[0].([]byte)) != columns[x].name {
+					t.Errorf("Compression:%d row group:%d column:%d path:%v", compression, y, x, field(chunks[x], 3, 3))
+				}
+				if encodings := field(chunks[x], 3, 2).([]interface{}); len(encodings) != 2 || encodings[0] != int64(0) || encodings[1] != int64(3) {
+					t.Errorf("Compression:%d row group:%d column:%d encodings:%v expected PLAIN and RLE", compression, y, x, encodings)
+				}
+				values[x] = readColumn(t, file, chunks[x])
+			}
+			var minTime, maxTime uint64
+			for z := 0; z < rows; z++ {
+				var expected []byte
+				var r = &records[row+z]
+				for x := range columns {
+					expected = columns[x].put(expected[:0], r)
+					var found = values[x][z*columns[x].size : (z+1)*columns[x].size]
+					if bytes.Equal(found, expected) == false {
+						t.Fatalf("Compression:%d row:%d column:%s value:%x expected:%x", compression, row+z, columns[x].name, found, expected)
+					}
+				}
+				if z == 0 || r.StartTimeMS < minTime {
+					minTime = r.StartTimeMS
+				}
+				if z == 0 || r.StartTimeMS > maxTime {
+					maxTime = r.StartTimeMS
+				}
+			}
+			var stats = field(chunks[0], 3, 12)
+			if binary.LittleEndian.Uint64(field(stats, 6).([]byte)) != minTime || binary.LittleEndian.Uint64(field(stats, 5).([]byte)) != maxTime {
+				t.Errorf("Compression:%d row group:%d start_time statistics:%v expected:%d-%d", compression, y, stats, minTime, maxTime)
+			}
+			row += rows
+		}
+		if row != len(records) {
+			t.Errorf("Compression:%d rows read:%d expected:%d", compression, row, len(records))
+		}
+	}
+
+	//IP address encoding
+	var ip []byte
+	if ip = putIP(nil, netip.MustParseAddr("10.1.2.3")); bytes.Equal(ip, netip.MustParseAddr("::ffff:10.1.2.3").AsSlice()) == false {
+		t.Errorf("IPv4 address:%x expected an IPv4 mapped address", ip)
+	}
+	if ip = putIP(nil, netip.Addr{}); bytes.Equal(ip, make([]byte, 16)) == false {
+		t.Errorf("Zero address:%x expected 16 zero bytes", ip)
+	}
+}
+
+//TestWriterEmpty verifies a file without rows is still complete
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := NewWriter(&buf, Options{})
+	if err != nil {
+		t.Fatalf("NewWriter error:%s", err)
+	}
+	if err = pw.Close(); err != nil {
+		t.Fatalf("Close error:%s", err)
+	}
+	if err = pw.Write(silk.Flow{}); err == nil {
+		t.Errorf("Write after Close expected error")
+	}
+	var file = buf.Bytes()
+	var footerSize = int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	var meta = (&thriftReader{buf: file[len(file)-8-footerSize : len(file)-8]}).value(thriftStruct)
+	if field(meta, 3).(int64) != 0 || len(field(meta, 4).([]interface{})) != 0 {
+		t.Errorf("Empty file metadata:%v", meta)
+	}
+	if _, err = NewWriter(&buf, Options{Compression: 7}); err == nil {
+		t.Errorf("NewWriter compression:7 expected error")
+	}
+}
+
+//goldenPath is a file written by writeGolden and read back with the Apache
+//Arrow parquet reader, goldenRowsPath holds the rows that reader returned
+const (
+	goldenPath     = "../testdata/flows.parquet"
+	goldenRowsPath = "../testdata/flows.parquet.csv"
+)
+
+//goldenRecords returns the first 10 records of a test file with an IPv6
+//source, a missing next hop and the fields the file leaves at 0 set
+func goldenRecords(t *testing.T) []silk.Record {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var records = silktest.ReadRecords(t, filePath)[:10]
+	records[1].NextHopIP = netip.Addr{}
+	records[2].SrcIP = netip.MustParseAddr("2001:db8::1")
+	records[3].Application = 443
+	records[3].Attributes = 0x28
+	records[3].InitalFlags = 0x02
+	records[3].SessionFlags = 0x19
+	records[3].SNMPIn = 65535
+	records[3].SNMPOut = 7
+	records[4].Duration = 4294967295
+	records[4].Bytes = 4294967295
+	return records
+}
+
+//writeGolden writes the golden records with several row groups and pages
+func writeGolden(t *testing.T) []byte {
+	var buf bytes.Buffer
+	pw, err := NewWriter(&buf, Options{RowGroupSize: 4, PageSize: 64, Compression: CompressionSnappy, CreatedBy: "silk parquet golden"})
+	if err != nil {
+		t.Fatalf("NewWriter error:%s", err)
+	}
+	var records = goldenRecords(t)
+	for x := range records {
+		if err = pw.WriteRecord(&records[x]); err != nil {
+			t.Fatalf("WriteRecord error:%s", err)
+		}
+	}
+	if err = pw.Close(); err != nil {
+		t.Fatalf("Close error:%s", err)
+	}
+	return buf.Bytes()
+}
+
+//TestWriterGolden verifies the Writer still writes the golden file and that
+//the rows the Apache Arrow parquet reader returned for it are the records
+func TestWriterGolden(t *testing.T) {
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("ReadFile error:%s", err)
+	}
+	if bytes.Equal(writeGolden(t), golden) == false {
+		t.Errorf("Written file differs from:%s", goldenPath)
+	}
+
+	var rows []byte
+	if rows, err = os.ReadFile(goldenRowsPath); err != nil {
+		t.Fatalf("ReadFile error:%s", err)
+	}
+	var lines = strings.Split(strings.TrimSuffix(string(rows), "\n"), "\n")
+	//The Arrow types of the columns show the logical types were understood
+	var header = "start_time:timestamp[ms, tz=UTC],duration_ms:uint32,src_ip:fixed_size_binary[16],dst_ip:fixed_size_binary[16]," +
+		"src_port:uint16,dst_port:uint16,protocol:uint8,tcp_flags:uint8,packets:uint32,bytes:uint32,flowtype:uint8,sensor:uint16," +
+		"initial_tcp_flags:uint8,session_tcp_flags:uint8,attributes:uint8,application:uint16,snmp_in:uint16,snmp_out:uint16,next_hop_ip:fixed_size_binary[16]"
+	if lines[0] != header {
+		t.Errorf("Columns:%s expected:%s", lines[0], header)
+	}
+	var records = goldenRecords(t)
+	if len(lines)-1 != len(records) {
+		t.Fatalf("Rows:%d expected:%d", len(lines)-1, len(records))
+	}
+	var ip = func(addr netip.Addr) string { return fmt.Sprintf("%x", putIP(nil, addr)) }
+	for x, r := range records {
+		var expected = fmt.Sprintf("%s,%d,%s,%s,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%d,%s",
+			time.UnixMilli(int64(r.StartTimeMS)).UTC().Format("2006-01-02 15:04:05.000Z"), r.Duration, ip(r.SrcIP), ip(r.DstIP),
+			r.SrcPort, r.DstPort, r.Proto, r.Flags, r.Packets, r.Bytes, r.ClassType, r.Sensor,
+			r.InitalFlags, r.SessionFlags, r.Attributes, r.Application, r.SNMPIn, r.SNMPOut, ip(r.NextHopIP))
+		if lines[x+1] != expected {
+			t.Errorf("Row:%d read:%s expected:%s", x, lines[x+1], expected)
+		}
+	}
+}
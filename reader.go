@@ -0,0 +1,473 @@
+package silk
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	lzo "github.com/rasky/go-lzo"
+)
+
+//Reader reads silk flows one record at a time in the style of bufio.Scanner.
+//Records are decoded lazily, a record is only decoded into a Flow when Flow
+//is called so skipping records or stopping early is cheap.
+//
+//	sr, err := silk.NewReader(f)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for sr.Next() {
+//		flow := sr.Flow()
+//		//...
+//	}
+//	if err = sr.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+type Reader struct {
+	r            io.Reader
+	header       Header
+	decode       func(record []byte, h *Header, silkFlow *Record)
+	buf          []byte
+	compressed   []byte
+	blockHeader  []byte
+	decompressor decompressor
+	pos          int
+	end          int
+	raw          []byte
+	record       Record
+	flow         Flow
+	decoded      bool
+	converted    bool
+	partial      bool
+	err          error
+
+	//Record selection, see SetFilter
+	decodePartial func(record []byte, h *Header, silkFlow *Record)
+	filter        FlowFilter
+	recordFilter  RecordFilter
+	partialFilter PartialFilter
+
+	//Random access, see NewIndexedReader
+	seeker io.ReadSeeker
+	index  *Index
+
+	//Concurrent block decoding, see NewReaderConcurrency
+	workers   int
+	free      chan *readerBlock
+	jobs      chan *readerBlock
+	blocks    chan *readerBlock
+	done      chan struct{}
+	block     *readerBlock
+	closeOnce sync.Once
+}
+
+//readerBlock is a compressed block decoded by a NewReaderConcurrency worker
+type readerBlock struct {
+	compressed []byte
+	buf        []byte
+	records    []Record
+	err        error
+	ready      chan struct{}
+}
+
+//decompressor holds the state reused between decompressed blocks
+type decompressor struct {
+	zlibReader  io.ReadCloser
+	blockReader bytes.Reader
+}
+
+//NewReader parses the silk header from r and returns a Reader positioned
+//before the first record.
+func NewReader(r io.Reader) (sr *Reader, err error) {
+	var header Header
+
+	if header, err = parseHeader(r); err != nil {
+		return
+	}
+
+	var rf recordFormat
+	if rf, err = lookupFormat(&header); err != nil {
+		return
+	}
+
+	sr = &Reader{
+		r:             r,
+		header:        header,
+		decode:        rf.decode,
+		decodePartial: rf.decodePartial,
+		blockHeader:   make([]byte, 8),
+	}
+
+	switch header.Compression {
+	case 0:
+		mod := defaultReadSize / int(header.RecordSize)
+		sr.buf = make([]byte, mod*int(header.RecordSize))
+	case 1, 2, 3:
+	default:
+		sr = nil
+		err = ErrUnsupportedCompression
+		return
+	}
+	return
+}
+
+//NewReaderConcurrency is NewReader for compressed files that reads blocks
+//ahead and decompresses and decodes them on workers goroutines. Records are
+//returned in file order. Uncompressed files, or a workers value below 2,
+//are read the same as NewReader. The workers stop at the end of the file or
+//on an error, Close must be called to stop them when reading ends early.
+func NewReaderConcurrency(r io.Reader, workers int) (sr *Reader, err error) {
+	if sr, err = NewReader(r); err != nil {
+		return
+	}
+	if sr.header.Compression != 0 && workers > 1 {
+		sr.workers = workers
+	}
+	return
+}
+
+//Header returns the parsed silk file header
+func (sr *Reader) Header() Header {
+	return sr.header
+}
+
+//SetFilter makes Next skip the records not matching filter, a nil filter
+//returns every record. A RecordFilter is tested without converting records
+//to a Flow and a PartialFilter first tests the fields decoded straight from
+//the record bytes, so rejected records are never fully decoded.
+func (sr *Reader) SetFilter(filter FlowFilter) {
+	sr.filter = filter
+	sr.recordFilter, _ = filter.(RecordFilter)
+	sr.partialFilter, _ = filter.(PartialFilter)
+}
+
+//Next advances to the next record, or the next record matching the filter
+//set by SetFilter. It returns false at the end of the file or when an error
+//occurs, use Err to tell them apart.
+func (sr *Reader) Next() bool {
+	for sr.next() {
+		if sr.filter == nil || sr.match() {
+			return true
+		}
+	}
+	return false
+}
+
+//match tests the current record against the filter
+func (sr *Reader) match() bool {
+	if sr.partialFilter != nil && sr.decoded == false {
+		//Record decodes the whole record again when it is called
+		sr.decodePartial(sr.raw, &sr.header, &sr.record)
+		match, complete := sr.partialFilter.MatchPartial(&sr.record)
+		if match == false || complete {
+			return match
+		}
+	}
+	if sr.recordFilter != nil {
+		return sr.recordFilter.MatchRecord(sr.Record())
+	}
+	return sr.filter.Match(sr.Flow())
+}
+
+//next advances to the next record
+func (sr *Reader) next() bool {
+	if sr.err != nil {
+		return false
+	}
+	if sr.workers > 1 {
+		return sr.nextConcurrent()
+	}
+	for sr.pos >= sr.end {
+		if sr.err = sr.readBlock(); sr.err != nil {
+			sr.raw = nil
+			return false
+		}
+	}
+
+	var recordSize = int(sr.header.RecordSize)
+	sr.raw = sr.buf[sr.pos : sr.pos+recordSize]
+	sr.pos += recordSize
+	sr.decoded = false
+	sr.converted = false
+	return true
+}
+
+//Flow decodes and returns the current record. The returned Flow is reused
+//by the Reader and is only valid until the next call to Next, copy it to keep it.
+//Its IP addresses are allocated for each record, use Record to avoid this.
+func (sr *Reader) Flow() *Flow {
+	var r = sr.Record()
+	if r == nil {
+		return nil
+	}
+	if sr.converted == false {
+		r.flow(&sr.flow)
+		sr.converted = true
+	}
+	return &sr.flow
+}
+
+//Record decodes and returns the current record without allocating. The
+//returned Record is reused by the Reader and is only valid until the next
+//call to Next.
+func (sr *Reader) Record() *Record {
+	if sr.raw == nil {
+		return nil
+	}
+	if sr.decoded == false {
+		sr.decode(sr.raw, &sr.header, &sr.record)
+		sr.decoded = true
+	}
+	return &sr.record
+}
+
+//Err returns the first error encountered by the Reader. Reaching the end of
+//the file is not an error.
+func (sr *Reader) Err() error {
+	if sr.err == io.EOF {
+		return nil
+	}
+	return sr.err
+}
+
+//Close stops the workers of a NewReaderConcurrency Reader, Next returns
+//false afterwards. It does not close the underlying io.Reader.
+func (sr *Reader) Close() error {
+	if sr.err == nil {
+		sr.err = io.EOF
+	}
+	sr.raw = nil
+	sr.stopWorkers()
+	return nil
+}
+
+//readBlock fills buf with the next block of records. For uncompressed files
+//a block is as many whole records as fit in defaultReadSize, for compressed
+//files it is the next compressed block. io.EOF is returned at the end of the file.
+func (sr *Reader) readBlock() (err error) {
+	var n int
+	var recordSize = int(sr.header.RecordSize)
+
+	sr.pos = 0
+	sr.end = 0
+
+	if sr.partial {
+		err = ErrUnsupportedPartialRead
+		return
+	}
+
+	if sr.header.Compression == 0 {
+		if n, err = io.ReadFull(sr.r, sr.buf); err == io.ErrUnexpectedEOF {
+			err = nil
+		} else if err != nil && err != io.EOF {
+			err = fmt.Errorf("Read error:%s", err.Error())
+			return
+		} else if err != nil {
+			return
+		}
+		//Return the whole records read, the partial record is reported next call
+		sr.partial = n%recordSize != 0
+		sr.end = n - n%recordSize
+		return
+	}
+
+	var decompressedBlockSize int
+	if sr.compressed, decompressedBlockSize, err = readFrame(sr.r, sr.blockHeader, sr.compressed); err != nil {
+		return
+	}
+	if decompressedBlockSize > cap(sr.buf) {
+		sr.buf = make([]byte, decompressedBlockSize)
+	}
+	if sr.buf, err = sr.decompressor.decompress(sr.header.Compression, sr.compressed, sr.buf[:decompressedBlockSize]); err != nil {
+		return
+	}
+
+	sr.end = (decompressedBlockSize / recordSize) * recordSize
+	return
+}
+
+//readFrame reads the 8 byte block header and the compressed block that
+//follows it into compressed, growing it as needed. io.EOF is returned at
+//the end of the file.
+func readFrame(r io.Reader, blockHeader []byte, compressed []byte) (block []byte, decompressedBlockSize int, err error) {
+	if _, err = io.ReadFull(r, blockHeader); err == io.ErrUnexpectedEOF {
+		err = ErrUnsupportedPartialRead
+		return
+	} else if err != nil {
+		return
+	}
+	var compressedBlockSize = int(binary.BigEndian.Uint32(blockHeader[0:4]))
+	decompressedBlockSize = int(binary.BigEndian.Uint32(blockHeader[4:8]))
+
+	if compressedBlockSize > cap(compressed) {
+		compressed = make([]byte, compressedBlockSize)
+	}
+	block = compressed[:compressedBlockSize]
+	if _, err = io.ReadFull(r, block); err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrUnsupportedPartialRead
+		return
+	} else if err != nil {
+		return
+	}
+	return
+}
+
+//decompress decompresses a block into dst which is sized to the
+//decompressed block size and returns the decompressed block.
+func (d *decompressor) decompress(compression uint8, compressed []byte, dst []byte) (decompressed []byte, err error) {
+	switch compression {
+	case 1:
+		d.blockReader.Reset(compressed)
+		if d.zlibReader == nil {
+			if d.zlibReader, err = zlib.NewReader(&d.blockReader); err != nil {
+				return
+			}
+		} else if err = d.zlibReader.(zlib.Resetter).Reset(&d.blockReader, nil); err != nil {
+			return
+		}
+		if _, err = io.ReadFull(d.zlibReader, dst); err != nil {
+			return
+		}
+		decompressed = dst
+	case 2:
+		d.blockReader.Reset(compressed)
+		if decompressed, err = lzo.Decompress1X(&d.blockReader, len(compressed), len(dst)); err != nil {
+			return
+		}
+	case 3:
+		if decompressed, err = snappy.Decode(dst[:cap(dst)], compressed); err != nil {
+			return
+		}
+	default:
+		err = ErrUnsupportedCompression
+		return
+	}
+	if len(decompressed) < len(dst) {
+		err = fmt.Errorf("Decompressed block size:%d smaller then expected:%d", len(decompressed), len(dst))
+	}
+	return
+}
+
+//nextConcurrent is Next for a NewReaderConcurrency Reader. Blocks are
+//returned to the free list once every record in them has been read.
+func (sr *Reader) nextConcurrent() bool {
+	if sr.blocks == nil {
+		sr.startWorkers()
+	}
+	for sr.block == nil || sr.pos >= len(sr.block.records) {
+		if sr.block != nil {
+			sr.free <- sr.block
+			sr.block = nil
+		}
+		var block, ok = <-sr.blocks
+		if ok == false {
+			sr.err = io.EOF
+			sr.raw = nil
+			return false
+		}
+		<-block.ready
+		if block.err != nil {
+			sr.err = block.err
+			sr.raw = nil
+			sr.free <- block
+			sr.stopWorkers()
+			return false
+		}
+		sr.block = block
+		sr.pos = 0
+	}
+
+	var recordSize = int(sr.header.RecordSize)
+	sr.raw = sr.block.buf[sr.pos*recordSize : (sr.pos+1)*recordSize]
+	sr.record = sr.block.records[sr.pos]
+	sr.pos++
+	sr.decoded = true
+	sr.converted = false
+	return true
+}
+
+//startWorkers starts the goroutine reading blocks and the workers decoding
+//them. Two blocks per worker are allocated so reading stays ahead of decoding.
+func (sr *Reader) startWorkers() {
+	var blockCount = 2 * sr.workers
+	sr.free = make(chan *readerBlock, blockCount)
+	sr.jobs = make(chan *readerBlock, blockCount)
+	sr.blocks = make(chan *readerBlock, blockCount)
+	sr.done = make(chan struct{})
+	for i := 0; i < blockCount; i++ {
+		sr.free <- &readerBlock{ready: make(chan struct{}, 1)}
+	}
+
+	go sr.readBlocks()
+	for i := 0; i < sr.workers; i++ {
+		go sr.decodeBlocks()
+	}
+}
+
+//stopWorkers stops reading ahead, it is safe to call more than once
+func (sr *Reader) stopWorkers() {
+	if sr.done == nil {
+		return
+	}
+	sr.closeOnce.Do(func() {
+		close(sr.done)
+	})
+}
+
+//readBlocks reads compressed blocks in file order, passing each to the
+//workers and to nextConcurrent. The channels can hold every block so only
+//waiting for a free block blocks.
+func (sr *Reader) readBlocks() {
+	defer close(sr.blocks)
+	defer close(sr.jobs)
+
+	var blockHeader = make([]byte, 8)
+	for {
+		var block *readerBlock
+		select {
+		case block = <-sr.free:
+		case <-sr.done:
+			return
+		}
+
+		var decompressedBlockSize int
+		block.compressed, decompressedBlockSize, block.err = readFrame(sr.r, blockHeader, block.compressed)
+		if block.err == io.EOF {
+			sr.free <- block
+			return
+		} else if block.err != nil {
+			block.ready <- struct{}{}
+			sr.blocks <- block
+			return
+		}
+		if decompressedBlockSize > cap(block.buf) {
+			block.buf = make([]byte, decompressedBlockSize)
+		}
+		block.buf = block.buf[:decompressedBlockSize]
+		sr.jobs <- block
+		sr.blocks <- block
+	}
+}
+
+//decodeBlocks decompresses and decodes blocks until readBlocks stops
+func (sr *Reader) decodeBlocks() {
+	var d decompressor
+	var recordSize = int(sr.header.RecordSize)
+	for block := range sr.jobs {
+		var count = len(block.buf) / recordSize
+		if block.buf, block.err = d.decompress(sr.header.Compression, block.compressed, block.buf); block.err == nil {
+			if count > cap(block.records) {
+				block.records = make([]Record, count)
+			}
+			block.records = block.records[:count]
+			for x := range block.records {
+				sr.decode(block.buf[x*recordSize:(x+1)*recordSize], &sr.header, &block.records[x])
+			}
+		}
+		block.ready <- struct{}{}
+	}
+}
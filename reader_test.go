@@ -0,0 +1,384 @@
+package silk
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+//getReaderTestFileList returns supported test files for each compression type
+func getReaderTestFileList() []string {
+	return []string{
+		"testdata/FT_RWIPV6-v1-c1-L.dat",
+		"testdata/FT_RWIPV6-v1-c2-B.dat",
+		"testdata/FT_RWIPV6-v2-c3-L.dat",
+		"testdata/FT_RWIPV6-v2-c1-B.dat",
+		"testdata/FT_RWIPV6ROUTING-v2-c2-L.dat",
+		"testdata/FT_RWIPV6ROUTING-v2-c3-B.dat",
+		"testdata/FT_RWGENERIC-v5-c1-L.dat",
+		"testdata/FT_RWGENERIC-v5-c1-B.dat",
+	}
+}
+
+//getReaderTestExpected returns the expected flows from file_test.go for a
+//file of getReaderTestFileList
+func getReaderTestExpected(filePath string) (expected testDetails, found bool) {
+	for _, testData := range []testDetails{
+		getTestDataFTRWIPV6V2(),
+		getTestDataFTRWIPV6V1(),
+		getTestDataFTRWIPV6ROUTINGV6V2(),
+		getTestDataFTRWGENERICV5(),
+	} {
+		for _, p := range testData.files {
+			if p == filePath {
+				return testData, true
+			}
+		}
+	}
+	return
+}
+
+//equalTestFlow compares a flow read from a file with an expected flow,
+//addresses are compared with net.IP.Equal
+func equalTestFlow(found Flow, expected Flow) bool {
+	if found.SrcIP.Equal(expected.SrcIP) == false || found.DstIP.Equal(expected.DstIP) == false {
+		return false
+	}
+	if found.NextHopIP.Equal(expected.NextHopIP) == false {
+		return false
+	}
+	found.SrcIP, found.DstIP, found.NextHopIP = nil, nil, nil
+	expected.SrcIP, expected.DstIP, expected.NextHopIP = nil, nil, nil
+	return reflect.DeepEqual(found, expected)
+}
+
+//TestReader verifies the Reader returns the fixed expected flows of
+//file_test.go and every row of each file
+func TestReader(t *testing.T) {
+	for _, filePath := range getReaderTestFileList() {
+		var sr *Reader
+		var f *os.File
+		var err error
+
+		var expected, found = getReaderTestExpected(filePath)
+		if found == false {
+			t.Fatalf("File:%s has no expected flows", filePath)
+		}
+		if f, err = os.Open(filePath); err != nil {
+			t.Errorf("Open file:%s error:%s", filePath, err)
+			continue
+		}
+		if sr, err = NewReader(f); err != nil {
+			t.Errorf("NewReader file:%s error:%s", filePath, err)
+			f.Close()
+			continue
+		}
+		if name := FormatName(sr.Header().RecordFormat); strings.HasPrefix(filePath, "testdata/"+name+"-") == false {
+			t.Errorf("File:%s record format:%s", filePath, name)
+		}
+		if sr.Flow() != nil {
+			t.Errorf("File:%s Flow() before Next() should be nil", filePath)
+		}
+
+		var x int
+		for sr.Next() {
+			if x < len(expected.flows) && equalTestFlow(*sr.Flow(), expected.flows[x]) == false {
+				t.Errorf("File:%s row:%d flow:%+v expected:%+v", filePath, x, *sr.Flow(), expected.flows[x])
+			}
+			x++
+		}
+		if err = sr.Err(); err != nil {
+			t.Errorf("File:%s Err:%s", filePath, err)
+		}
+		if x != 245340 {
+			t.Errorf("File:%s Rows found:%d, rows expected:%d", filePath, x, 245340)
+		}
+		if sr.Next() {
+			t.Errorf("File:%s Next() after end of file should be false", filePath)
+		}
+		f.Close()
+	}
+}
+
+//TestReaderPartialRecord verifies a file ending part way through a record
+//returns an error instead of a clean end of file.
+func TestReaderPartialRecord(t *testing.T) {
+	var sf File
+	var sr *Reader
+	var err error
+
+	if sf, err = OpenFile("testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"); err != nil {
+		t.Fatalf("OpenFile error:%s", err)
+	}
+	for _, compression := range []uint8{0, 1} {
+		var h = sf.Header
+		h.Compression = compression
+		buf, _ := writeTestFile(t, h, sf.Flows[:100])
+		var truncated = buf.Bytes()[:buf.Len()-10]
+
+		if sr, err = NewReader(bytes.NewReader(truncated)); err != nil {
+			t.Fatalf("NewReader error:%s", err)
+		}
+		var x int
+		for sr.Next() {
+			x++
+		}
+		if sr.Err() != ErrUnsupportedPartialRead {
+			t.Errorf("Compression:%d truncated file Err:%v expected:%s", compression, sr.Err(), ErrUnsupportedPartialRead)
+		}
+		if compression == 0 && x != 99 {
+			t.Errorf("Compression:%d truncated file rows:%d expected:%d", compression, x, 99)
+		}
+	}
+}
+
+//TestReaderConcurrency verifies concurrent block decoding returns the same
+//records in the same order as NewReader
+func TestReaderConcurrency(t *testing.T) {
+	for _, filePath := range getReaderTestFileList() {
+		var data []byte
+		var err error
+		if data, err = os.ReadFile(filePath); err != nil {
+			t.Fatalf("ReadFile error:%s", err)
+		}
+		var sr *Reader
+		if sr, err = NewReader(bytes.NewReader(data)); err != nil {
+			t.Fatalf("NewReader file:%s error:%s", filePath, err)
+		}
+		var expected []Record
+		for sr.Next() {
+			expected = append(expected, *sr.Record())
+		}
+
+		for _, workers := range []int{2, 4, 8} {
+			if sr, err = NewReaderConcurrency(bytes.NewReader(data), workers); err != nil {
+				t.Fatalf("NewReaderConcurrency file:%s error:%s", filePath, err)
+			}
+			var x int
+			for sr.Next() {
+				if x < len(expected) && *sr.Record() != expected[x] {
+					t.Errorf("File:%s workers:%d row:%d record:%+v expected:%+v", filePath, workers, x, *sr.Record(), expected[x])
+					break
+				}
+				if x == 0 && reflect.DeepEqual(*sr.Flow(), expected[x].Flow()) == false {
+					t.Errorf("File:%s workers:%d flow:%+v expected:%+v", filePath, workers, *sr.Flow(), expected[x].Flow())
+				}
+				x++
+			}
+			if err = sr.Err(); err != nil {
+				t.Errorf("File:%s workers:%d Err:%s", filePath, workers, err)
+			}
+			if x != len(expected) {
+				t.Errorf("File:%s workers:%d Rows found:%d, rows expected:%d", filePath, workers, x, len(expected))
+			}
+		}
+	}
+}
+
+//TestReaderConcurrencyClose verifies stopping early and truncated files stop the workers
+func TestReaderConcurrencyClose(t *testing.T) {
+	var data []byte
+	var sr *Reader
+	var err error
+	if data, err = os.ReadFile("testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"); err != nil {
+		t.Fatalf("ReadFile error:%s", err)
+	}
+	var goroutines = runtime.NumGoroutine()
+
+	if sr, err = NewReaderConcurrency(bytes.NewReader(data), 4); err != nil {
+		t.Fatalf("NewReaderConcurrency error:%s", err)
+	}
+	for x := 0; x < 10 && sr.Next(); x++ {
+	}
+	sr.Close()
+	if sr.Next() {
+		t.Errorf("Next() after Close() should be false")
+	}
+	if err = sr.Err(); err != nil {
+		t.Errorf("Err() after Close():%s", err)
+	}
+
+	if sr, err = NewReaderConcurrency(bytes.NewReader(data[:len(data)-10]), 4); err != nil {
+		t.Fatalf("NewReaderConcurrency error:%s", err)
+	}
+	for sr.Next() {
+	}
+	if sr.Err() != ErrUnsupportedPartialRead {
+		t.Errorf("Truncated file Err:%v expected:%s", sr.Err(), ErrUnsupportedPartialRead)
+	}
+
+	for x := 0; x < 100 && runtime.NumGoroutine() > goroutines; x++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if found := runtime.NumGoroutine(); found > goroutines {
+		t.Errorf("Goroutines:%d expected:%d", found, goroutines)
+	}
+}
+
+//TestReaderPartialDecode verifies the partial decode of every format sets
+//the same partial fields as the full decode and leaves the rest zero
+func TestReaderPartialDecode(t *testing.T) {
+	var filePaths = append(getReaderTestFileList(),
+		"testdata/FT_RWAUGROUTING-v5-c0-B.dat",
+		"testdata/FT_RWSPLIT-v5-c0-L.dat",
+	)
+	for _, filePath := range filePaths {
+		var data []byte
+		var sr *Reader
+		var err error
+		if data, err = os.ReadFile(filePath); err != nil {
+			t.Fatalf("ReadFile error:%s", err)
+		}
+		if sr, err = NewReader(bytes.NewReader(data)); err != nil {
+			t.Fatalf("NewReader file:%s error:%s", filePath, err)
+		}
+		var x int
+		for sr.Next() {
+			var partial Record
+			sr.decodePartial(sr.raw, &sr.header, &partial)
+			var r = sr.Record()
+			var expected = Record{
+				StartTimeMS: r.StartTimeMS,
+				Duration:    r.Duration,
+				SrcPort:     r.SrcPort,
+				DstPort:     r.DstPort,
+				Proto:       r.Proto,
+				Flags:       r.Flags,
+				Packets:     r.Packets,
+				Bytes:       r.Bytes,
+			}
+			//Formats without a partial decode are fully decoded
+			if partial != expected && partial != *r {
+				t.Errorf("File:%s row:%d partial:%+v expected:%+v", filePath, x, partial, expected)
+				break
+			}
+			x++
+		}
+		if err = sr.Err(); err != nil {
+			t.Errorf("File:%s Err:%s", filePath, err)
+		}
+	}
+}
+
+//testPartialFilter selects UDP flows to port 53, optionally only those to
+//an odd destination address, counting the calls to each match method
+type testPartialFilter struct {
+	oddDstIP     bool
+	partialCalls int
+	recordCalls  int
+}
+
+func (f *testPartialFilter) Match(flow *Flow) bool {
+	var r Record
+	r.SetFlow(flow)
+	return f.MatchRecord(&r)
+}
+
+func (f *testPartialFilter) MatchRecord(r *Record) bool {
+	f.recordCalls++
+	var match, _ = f.match(r)
+	return match && (f.oddDstIP == false || r.DstIP.As16()[15]&1 == 1)
+}
+
+func (f *testPartialFilter) MatchPartial(r *Record) (match bool, complete bool) {
+	f.partialCalls++
+	return f.match(r)
+}
+
+func (f *testPartialFilter) match(r *Record) (match bool, complete bool) {
+	return r.Proto == 17 && r.DstPort == 53, f.oddDstIP == false
+}
+
+//TestReaderFilter verifies SetFilter returns the matching records and only
+//fully tests records passing the partial fields
+func TestReaderFilter(t *testing.T) {
+	for _, filePath := range getReaderTestFileList() {
+		var data []byte
+		var sr *Reader
+		var err error
+		if data, err = os.ReadFile(filePath); err != nil {
+			t.Fatalf("ReadFile error:%s", err)
+		}
+		if sr, err = NewReader(bytes.NewReader(data)); err != nil {
+			t.Fatalf("NewReader file:%s error:%s", filePath, err)
+		}
+		var records []Record
+		for sr.Next() {
+			records = append(records, *sr.Record())
+		}
+
+		for _, oddDstIP := range []bool{false, true} {
+			var filter = &testPartialFilter{oddDstIP: oddDstIP}
+			var expected []Record
+			var partialMatches int
+			for x := range records {
+				if match, _ := filter.match(&records[x]); match {
+					partialMatches++
+					if oddDstIP == false || records[x].DstIP.As16()[15]&1 == 1 {
+						expected = append(expected, records[x])
+					}
+				}
+			}
+
+			for _, workers := range []int{0, 4} {
+				*filter = testPartialFilter{oddDstIP: oddDstIP}
+				if sr, err = NewReaderConcurrency(bytes.NewReader(data), workers); err != nil {
+					t.Fatalf("NewReaderConcurrency file:%s error:%s", filePath, err)
+				}
+				sr.SetFilter(filter)
+				var found []Record
+				for sr.Next() {
+					found = append(found, *sr.Record())
+				}
+				if err = sr.Err(); err != nil {
+					t.Errorf("File:%s Err:%s", filePath, err)
+				}
+				if reflect.DeepEqual(found, expected) == false {
+					t.Errorf("File:%s oddDstIP:%t workers:%d records:%d expected:%d", filePath, oddDstIP, workers, len(found), len(expected))
+				}
+				if sr.workers > 1 {
+					//Concurrently decoded records are already fully decoded
+					continue
+				}
+				if filter.partialCalls != len(records) {
+					t.Errorf("File:%s oddDstIP:%t MatchPartial calls:%d expected:%d", filePath, oddDstIP, filter.partialCalls, len(records))
+				}
+				var recordCalls int
+				if oddDstIP {
+					recordCalls = partialMatches
+				}
+				if filter.recordCalls != recordCalls {
+					t.Errorf("File:%s oddDstIP:%t MatchRecord calls:%d expected:%d", filePath, oddDstIP, filter.recordCalls, recordCalls)
+				}
+			}
+		}
+
+		//A plain FlowFilter is tested with each record's Flow
+		if sr, err = NewReader(bytes.NewReader(data)); err != nil {
+			t.Fatalf("NewReader file:%s error:%s", filePath, err)
+		}
+		sr.SetFilter(FlowFilterFunc(func(f *Flow) bool {
+			return f.Proto == 6
+		}))
+		var tcp int
+		for sr.Next() {
+			if sr.Record().Proto != 6 {
+				t.Errorf("File:%s proto:%d should have been filtered", filePath, sr.Record().Proto)
+				break
+			}
+			tcp++
+		}
+		for x := range records {
+			if records[x].Proto == 6 {
+				tcp--
+			}
+		}
+		if tcp != 0 {
+			t.Errorf("File:%s FlowFilterFunc records off by:%d", filePath, tcp)
+		}
+	}
+}
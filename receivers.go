@@ -1,5 +1,9 @@
 package silk
 
+import (
+	"context"
+)
+
 type FlowReceiver interface {
 	HandleHeader(h Header)
 	HandleFlow(f Flow)
@@ -30,9 +34,18 @@ func (a *SliceFlowReceiver) Close() {
 	// Nothing to do in the slice case
 }
 
+//contextFlowReceiver is implemented by receivers that need the parse
+//context and the error parsing stopped with. setErr is called before Close.
+type contextFlowReceiver interface {
+	setContext(ctx context.Context)
+	setErr(err error)
+}
+
 type ChannelFlowReceiver struct {
 	Header    Header
 	rwChannel chan Flow
+	done      <-chan struct{}
+	err       error
 }
 
 func NewChannelFlowReceiver(channelBufferSize int) *ChannelFlowReceiver {
@@ -42,7 +55,7 @@ func NewChannelFlowReceiver(channelBufferSize int) *ChannelFlowReceiver {
 	}
 }
 
-func (c ChannelFlowReceiver) Read() <-chan Flow {
+func (c *ChannelFlowReceiver) Read() <-chan Flow {
 	return c.rwChannel
 }
 
@@ -51,9 +64,126 @@ func (c *ChannelFlowReceiver) HandleHeader(h Header) {
 }
 
 func (c *ChannelFlowReceiver) HandleFlow(f Flow) {
-	c.rwChannel <- f
+	select {
+	case c.rwChannel <- f:
+	case <-c.done:
+	}
 }
 
 func (c *ChannelFlowReceiver) Close() {
 	close(c.rwChannel)
 }
+
+//Err returns the error parsing stopped with, nil when the whole file was
+//read. It is only valid once the channel returned by Read is closed.
+func (c *ChannelFlowReceiver) Err() error {
+	return c.err
+}
+
+func (c *ChannelFlowReceiver) setContext(ctx context.Context) {
+	c.done = ctx.Done()
+}
+
+func (c *ChannelFlowReceiver) setErr(err error) {
+	c.err = err
+}
+
+//FlowFilter selects flows, the filter package compiles rwfilter switches
+//into a FlowFilter
+type FlowFilter interface {
+	Match(f *Flow) bool
+}
+
+//RecordFilter is a FlowFilter that can also test a decoded Record, which
+//avoids converting each record to a Flow
+type RecordFilter interface {
+	FlowFilter
+	MatchRecord(r *Record) bool
+}
+
+//PartialFilter is a RecordFilter that can reject records from their
+//partial fields before the rest of the record is decoded. The partial
+//fields are StartTimeMS, Duration, SrcPort, DstPort, Proto, Flags, Packets
+//and Bytes, every other field of the Record passed to MatchPartial is zero.
+//complete is true when the partial fields decided the match, otherwise
+//MatchRecord is called with the fully decoded record once match is true.
+type PartialFilter interface {
+	RecordFilter
+	MatchPartial(r *Record) (match bool, complete bool)
+}
+
+//FlowFilterFunc is a function used as a FlowFilter
+type FlowFilterFunc func(f *Flow) bool
+
+//Match calls fn(f)
+func (fn FlowFilterFunc) Match(f *Flow) bool {
+	return fn(f)
+}
+
+//FilteringReceiver is a FlowReceiver that only handles the flows matching
+//Filter, such as a FilterReceiver or a receiver wrapping one. Parsing into a
+//FilteringReceiver applies Filter in the Reader, see Reader.SetFilter, so
+//rejected records are never converted to a Flow. HandleFlow is still called
+//with each matching flow.
+type FilteringReceiver interface {
+	FlowReceiver
+	Filter() FlowFilter
+}
+
+//matchedFlowReceiver is implemented by filtering receivers that can pass the
+//flows already matched by the Reader straight to the receiver they wrap
+type matchedFlowReceiver interface {
+	matchedReceiver() FlowReceiver
+}
+
+//FilterReceiver wraps a FlowReceiver and only forwards the flows matching its filter
+type FilterReceiver struct {
+	filter   FlowFilter
+	receiver FlowReceiver
+}
+
+//NewFilterReceiver returns a FilterReceiver forwarding the flows matching
+//filter to receiver. The header and Close are always forwarded. When
+//parsing into a FilterReceiver the filter is applied by the Reader, see
+//Reader.SetFilter, so only matching records are converted to a Flow.
+func NewFilterReceiver(filter FlowFilter, receiver FlowReceiver) *FilterReceiver {
+	return &FilterReceiver{
+		filter:   filter,
+		receiver: receiver,
+	}
+}
+
+//Filter returns the filter flows are matched against
+func (fr *FilterReceiver) Filter() FlowFilter {
+	return fr.filter
+}
+
+func (fr *FilterReceiver) matchedReceiver() FlowReceiver {
+	return fr.receiver
+}
+
+func (fr *FilterReceiver) HandleHeader(h Header) {
+	fr.receiver.HandleHeader(h)
+}
+
+func (fr *FilterReceiver) HandleFlow(f Flow) {
+	if fr.filter.Match(&f) {
+		fr.receiver.HandleFlow(f)
+	}
+}
+
+func (fr *FilterReceiver) Close() {
+	fr.receiver.Close()
+}
+
+func (fr *FilterReceiver) setContext(ctx context.Context) {
+	if cr, ok := fr.receiver.(contextFlowReceiver); ok {
+		cr.setContext(ctx)
+	}
+}
+
+func (fr *FilterReceiver) setErr(err error) {
+	if cr, ok := fr.receiver.(contextFlowReceiver); ok {
+		cr.setErr(err)
+	}
+}
@@ -0,0 +1,122 @@
+package silk
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+//TestFilterReceiverContext verifies a FilterReceiver passes the parse context
+//and error through to a wrapped ChannelFlowReceiver
+func TestFilterReceiverContext(t *testing.T) {
+	var filePath = "testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var parseErr = make(chan error, 1)
+
+	reader, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	receiver := NewChannelFlowReceiver(0)
+	var filter = FlowFilterFunc(func(f *Flow) bool {
+		return f.Proto == 6
+	})
+	go func() {
+		parseErr <- ParseContext(ctx, reader, NewFilterReceiver(filter, receiver))
+	}()
+
+	var rows int
+	for flow := range receiver.Read() {
+		if flow.Proto != 6 {
+			t.Errorf("File:%s flow proto:%d should have been filtered", filePath, flow.Proto)
+		}
+		rows++
+		if rows == 10 {
+			break
+		}
+	}
+	cancel()
+
+	select {
+	case err = <-parseErr:
+		if err != context.Canceled {
+			t.Errorf("ParseContext error:%v expected:%s", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ParseContext did not return after cancel")
+	}
+	for range receiver.Read() {
+	}
+	if receiver.Err() != context.Canceled {
+		t.Errorf("Receiver Err:%v expected:%s", receiver.Err(), context.Canceled)
+	}
+}
+
+//countingFilter is a RecordFilter counting how flows and records are tested
+type countingFilter struct {
+	flows   int
+	records int
+}
+
+func (cf *countingFilter) Match(f *Flow) bool {
+	cf.flows++
+	return f.Proto == 6
+}
+
+func (cf *countingFilter) MatchRecord(r *Record) bool {
+	cf.records++
+	return r.Proto == 6
+}
+
+//wrappingReceiver wraps a FilteringReceiver and counts the flows it handles
+type wrappingReceiver struct {
+	FilteringReceiver
+	flows int
+}
+
+func (wr *wrappingReceiver) HandleFlow(f Flow) {
+	wr.flows++
+	wr.FilteringReceiver.HandleFlow(f)
+}
+
+//TestFilteringReceiver verifies the filter of a wrapped FilterReceiver is
+//still applied by the Reader
+func TestFilteringReceiver(t *testing.T) {
+	var filePath = "testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var sf, err = OpenFile(filePath)
+	if err != nil {
+		t.Fatalf("OpenFile file:%s error:%s", filePath, err)
+	}
+	var expected int
+	for x := range sf.Flows {
+		if sf.Flows[x].Proto == 6 {
+			expected++
+		}
+	}
+
+	var f *os.File
+	if f, err = os.Open(filePath); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var filter = &countingFilter{}
+	var slice = NewSliceFlowReceiver(expected)
+	var receiver = &wrappingReceiver{FilteringReceiver: NewFilterReceiver(filter, slice)}
+	if err = Parse(f, receiver); err != nil {
+		t.Fatalf("Parse file:%s error:%s", filePath, err)
+	}
+	if len(slice.Flows) != expected || receiver.flows != expected {
+		t.Errorf("File:%s flows:%d handled:%d expected:%d", filePath, len(slice.Flows), receiver.flows, expected)
+	}
+	if filter.records != len(sf.Flows) {
+		t.Errorf("File:%s records matched by the Reader:%d expected:%d", filePath, filter.records, len(sf.Flows))
+	}
+	if filter.flows != expected {
+		t.Errorf("File:%s flows matched by the receiver:%d expected:%d", filePath, filter.flows, expected)
+	}
+}
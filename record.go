@@ -0,0 +1,112 @@
+package silk
+
+import (
+	"net"
+	"net/netip"
+)
+
+//Record is a decoded silk record with the same fields as Flow but with
+//netip.Addr IP addresses. IPv4 addresses, including IPv4 mapped IPv6
+//addresses, are stored as 4 byte addresses. Unlike Flow a Record holds no
+//pointers so the Reader decodes into a reused Record without allocating.
+//NextHopIP is the zero netip.Addr for formats without a next hop.
+type Record struct {
+	StartTimeMS  uint64
+	Duration     uint32
+	SrcIP        netip.Addr
+	DstIP        netip.Addr
+	SrcPort      uint16
+	DstPort      uint16
+	Proto        uint8
+	Flags        uint8
+	Packets      uint32
+	Bytes        uint32
+	ClassType    uint8
+	Sensor       uint16
+	InitalFlags  uint8
+	SessionFlags uint8
+	Attributes   uint8
+	Application  uint16
+	SNMPIn       uint16
+	SNMPOut      uint16
+	NextHopIP    netip.Addr
+}
+
+//Flow returns the record as a Flow. Every IP address is allocated as a
+//16 byte net.IP, a zero netip.Addr is returned as a nil net.IP.
+func (r *Record) Flow() (f Flow) {
+	r.flow(&f)
+	return
+}
+
+//SetFlow sets the record fields from f
+func (r *Record) SetFlow(f *Flow) {
+	*r = Record{
+		StartTimeMS:  f.StartTimeMS,
+		Duration:     f.Duration,
+		SrcIP:        ipToAddr(f.SrcIP),
+		DstIP:        ipToAddr(f.DstIP),
+		SrcPort:      f.SrcPort,
+		DstPort:      f.DstPort,
+		Proto:        f.Proto,
+		Flags:        f.Flags,
+		Packets:      f.Packets,
+		Bytes:        f.Bytes,
+		ClassType:    f.ClassType,
+		Sensor:       f.Sensor,
+		InitalFlags:  f.InitalFlags,
+		SessionFlags: f.SessionFlags,
+		Attributes:   f.Attributes,
+		Application:  f.Application,
+		SNMPIn:       f.SNMPIn,
+		SNMPOut:      f.SNMPOut,
+		NextHopIP:    ipToAddr(f.NextHopIP),
+	}
+}
+
+//flow sets the fields of f from the record
+func (r *Record) flow(f *Flow) {
+	*f = Flow{
+		StartTimeMS:  r.StartTimeMS,
+		Duration:     r.Duration,
+		SrcIP:        addrToIP(r.SrcIP),
+		DstIP:        addrToIP(r.DstIP),
+		SrcPort:      r.SrcPort,
+		DstPort:      r.DstPort,
+		Proto:        r.Proto,
+		Flags:        r.Flags,
+		Packets:      r.Packets,
+		Bytes:        r.Bytes,
+		ClassType:    r.ClassType,
+		Sensor:       r.Sensor,
+		InitalFlags:  r.InitalFlags,
+		SessionFlags: r.SessionFlags,
+		Attributes:   r.Attributes,
+		Application:  r.Application,
+		SNMPIn:       r.SNMPIn,
+		SNMPOut:      r.SNMPOut,
+		NextHopIP:    addrToIP(r.NextHopIP),
+	}
+}
+
+//addrToIP returns addr as a 16 byte net.IP, the form net.ParseIP returns
+func addrToIP(addr netip.Addr) net.IP {
+	if addr.IsValid() == false {
+		return nil
+	}
+	var b = addr.As16()
+	var ip = make(net.IP, net.IPv6len)
+	copy(ip, b[:])
+	return ip
+}
+
+//ipToAddr returns ip as a netip.Addr with IPv4 mapped addresses unmapped
+func ipToAddr(ip net.IP) netip.Addr {
+	addr, _ := netip.AddrFromSlice(ip)
+	return addr.Unmap()
+}
+
+//decodeIPv6 decodes a 16 byte IP address field, IPv4 mapped addresses are unmapped
+func decodeIPv6(b []byte) netip.Addr {
+	return netip.AddrFrom16(*(*[16]byte)(b)).Unmap()
+}
@@ -0,0 +1,319 @@
+//Package sort sorts flow records by a list of fields like rwsort. Records
+//are held in memory up to a memory limit, beyond it each sorted batch is
+//written to a temporary silk file, a run, and the runs are merged when the
+//sorted records are read. Sorting is stable, records with equal keys keep
+//the order they were added in.
+//
+//Runs are FT_RWIPV6ROUTING files, which store every Record field. The only
+//loss is that a zero netip.Addr reads back as :: for records read from runs.
+package sort
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	stdsort "sort"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+)
+
+//DefaultMemoryLimit is the default number of bytes of records sorted in memory
+const DefaultMemoryLimit = 256 << 20
+
+//maxMergeRuns is the most runs merged at once, when there are more runs
+//the earliest runs are merged into one until there are few enough
+const maxMergeRuns = 64
+
+//recordSize is the memory used by a silk.Record
+var recordSize = int(reflect.TypeOf(silk.Record{}).Size())
+
+//runHeader is the header of run files
+var runHeader = silk.Header{RecordFormat: silk.FormatRWIPV6Routing, RecordVersion: 1, Compression: 3}
+
+//Options configure a Sorter. Fields are the sort keys compared in order,
+//Reverse sorts from largest to smallest. MemoryLimit is the bytes of
+//records held in memory before a run is written, DefaultMemoryLimit when 0.
+//Runs are created in TempDir, os.TempDir when empty.
+type Options struct {
+	Fields      []format.Field
+	Reverse     bool
+	MemoryLimit int
+	TempDir     string
+}
+
+//Sorter sorts records. Records are added with Add or AddRecord, then Sort
+//is called once and the sorted records are read with Next and Record like
+//a silk.Reader. Close removes any runs.
+type Sorter struct {
+	opts    Options
+	limit   int
+	records []silk.Record
+	runs    []*run
+	merge   *silk.MergeReader
+	merged  bool
+	sorted  bool
+	pos     int
+	record  *silk.Record
+	err     error
+}
+
+//NewSorter returns a Sorter, Fields must not be empty
+func NewSorter(opts Options) (s *Sorter, err error) {
+	if len(opts.Fields) == 0 {
+		err = fmt.Errorf("Sort fields are required")
+		return
+	}
+	if opts.MemoryLimit <= 0 {
+		opts.MemoryLimit = DefaultMemoryLimit
+	}
+	s = &Sorter{opts: opts, limit: opts.MemoryLimit / recordSize}
+	if s.limit < 1 {
+		s.limit = 1
+	}
+	return
+}
+
+//compare compares records by the sort fields, reversed by Reverse
+func (s *Sorter) compare(a, b *silk.Record) int {
+	var c = format.CompareFields(s.opts.Fields, a, b)
+	if s.opts.Reverse {
+		return -c
+	}
+	return c
+}
+
+//Add adds f to the records to sort
+func (s *Sorter) Add(f *silk.Flow) error {
+	var r silk.Record
+	r.SetFlow(f)
+	return s.AddRecord(&r)
+}
+
+//AddRecord adds a copy of r to the records to sort, a run is written when
+//the memory limit is reached
+func (s *Sorter) AddRecord(r *silk.Record) (err error) {
+	if s.err != nil {
+		return s.err
+	}
+	if s.sorted {
+		return fmt.Errorf("AddRecord called after Sort")
+	}
+	if len(s.records) == s.limit {
+		if err = s.spill(); err != nil {
+			s.err = err
+			return
+		}
+	}
+	s.records = append(s.records, *r)
+	return
+}
+
+//sortRecords stable sorts the records in memory
+func (s *Sorter) sortRecords() {
+	stdsort.Stable(recordSlice{records: s.records, s: s})
+}
+
+//spill sorts the records in memory and writes them to a new run
+func (s *Sorter) spill() (err error) {
+	s.sortRecords()
+	var r *run
+	if r, err = s.createRun(); err != nil {
+		return
+	}
+	for x := range s.records {
+		if err = r.writer.WriteRecord(&s.records[x]); err != nil {
+			return
+		}
+	}
+	if err = r.close(); err != nil {
+		return
+	}
+	s.records = s.records[:0]
+	return
+}
+
+//createRun creates a temporary run file and adds it to the runs
+func (s *Sorter) createRun() (r *run, err error) {
+	r = &run{}
+	if r.file, err = os.CreateTemp(s.opts.TempDir, "silksort-*.rw"); err != nil {
+		return
+	}
+	r.path = r.file.Name()
+	s.runs = append(s.runs, r)
+	r.buf = bufio.NewWriter(r.file)
+	r.writer, err = silk.NewWriter(r.buf, runHeader)
+	return
+}
+
+//Sort sorts the added records, when runs were written the records still in
+//memory are written as the last run and the runs are opened for merging
+func (s *Sorter) Sort() (err error) {
+	if s.err != nil {
+		return s.err
+	}
+	if s.sorted {
+		return fmt.Errorf("Sort called twice")
+	}
+	s.sorted = true
+	if len(s.runs) == 0 {
+		s.sortRecords()
+		return
+	}
+	if len(s.records) > 0 {
+		if err = s.spill(); err != nil {
+			s.err = err
+			return
+		}
+	}
+	s.records = nil
+	s.merged = true
+
+	for len(s.runs) > maxMergeRuns {
+		if err = s.mergeRuns(maxMergeRuns); err != nil {
+			s.err = err
+			return
+		}
+	}
+	if err = s.openMerge(s.runs); err != nil {
+		s.err = err
+	}
+	return
+}
+
+//mergeRuns merges the first n runs into a new run that takes their place
+//at the front so equal records keep their order
+func (s *Sorter) mergeRuns(n int) (err error) {
+	var inputs = s.runs[:n:n]
+	if err = s.openMerge(inputs); err != nil {
+		return
+	}
+	var r *run
+	if r, err = s.createRun(); err != nil {
+		return
+	}
+	s.runs = append([]*run{r}, s.runs[n:len(s.runs)-1]...)
+	for s.mergeNext() {
+		if err = r.writer.WriteRecord(s.record); err != nil {
+			break
+		}
+	}
+	s.record = nil
+	if err == nil {
+		err = s.err
+	}
+	for _, input := range inputs {
+		if e := input.remove(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := r.close(); e != nil && err == nil {
+		err = e
+	}
+	return
+}
+
+//openMerge opens runs for reading and merges them in order
+func (s *Sorter) openMerge(runs []*run) (err error) {
+	var readers = make([]*silk.Reader, len(runs))
+	for x, r := range runs {
+		if r.file, err = os.Open(r.path); err != nil {
+			return
+		}
+		if readers[x], err = silk.NewReader(bufio.NewReader(r.file)); err != nil {
+			return
+		}
+	}
+	s.merge = silk.NewMergeReader(readers, s.compare)
+	return
+}
+
+//Next advances to the next sorted record, it returns false after the last
+//record or on an error
+func (s *Sorter) Next() bool {
+	if s.err != nil || s.sorted == false {
+		return false
+	}
+	if s.merged {
+		return s.mergeNext()
+	}
+	if s.pos == len(s.records) {
+		return false
+	}
+	s.record = &s.records[s.pos]
+	s.pos++
+	return true
+}
+
+//mergeNext advances to the smallest current record of the merging runs
+func (s *Sorter) mergeNext() bool {
+	if s.merge.Next() {
+		s.record = s.merge.Record()
+		return true
+	}
+	s.err = s.merge.Err()
+	s.record = nil
+	return false
+}
+
+//Record returns the current record, it is overwritten by the next call to Next
+func (s *Sorter) Record() *silk.Record {
+	return s.record
+}
+
+//Err returns the error that stopped AddRecord, Sort or Next
+func (s *Sorter) Err() error {
+	return s.err
+}
+
+//Close releases the records and removes the runs
+func (s *Sorter) Close() (err error) {
+	for _, r := range s.runs {
+		if e := r.remove(); e != nil && err == nil {
+			err = e
+		}
+	}
+	s.runs = nil
+	s.records = nil
+	s.merge = nil
+	s.record = nil
+	return
+}
+
+//recordSlice sorts records with a Sorter's compare
+type recordSlice struct {
+	records []silk.Record
+	s       *Sorter
+}
+
+func (rs recordSlice) Len() int           { return len(rs.records) }
+func (rs recordSlice) Less(i, j int) bool { return rs.s.compare(&rs.records[i], &rs.records[j]) < 0 }
+func (rs recordSlice) Swap(i, j int)      { rs.records[i], rs.records[j] = rs.records[j], rs.records[i] }
+
+//run is a temporary file of sorted records
+type run struct {
+	path   string
+	file   *os.File
+	buf    *bufio.Writer
+	writer *silk.Writer
+}
+
+//close finishes writing the run and closes its file
+func (r *run) close() (err error) {
+	if err = r.writer.Close(); err == nil {
+		err = r.buf.Flush()
+	}
+	if e := r.file.Close(); e != nil && err == nil {
+		err = e
+	}
+	r.writer = nil
+	r.buf = nil
+	return
+}
+
+//remove closes and removes the run file
+func (r *run) remove() error {
+	r.file.Close()
+	return os.Remove(r.path)
+}
@@ -0,0 +1,103 @@
+package sort
+
+import (
+	"path/filepath"
+	stdsort "sort"
+	"testing"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/internal/silktest"
+)
+
+//TestSorter verifies sorting in memory and with runs matches a stable sort of the records
+func TestSorter(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var records = silktest.ReadRecords(t, filePath)
+	if len(records) > 50000 {
+		records = records[:50000]
+	}
+	//The test file has no applications, set them so runs must keep them
+	for x := range records {
+		records[x].Application = uint16(x)
+	}
+
+	var tests = []struct {
+		fields      []format.Field
+		reverse     bool
+		memoryLimit int
+	}{
+		{fields: []format.Field{format.FieldSTime}},
+		{fields: []format.Field{format.FieldSIP, format.FieldDPort}, memoryLimit: 1 << 20},
+		{fields: []format.Field{format.FieldBytes}, reverse: true, memoryLimit: 1 << 20},
+		{fields: []format.Field{format.FieldProtocol, format.FieldETime}, memoryLimit: 200 * recordSize},
+	}
+	for _, test := range tests {
+		var expected = append([]silk.Record(nil), records...)
+		stdsort.SliceStable(expected, func(i, j int) bool {
+			var c = format.CompareFields(test.fields, &expected[i], &expected[j])
+			if test.reverse {
+				return c > 0
+			}
+			return c < 0
+		})
+
+		var tempDir = t.TempDir()
+		var s, err = NewSorter(Options{Fields: test.fields, Reverse: test.reverse, MemoryLimit: test.memoryLimit, TempDir: tempDir})
+		if err != nil {
+			t.Fatalf("NewSorter error:%s", err)
+		}
+		for x := range records {
+			if err = s.AddRecord(&records[x]); err != nil {
+				t.Fatalf("AddRecord error:%s", err)
+			}
+		}
+		if err = s.Sort(); err != nil {
+			t.Fatalf("Sort error:%s", err)
+		}
+		var n int
+		for s.Next() {
+			if n < len(expected) && *s.Record() != expected[n] {
+				t.Errorf("Fields:%v reverse:%t runs:%d record:%d sorted:%+v expected:%+v", test.fields, test.reverse, len(s.runs), n, *s.Record(), expected[n])
+				break
+			}
+			n++
+		}
+		if err = s.Err(); err != nil {
+			t.Errorf("Fields:%v error:%s", test.fields, err)
+		}
+		if n != len(expected) && t.Failed() == false {
+			t.Errorf("Fields:%v records:%d expected:%d", test.fields, n, len(expected))
+		}
+		if test.memoryLimit > 0 && (len(s.runs) < 2 || len(s.runs) > maxMergeRuns) {
+			t.Errorf("Fields:%v memory limit:%d runs:%d expected 2-%d", test.fields, test.memoryLimit, len(s.runs), maxMergeRuns)
+		}
+		if err = s.Close(); err != nil {
+			t.Errorf("Close error:%s", err)
+		}
+		if matches, _ := filepath.Glob(filepath.Join(tempDir, "*")); len(matches) != 0 {
+			t.Errorf("Close left runs:%v", matches)
+		}
+	}
+}
+
+//TestSorterErrors verifies the Sorter call order is enforced
+func TestSorterErrors(t *testing.T) {
+	if _, err := NewSorter(Options{}); err == nil {
+		t.Errorf("NewSorter without fields should fail")
+	}
+	var s, _ = NewSorter(Options{Fields: []format.Field{format.FieldSTime}})
+	if s.Next() {
+		t.Errorf("Next before Sort should return false")
+	}
+	s.Sort()
+	if err := s.AddRecord(&silk.Record{}); err == nil {
+		t.Errorf("AddRecord after Sort should fail")
+	}
+	if err := s.Sort(); err == nil {
+		t.Errorf("Sort twice should fail")
+	}
+	if s.Next() {
+		t.Errorf("Next of no records should return false")
+	}
+}
@@ -0,0 +1,136 @@
+package silk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+//FileInfo describes a silk file the way rwfileinfo does
+type FileInfo struct {
+	Path        string
+	Header      Header
+	FileSize    int64
+	RecordCount uint64
+}
+
+//Stat parses the header of the silk file at path and counts its records
+//without decoding them. Compressed files are counted from their block
+//headers so no blocks are decompressed.
+func Stat(path string) (fi FileInfo, err error) {
+	var f *os.File
+	var stat os.FileInfo
+
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+
+	if stat, err = f.Stat(); err != nil {
+		return
+	}
+	fi.Path = path
+	fi.FileSize = stat.Size()
+
+	if fi.Header, err = parseHeader(f); err != nil {
+		return
+	}
+	//parseHeader reads any padding after the end of header entry so the
+	//records start at the current offset
+	var start int64
+	if start, err = f.Seek(0, io.SeekCurrent); err != nil {
+		return
+	}
+	fi.RecordCount, err = countRecords(f, fi.Header, start, fi.FileSize)
+	return
+}
+
+//countRecords counts the records in r from offset start, size is the
+//total file size. A file ending part way through a record or block returns
+//ErrUnsupportedPartialRead.
+func countRecords(r io.ReadSeeker, h Header, start int64, size int64) (count uint64, err error) {
+	if h.RecordSize == 0 {
+		return
+	}
+	var recordSize = int64(h.RecordSize)
+
+	if h.Compression == 0 {
+		var dataLength = size - start
+		count = uint64(dataLength / recordSize)
+		if dataLength%recordSize != 0 {
+			err = ErrUnsupportedPartialRead
+		}
+		return
+	}
+
+	var blockHeader = make([]byte, 8)
+	var pos = start
+	for pos < size {
+		if _, err = io.ReadFull(r, blockHeader); err != nil {
+			err = ErrUnsupportedPartialRead
+			return
+		}
+		var compressedBlockSize = int64(binary.BigEndian.Uint32(blockHeader[0:4]))
+		var decompressedBlockSize = int64(binary.BigEndian.Uint32(blockHeader[4:8]))
+		pos += 8 + compressedBlockSize
+		if pos > size {
+			err = ErrUnsupportedPartialRead
+			return
+		}
+		if decompressedBlockSize%recordSize != 0 {
+			err = fmt.Errorf("Decompressed block size:%d is not a multiple of record size:%d", decompressedBlockSize, recordSize)
+			return
+		}
+		count += uint64(decompressedBlockSize / recordSize)
+		if _, err = r.Seek(pos, io.SeekStart); err != nil {
+			return
+		}
+	}
+	return
+}
+
+//FormatName returns the name of the file record format
+func (fi FileInfo) FormatName() string {
+	return FormatName(fi.Header.RecordFormat)
+}
+
+//ByteOrder returns the record byte order as rwfileinfo prints it
+func (fi FileInfo) ByteOrder() string {
+	if fi.Header.FileFlags != 0 {
+		return "bigEndian"
+	}
+	return "littleEndian"
+}
+
+//compressionNames are the rwfileinfo compression names indexed by the header
+//compression method
+var compressionNames = []string{"none", "zlib", "lzo1x", "snappy"}
+
+//CompressionName returns the name of the file compression method
+func (fi FileInfo) CompressionName() string {
+	if int(fi.Header.Compression) < len(compressionNames) {
+		return compressionNames[fi.Header.Compression]
+	}
+	return "unknown"
+}
+
+//ParseCompression returns the header compression method named none, zlib,
+//lzo1x or snappy. lzo is accepted for lzo1x.
+func ParseCompression(name string) (uint8, error) {
+	if name == "lzo" {
+		name = "lzo1x"
+	}
+	for x := range compressionNames {
+		if compressionNames[x] == name {
+			return uint8(x), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown compression:%s", name)
+}
+
+//SilkVersion returns the version of silk that wrote the file as major.minor.patch
+func (fi FileInfo) SilkVersion() string {
+	var v = fi.Header.SilkVersion
+	return fmt.Sprintf("%d.%d.%d", v/1000000, v/1000%1000, v%1000)
+}
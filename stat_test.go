@@ -0,0 +1,103 @@
+package silk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//TestStat verifies Stat counts the same number of records OpenFile decodes
+func TestStat(t *testing.T) {
+	var filePaths = append(getReaderTestFileList(),
+		"testdata/FT_RWAUGMENTED-v5-c0-L.dat",
+		"testdata/FT_RWWWW-v5-c1-B.dat",
+	)
+	for _, filePath := range filePaths {
+		var sf File
+		var fi FileInfo
+		var err error
+
+		if sf, err = OpenFile(filePath); err != nil {
+			t.Errorf("OpenFile file:%s error:%s", filePath, err)
+			continue
+		}
+		if fi, err = Stat(filePath); err != nil {
+			t.Errorf("Stat file:%s error:%s", filePath, err)
+			continue
+		}
+		if fi.RecordCount != uint64(len(sf.Flows)) {
+			t.Errorf("File:%s RecordCount:%d expected:%d", filePath, fi.RecordCount, len(sf.Flows))
+		}
+		if fi.Header.HeaderLength != sf.Header.HeaderLength || fi.Header.RecordFormat != sf.Header.RecordFormat {
+			t.Errorf("File:%s header:%+v expected:%+v", filePath, fi.Header, sf.Header)
+		}
+		if stat, _ := os.Stat(filePath); stat.Size() != fi.FileSize {
+			t.Errorf("File:%s FileSize:%d expected:%d", filePath, fi.FileSize, stat.Size())
+		}
+	}
+
+	fi, err := Stat("testdata/FT_RWIPV6ROUTING-v2-c1-B.dat")
+	if err != nil {
+		t.Fatalf("Stat error:%s", err)
+	}
+	for _, test := range []struct {
+		name     string
+		found    string
+		expected string
+	}{
+		{"FormatName", fi.FormatName(), "FT_RWIPV6ROUTING"},
+		{"ByteOrder", fi.ByteOrder(), "bigEndian"},
+		{"CompressionName", fi.CompressionName(), "zlib"},
+		{"SilkVersion", fi.SilkVersion(), "3.17.1"},
+	} {
+		if test.found != test.expected {
+			t.Errorf("%s():%s expected:%s", test.name, test.found, test.expected)
+		}
+	}
+}
+
+//TestParseCompression verifies the compression names and that they match CompressionName
+func TestParseCompression(t *testing.T) {
+	for name, expected := range map[string]uint8{"none": 0, "zlib": 1, "lzo1x": 2, "lzo": 2, "snappy": 3} {
+		c, err := ParseCompression(name)
+		if err != nil || c != expected {
+			t.Errorf("ParseCompression:%s found:%d error:%v expected:%d", name, c, err, expected)
+		}
+		if found := (FileInfo{Header: Header{Compression: c}}).CompressionName(); name != "lzo" && found != name {
+			t.Errorf("CompressionName:%d found:%s expected:%s", c, found, name)
+		}
+	}
+	if _, err := ParseCompression("best"); err == nil {
+		t.Errorf("ParseCompression:best expected error")
+	}
+	if found := (FileInfo{Header: Header{Compression: 4}}).CompressionName(); found != "unknown" {
+		t.Errorf("CompressionName:4 found:%s expected:unknown", found)
+	}
+}
+
+//TestStatTruncated verifies Stat reports files ending part way through a record or block
+func TestStatTruncated(t *testing.T) {
+	var dir, err = ioutil.TempDir("", "silk")
+	if err != nil {
+		t.Fatalf("TempDir error:%s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, filePath := range []string{
+		"testdata/FT_RWAUGMENTED-v5-c0-L.dat",
+		"testdata/FT_RWAUGMENTED-v5-c1-L.dat",
+	} {
+		var data []byte
+		if data, err = ioutil.ReadFile(filePath); err != nil {
+			t.Fatalf("ReadFile error:%s", err)
+		}
+		var truncatedPath = filepath.Join(dir, filepath.Base(filePath))
+		if err = ioutil.WriteFile(truncatedPath, data[:len(data)-5], 0600); err != nil {
+			t.Fatalf("WriteFile error:%s", err)
+		}
+		if _, err = Stat(truncatedPath); err != ErrUnsupportedPartialRead {
+			t.Errorf("File:%s truncated Stat error:%v expected:%s", filePath, err, ErrUnsupportedPartialRead)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+//Package stats reports the top or bottom groups of flow records by a value
+//like rwstats, and the distribution of records, bytes and packets over a
+//protocol or port. Records are streamed in, groups are totalled by a
+//uniq.Aggregator so input far larger than memory can be reported on.
+package stats
+
+import (
+	"container/heap"
+	"fmt"
+	stdsort "sort"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/uniq"
+)
+
+//Value is the total groups are ranked by
+type Value uint8
+
+const (
+	//ValueRecords ranks groups by their number of records
+	ValueRecords Value = iota
+	//ValueBytes ranks groups by their bytes
+	ValueBytes
+	//ValuePackets ranks groups by their packets
+	ValuePackets
+)
+
+//valueNames are the --values names indexed by Value
+var valueNames = []string{"records", "bytes", "packets"}
+
+//ParseValue returns the value named records, bytes or packets, flows is
+//accepted for records
+func ParseValue(name string) (Value, error) {
+	if name == "flows" {
+		return ValueRecords, nil
+	}
+	for x := range valueNames {
+		if valueNames[x] == name {
+			return Value(x), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown value:%s", name)
+}
+
+//String returns the value name
+func (v Value) String() string {
+	if int(v) < len(valueNames) {
+		return valueNames[v]
+	}
+	return fmt.Sprintf("Value(%d)", uint8(v))
+}
+
+//of returns the value of a group
+func (v Value) of(g *uniq.Group) uint64 {
+	switch v {
+	case ValueBytes:
+		return g.Bytes
+	case ValuePackets:
+		return g.Packets
+	}
+	return g.Records
+}
+
+//Options configure a Top. Fields are the group keys, groups are ranked by
+//Value. Count limits the report to the first groups, Threshold to groups
+//with a value of at least Threshold, or at most for Bottom, and Percentage
+//to groups with at least that percentage of the total value, or at most
+//for Bottom. Every group is reported when none are set. MemoryLimit and
+//TempDir are passed to the uniq.Aggregator.
+type Options struct {
+	Fields      []format.Field
+	Value       Value
+	Bottom      bool
+	Count       int
+	Threshold   uint64
+	Percentage  float64
+	MemoryLimit int
+	TempDir     string
+}
+
+//Row is a reported group. Percent is the group's share of the total value
+//of all records and CumulativePercent the share of this and the rows before it.
+type Row struct {
+	uniq.Group
+	Value             uint64
+	Percent           float64
+	CumulativePercent float64
+}
+
+//Top ranks groups of records. It is a silk.FlowReceiver, records can also
+//be added with AddRecord. Once every record is added Rows returns the report.
+type Top struct {
+	opts    Options
+	agg     *uniq.Aggregator
+	records uint64
+	total   uint64
+	groups  int
+}
+
+//NewTop returns a Top, Fields must not be empty and only one of Count,
+//Threshold and Percentage may be set
+func NewTop(opts Options) (t *Top, err error) {
+	var limits int
+	for _, set := range []bool{opts.Count > 0, opts.Threshold > 0, opts.Percentage > 0} {
+		if set {
+			limits++
+		}
+	}
+	if limits > 1 {
+		err = fmt.Errorf("Only one of count, threshold and percentage may be set")
+		return
+	}
+	if int(opts.Value) >= len(valueNames) {
+		err = fmt.Errorf("Unknown value:%d", opts.Value)
+		return
+	}
+	t = &Top{opts: opts}
+	if t.agg, err = uniq.NewAggregator(uniq.Options{Fields: opts.Fields, MemoryLimit: opts.MemoryLimit, TempDir: opts.TempDir}); err != nil {
+		return nil, err
+	}
+	return
+}
+
+//HandleHeader does nothing, Top needs no header information
+func (t *Top) HandleHeader(h silk.Header) {}
+
+//HandleFlow adds f, errors are returned by Rows
+func (t *Top) HandleFlow(f silk.Flow) {
+	var r silk.Record
+	r.SetFlow(&f)
+	t.AddRecord(&r)
+}
+
+//Close does nothing, read the report with Rows
+func (t *Top) Close() {}
+
+//AddRecord adds r to its group
+func (t *Top) AddRecord(r *silk.Record) error {
+	t.records++
+	switch t.opts.Value {
+	case ValueBytes:
+		t.total += uint64(r.Bytes)
+	case ValuePackets:
+		t.total += uint64(r.Packets)
+	default:
+		t.total++
+	}
+	return t.agg.AddRecord(r)
+}
+
+//Records returns the number of records added
+func (t *Top) Records() uint64 {
+	return t.records
+}
+
+//Total returns the total value of the records added
+func (t *Top) Total() uint64 {
+	return t.total
+}
+
+//Groups returns the number of groups, it is set by Rows
+func (t *Top) Groups() int {
+	return t.groups
+}
+
+//Rows ends the input and returns the reported groups, largest value first
+//or smallest first for Bottom. Groups with equal values are in key order.
+//Any temporary runs are removed.
+func (t *Top) Rows() (rows []Row, err error) {
+	defer t.agg.Release()
+	t.agg.Close()
+
+	var h = rowHeap{bottom: t.opts.Bottom}
+	t.groups = 0
+	for t.agg.Next() {
+		var g = t.agg.Group()
+		var row = Row{Group: *g, Value: t.opts.Value.of(g)}
+		row.Percent = percent(row.Value, t.total)
+		t.groups++
+		switch {
+		case t.opts.Count > 0:
+			heap.Push(&h, rankedRow{row: row, group: t.groups})
+			if h.Len() > t.opts.Count {
+				heap.Pop(&h)
+			}
+			continue
+		case t.opts.Threshold > 0:
+			if (t.opts.Bottom == false && row.Value < t.opts.Threshold) || (t.opts.Bottom && row.Value > t.opts.Threshold) {
+				continue
+			}
+		case t.opts.Percentage > 0:
+			if (t.opts.Bottom == false && row.Percent < t.opts.Percentage) || (t.opts.Bottom && row.Percent > t.opts.Percentage) {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err = t.agg.Err(); err != nil {
+		return nil, err
+	}
+
+	if t.opts.Count > 0 {
+		//the rows left in the heap are put back in key order before ranking
+		stdsort.Slice(h.rows, func(i, j int) bool { return h.rows[i].group < h.rows[j].group })
+		rows = make([]Row, len(h.rows))
+		for x := range h.rows {
+			rows[x] = h.rows[x].row
+		}
+	}
+	stdsort.SliceStable(rows, func(i, j int) bool {
+		if t.opts.Bottom {
+			return rows[i].Value < rows[j].Value
+		}
+		return rows[i].Value > rows[j].Value
+	})
+	var cumulative float64
+	for x := range rows {
+		cumulative += rows[x].Percent
+		rows[x].CumulativePercent = cumulative
+	}
+	return
+}
+
+//rankedRow is a row and the position of its group in key order
+type rankedRow struct {
+	row   Row
+	group int
+}
+
+//rowHeap holds the best rows seen with the worst on top so it is dropped
+//first. Of equal values the later group is worse.
+type rowHeap struct {
+	rows   []rankedRow
+	bottom bool
+}
+
+func (h rowHeap) Len() int { return len(h.rows) }
+func (h rowHeap) Less(i, j int) bool {
+	var a, b = h.rows[i].row.Value, h.rows[j].row.Value
+	if a == b {
+		return h.rows[i].group > h.rows[j].group
+	}
+	if h.bottom {
+		return a > b
+	}
+	return a < b
+}
+func (h rowHeap) Swap(i, j int)       { h.rows[i], h.rows[j] = h.rows[j], h.rows[i] }
+func (h *rowHeap) Push(x interface{}) { h.rows = append(h.rows, x.(rankedRow)) }
+func (h *rowHeap) Pop() interface{} {
+	var r = h.rows[len(h.rows)-1]
+	h.rows = h.rows[:len(h.rows)-1]
+	return r
+}
@@ -0,0 +1,162 @@
+package stats
+
+import (
+	"math"
+	stdsort "sort"
+	"testing"
+
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/internal/silktest"
+)
+
+//TestTop verifies the top and bottom rows match ranking totals computed with a map
+func TestTop(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var records = silktest.ReadRecords(t, filePath)
+	if len(records) > 50000 {
+		records = records[:50000]
+	}
+
+	var tests = []struct {
+		opts Options
+	}{
+		{opts: Options{Value: ValueBytes, Count: 10}},
+		{opts: Options{Value: ValueRecords, Count: 20, Bottom: true, MemoryLimit: 4096}},
+		{opts: Options{Value: ValuePackets, Threshold: 1000}},
+		{opts: Options{Value: ValueRecords, Threshold: 2, Bottom: true, MemoryLimit: 4096}},
+		{opts: Options{Value: ValueBytes, Percentage: 1}},
+		{opts: Options{Value: ValueRecords}},
+	}
+	for _, test := range tests {
+		test.opts.Fields = []format.Field{format.FieldSPort}
+		test.opts.TempDir = t.TempDir()
+
+		//expected rows ranked by value then port
+		var totals = map[uint16]uint64{}
+		var total uint64
+		for x := range records {
+			var v uint64 = 1
+			switch test.opts.Value {
+			case ValueBytes:
+				v = uint64(records[x].Bytes)
+			case ValuePackets:
+				v = uint64(records[x].Packets)
+			}
+			totals[records[x].SrcPort] += v
+			total += v
+		}
+		var ports []uint16
+		for port, v := range totals {
+			switch {
+			case test.opts.Threshold > 0 && test.opts.Bottom == false && v < test.opts.Threshold,
+				test.opts.Threshold > 0 && test.opts.Bottom && v > test.opts.Threshold,
+				test.opts.Percentage > 0 && float64(v)*100/float64(total) < test.opts.Percentage:
+				continue
+			}
+			ports = append(ports, port)
+		}
+		stdsort.Slice(ports, func(i, j int) bool {
+			var a, b = totals[ports[i]], totals[ports[j]]
+			if a != b {
+				return (a > b) != test.opts.Bottom
+			}
+			return ports[i] < ports[j]
+		})
+		if test.opts.Count > 0 && len(ports) > test.opts.Count {
+			ports = ports[:test.opts.Count]
+		}
+
+		var top, err = NewTop(test.opts)
+		if err != nil {
+			t.Fatalf("NewTop error:%s", err)
+		}
+		for x := range records {
+			if err = top.AddRecord(&records[x]); err != nil {
+				t.Fatalf("AddRecord error:%s", err)
+			}
+		}
+		var rows []Row
+		if rows, err = top.Rows(); err != nil {
+			t.Fatalf("Options:%+v error:%s", test.opts, err)
+		}
+		if top.Total() != total || top.Groups() != len(totals) || top.Records() != uint64(len(records)) {
+			t.Errorf("Options:%+v total:%d groups:%d records:%d expected:%d %d %d", test.opts, top.Total(), top.Groups(), top.Records(), total, len(totals), len(records))
+		}
+		if len(rows) != len(ports) {
+			t.Fatalf("Options:%+v rows:%d expected:%d", test.opts, len(rows), len(ports))
+		}
+		var cumulative float64
+		for x, row := range rows {
+			if row.Key.SrcPort != ports[x] || row.Value != totals[ports[x]] {
+				t.Errorf("Options:%+v row:%d port:%d value:%d expected:%d %d", test.opts, x, row.Key.SrcPort, row.Value, ports[x], totals[ports[x]])
+			}
+			cumulative += float64(row.Value) * 100 / float64(total)
+			if math.Abs(row.CumulativePercent-cumulative) > 1e-9 {
+				t.Errorf("Options:%+v row:%d cumulative:%f expected:%f", test.opts, x, row.CumulativePercent, cumulative)
+			}
+		}
+		if test.opts.Count == 0 && test.opts.Threshold == 0 && test.opts.Percentage == 0 && math.Abs(cumulative-100) > 1e-6 {
+			t.Errorf("Options:%+v cumulative:%f expected:100", test.opts, cumulative)
+		}
+	}
+
+	if _, err := NewTop(Options{Fields: []format.Field{format.FieldSIP}, Count: 10, Threshold: 5}); err == nil {
+		t.Errorf("NewTop with count and threshold expected error")
+	}
+}
+
+//TestSummary verifies protocol and port summaries match totals computed with a map
+func TestSummary(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var records = silktest.ReadRecords(t, filePath)
+
+	var tests = []SummaryOptions{
+		{},
+		{Field: format.FieldDPort, Protocols: []uint8{6, 17}},
+	}
+	for _, test := range tests {
+		var s, err = NewSummary(test)
+		if err != nil {
+			t.Fatalf("NewSummary error:%s", err)
+		}
+		var expected = map[uint16]uint64{}
+		var total uint64
+		for x := range records {
+			var r = &records[x]
+			if test.Field == format.FieldDPort {
+				if r.Proto != 6 && r.Proto != 17 {
+					continue
+				}
+				expected[r.DstPort] += uint64(r.Bytes)
+			} else {
+				expected[uint16(r.Proto)] += uint64(r.Bytes)
+			}
+			total++
+		}
+		for x := range records {
+			s.AddRecord(&records[x])
+		}
+
+		var rows = s.Rows()
+		if len(rows) != len(expected) || s.Total().Records != total {
+			t.Errorf("Options:%+v rows:%d records:%d expected:%d %d", test, len(rows), s.Total().Records, len(expected), total)
+		}
+		var bytesPercent float64
+		for x, row := range rows {
+			if row.Bytes != expected[row.Value] {
+				t.Errorf("Options:%+v value:%d bytes:%d expected:%d", test, row.Value, row.Bytes, expected[row.Value])
+			}
+			if x > 0 && rows[x-1].Records < row.Records {
+				t.Errorf("Options:%+v row:%d records:%d is more than the row before", test, x, row.Records)
+			}
+			bytesPercent += row.BytesPercent
+		}
+		if len(rows) > 0 && (math.Abs(rows[len(rows)-1].CumulativePercent-100) > 1e-6 || math.Abs(bytesPercent-100) > 1e-6) {
+			t.Errorf("Options:%+v cumulative:%f bytes:%f expected:100", test, rows[len(rows)-1].CumulativePercent, bytesPercent)
+		}
+	}
+
+	if _, err := NewSummary(SummaryOptions{Field: format.FieldSIP}); err == nil {
+		t.Errorf("NewSummary field:sIP expected error")
+	}
+}
@@ -0,0 +1,128 @@
+package stats
+
+import (
+	"fmt"
+	stdsort "sort"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+)
+
+//SummaryOptions configure a Summary. Field is FieldProtocol, FieldSPort or
+//FieldDPort, FieldProtocol when 0. When Protocols is set only records of
+//those protocols are summarized, such as 6 and 17 for a port summary.
+type SummaryOptions struct {
+	Field     format.Field
+	Protocols []uint8
+}
+
+//SummaryRow is the volume of one protocol or port. The percentages are of
+//the records, bytes and packets summarized and CumulativePercent is the
+//records percentage of this and the rows before it.
+type SummaryRow struct {
+	Value             uint16
+	Records           uint64
+	Bytes             uint64
+	Packets           uint64
+	RecordsPercent    float64
+	BytesPercent      float64
+	PacketsPercent    float64
+	CumulativePercent float64
+}
+
+//Summary totals records by protocol or port like rwstats' protocol and
+//port distributions. It is a silk.FlowReceiver, records can also be added
+//with AddRecord. A protocol or port has at most 65536 values so every
+//value is kept in memory.
+type Summary struct {
+	opts      SummaryOptions
+	protocols [256]bool
+	rows      map[uint16]*SummaryRow
+	total     SummaryRow
+}
+
+//NewSummary returns a Summary
+func NewSummary(opts SummaryOptions) (s *Summary, err error) {
+	switch opts.Field {
+	case 0:
+		opts.Field = format.FieldProtocol
+	case format.FieldProtocol, format.FieldSPort, format.FieldDPort:
+	default:
+		err = fmt.Errorf("Summary field:%s must be protocol, sPort or dPort", opts.Field)
+		return
+	}
+	s = &Summary{opts: opts, rows: map[uint16]*SummaryRow{}}
+	for _, p := range opts.Protocols {
+		s.protocols[p] = true
+	}
+	return
+}
+
+//HandleHeader does nothing, the Summary needs no header information
+func (s *Summary) HandleHeader(h silk.Header) {}
+
+//HandleFlow adds f
+func (s *Summary) HandleFlow(f silk.Flow) {
+	var r silk.Record
+	r.SetFlow(&f)
+	s.AddRecord(&r)
+}
+
+//Close does nothing, read the summary with Rows
+func (s *Summary) Close() {}
+
+//AddRecord adds r to the row of its protocol or port
+func (s *Summary) AddRecord(r *silk.Record) {
+	if len(s.opts.Protocols) > 0 && s.protocols[r.Proto] == false {
+		return
+	}
+	var value = uint16(s.opts.Field.Value(r))
+	var row, ok = s.rows[value]
+	if ok == false {
+		row = &SummaryRow{Value: value}
+		s.rows[value] = row
+	}
+	for _, t := range []*SummaryRow{row, &s.total} {
+		t.Records++
+		t.Bytes += uint64(r.Bytes)
+		t.Packets += uint64(r.Packets)
+	}
+}
+
+//Total returns the records, bytes and packets summarized
+func (s *Summary) Total() SummaryRow {
+	return s.total
+}
+
+//Rows returns the rows with the most records first, rows with equal
+//records are ordered by value
+func (s *Summary) Rows() (rows []SummaryRow) {
+	rows = make([]SummaryRow, 0, len(s.rows))
+	for _, row := range s.rows {
+		rows = append(rows, *row)
+	}
+	stdsort.Slice(rows, func(i, j int) bool {
+		if rows[i].Records != rows[j].Records {
+			return rows[i].Records > rows[j].Records
+		}
+		return rows[i].Value < rows[j].Value
+	})
+	var cumulative float64
+	for x := range rows {
+		var row = &rows[x]
+		row.RecordsPercent = percent(row.Records, s.total.Records)
+		row.BytesPercent = percent(row.Bytes, s.total.Bytes)
+		row.PacketsPercent = percent(row.Packets, s.total.Packets)
+		cumulative += row.RecordsPercent
+		row.CumulativePercent = cumulative
+	}
+	return
+}
+
+//percent returns v as a percentage of total, 0 when total is 0
+func percent(v, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(v) * 100 / float64(total)
+}
@@ -0,0 +1,265 @@
+//Package total sums the records, bytes and packets of flow records by a
+//bucketed key like rwtotal: the first or last bits of an IPv4 address, a
+//port, the protocol or the ICMP type and code. Every key has at most 2^24
+//values, the totals are kept in memory.
+package total
+
+import (
+	"fmt"
+	stdsort "sort"
+	"strconv"
+
+	"github.com/chrispassas/silk"
+)
+
+//Key selects the bucket a record is totalled in
+type Key uint8
+
+//Keys named like the rwtotal switches that select them
+const (
+	KeySIPFirst8 Key = iota
+	KeySIPFirst16
+	KeySIPFirst24
+	KeySIPLast8
+	KeySIPLast16
+	KeyDIPFirst8
+	KeyDIPFirst16
+	KeyDIPFirst24
+	KeyDIPLast8
+	KeyDIPLast16
+	KeySPort
+	KeyDPort
+	KeyProtocol
+	KeyICMPCode
+)
+
+//keyInfo describes a key. bits is the bits of an address in the key and
+//first is true when they are the leading bits, title is the column title.
+type keyInfo struct {
+	name  string
+	title string
+	bits  int
+	first bool
+}
+
+//keyInfos are indexed by Key
+var keyInfos = []keyInfo{
+	KeySIPFirst8:  {name: "sip-first-8", title: "sIP_First8", bits: 8, first: true},
+	KeySIPFirst16: {name: "sip-first-16", title: "sIP_First16", bits: 16, first: true},
+	KeySIPFirst24: {name: "sip-first-24", title: "sIP_First24", bits: 24, first: true},
+	KeySIPLast8:   {name: "sip-last-8", title: "sIP_Last8", bits: 8},
+	KeySIPLast16:  {name: "sip-last-16", title: "sIP_Last16", bits: 16},
+	KeyDIPFirst8:  {name: "dip-first-8", title: "dIP_First8", bits: 8, first: true},
+	KeyDIPFirst16: {name: "dip-first-16", title: "dIP_First16", bits: 16, first: true},
+	KeyDIPFirst24: {name: "dip-first-24", title: "dIP_First24", bits: 24, first: true},
+	KeyDIPLast8:   {name: "dip-last-8", title: "dIP_Last8", bits: 8},
+	KeyDIPLast16:  {name: "dip-last-16", title: "dIP_Last16", bits: 16},
+	KeySPort:      {name: "sport", title: "sPort"},
+	KeyDPort:      {name: "dport", title: "dPort"},
+	KeyProtocol:   {name: "proto", title: "protocol"},
+	KeyICMPCode:   {name: "icmp-code", title: "iTy iCo"},
+}
+
+//KeyNames returns the key names in Key order
+func KeyNames() (names []string) {
+	for _, info := range keyInfos {
+		names = append(names, info.name)
+	}
+	return
+}
+
+//ParseKey returns the key named like an rwtotal switch, such as sip-first-16
+func ParseKey(name string) (Key, error) {
+	for x := range keyInfos {
+		if keyInfos[x].name == name {
+			return Key(x), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown key:%s", name)
+}
+
+//String returns the key name
+func (k Key) String() string {
+	if int(k) < len(keyInfos) {
+		return keyInfos[k].name
+	}
+	return fmt.Sprintf("Key(%d)", uint8(k))
+}
+
+//Title returns the column title of the key
+func (k Key) Title() string {
+	if int(k) < len(keyInfos) {
+		return keyInfos[k].title
+	}
+	return k.String()
+}
+
+//Value returns the key value of r. ok is false for records the key does not
+//apply to: IPv6 addresses for address keys and records that are not ICMP
+//for KeyICMPCode.
+func (k Key) Value(r *silk.Record) (v uint32, ok bool) {
+	switch k {
+	case KeySPort:
+		return uint32(r.SrcPort), true
+	case KeyDPort:
+		return uint32(r.DstPort), true
+	case KeyProtocol:
+		return uint32(r.Proto), true
+	case KeyICMPCode:
+		return uint32(r.DstPort), r.Proto == 1 || r.Proto == 58
+	}
+
+	var info = keyInfos[k]
+	var addr = r.SrcIP
+	if k >= KeyDIPFirst8 {
+		addr = r.DstIP
+	}
+	if addr.Is4() == false {
+		return 0, false
+	}
+	var b = addr.As4()
+	var ip = uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	if info.first {
+		return ip >> (32 - info.bits), true
+	}
+	return ip & (1<<info.bits - 1), true
+}
+
+//MaxValue returns the largest value of the key: 2^bits-1 for address keys,
+//65535 for ports and the ICMP type and code and 255 for the protocol
+func (k Key) MaxValue() uint32 {
+	switch k {
+	case KeySPort, KeyDPort, KeyICMPCode:
+		return 0xFFFF
+	case KeyProtocol:
+		return 0xFF
+	}
+	return 1<<keyInfos[k].bits - 1
+}
+
+//AppendValue appends the text of key value v: the octets of an address key
+//separated by dots, the ICMP type and code separated by a space or the number
+func (k Key) AppendValue(dst []byte, v uint32) []byte {
+	switch k {
+	case KeySPort, KeyDPort, KeyProtocol:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case KeyICMPCode:
+		dst = strconv.AppendUint(dst, uint64(v>>8), 10)
+		dst = append(dst, ' ')
+		return strconv.AppendUint(dst, uint64(v&0xFF), 10)
+	}
+	for shift := keyInfos[k].bits - 8; shift >= 0; shift -= 8 {
+		dst = strconv.AppendUint(dst, uint64(v>>shift&0xFF), 10)
+		if shift > 0 {
+			dst = append(dst, '.')
+		}
+	}
+	return dst
+}
+
+//Bin is the totals of one key value
+type Bin struct {
+	Value   uint32
+	Records uint64
+	Bytes   uint64
+	Packets uint64
+}
+
+//Options configure a Totaler. Bins with fewer than MinRecords records or
+//more than MaxRecords are not returned by Bins, 0 leaves either end open.
+type Options struct {
+	Key        Key
+	MinRecords uint64
+	MaxRecords uint64
+}
+
+//Totaler sums records by key. It is a silk.FlowReceiver so it can be passed
+//to silk.Parse, or records can be added with AddRecord.
+type Totaler struct {
+	opts    Options
+	bins    map[uint32]*Bin
+	ignored Bin
+}
+
+//NewTotaler returns a Totaler, MaxRecords must not be less than MinRecords
+func NewTotaler(opts Options) (t *Totaler, err error) {
+	if int(opts.Key) >= len(keyInfos) {
+		err = fmt.Errorf("Unknown key:%d", opts.Key)
+		return
+	}
+	if opts.MaxRecords != 0 && opts.MaxRecords < opts.MinRecords {
+		err = fmt.Errorf("Max records:%d is less than min records:%d", opts.MaxRecords, opts.MinRecords)
+		return
+	}
+	t = &Totaler{opts: opts, bins: map[uint32]*Bin{}}
+	return
+}
+
+//HandleHeader does nothing, the Totaler needs no header information
+func (t *Totaler) HandleHeader(h silk.Header) {}
+
+//HandleFlow adds f
+func (t *Totaler) HandleFlow(f silk.Flow) {
+	var r silk.Record
+	r.SetFlow(&f)
+	t.AddRecord(&r)
+}
+
+//Close does nothing, read the totals with Bins
+func (t *Totaler) Close() {}
+
+//AddRecord adds r to the bin of its key value, records the key does not
+//apply to are added to Ignored
+func (t *Totaler) AddRecord(r *silk.Record) {
+	var v, ok = t.opts.Key.Value(r)
+	var b = &t.ignored
+	if ok {
+		if b, ok = t.bins[v]; ok == false {
+			b = &Bin{Value: v}
+			t.bins[v] = b
+		}
+	}
+	b.Records++
+	b.Bytes += uint64(r.Bytes)
+	b.Packets += uint64(r.Packets)
+}
+
+//Ignored returns the totals of the records the key does not apply to
+func (t *Totaler) Ignored() Bin {
+	return t.ignored
+}
+
+//Bins returns the bins in key value order. Like rwtotal every key value
+//from 0 to MaxValue is returned unless skipZeroes is set, so a 24 bit
+//address key returns 2^24 bins. Bins outside MinRecords and MaxRecords are
+//left out.
+func (t *Totaler) Bins(skipZeroes bool) (bins []Bin) {
+	var all = make([]Bin, 0, len(t.bins))
+	for _, b := range t.bins {
+		all = append(all, *b)
+	}
+	stdsort.Slice(all, func(i, j int) bool { return all[i].Value < all[j].Value })
+	if skipZeroes == false && t.opts.MinRecords == 0 {
+		var max = uint64(t.opts.Key.MaxValue())
+		var filled = make([]Bin, 0, max+1)
+		for _, b := range all {
+			for v := uint32(len(filled)); v < b.Value; v++ {
+				filled = append(filled, Bin{Value: v})
+			}
+			filled = append(filled, b)
+		}
+		for v := uint64(len(filled)); v <= max; v++ {
+			filled = append(filled, Bin{Value: uint32(v)})
+		}
+		all = filled
+	}
+
+	bins = all[:0]
+	for _, b := range all {
+		if b.Records < t.opts.MinRecords || (t.opts.MaxRecords != 0 && b.Records > t.opts.MaxRecords) {
+			continue
+		}
+		bins = append(bins, b)
+	}
+	return
+}
@@ -0,0 +1,146 @@
+package total
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/internal/silktest"
+)
+
+//TestKeyValues verifies the value and text of every key
+func TestKeyValues(t *testing.T) {
+	var r = silk.Record{
+		SrcIP:   netip.MustParseAddr("10.1.2.3"),
+		DstIP:   netip.MustParseAddr("2001:db8::1"),
+		SrcPort: 51000,
+		DstPort: 0x0803,
+		Proto:   1,
+	}
+	var tests = []struct {
+		key  Key
+		text string
+		ok   bool
+	}{
+		{key: KeySIPFirst8, text: "10", ok: true},
+		{key: KeySIPFirst16, text: "10.1", ok: true},
+		{key: KeySIPFirst24, text: "10.1.2", ok: true},
+		{key: KeySIPLast8, text: "3", ok: true},
+		{key: KeySIPLast16, text: "2.3", ok: true},
+		{key: KeyDIPFirst8},
+		{key: KeySPort, text: "51000", ok: true},
+		{key: KeyDPort, text: "2051", ok: true},
+		{key: KeyProtocol, text: "1", ok: true},
+		{key: KeyICMPCode, text: "8 3", ok: true},
+	}
+	for _, test := range tests {
+		var v, ok = test.key.Value(&r)
+		if ok != test.ok {
+			t.Errorf("Key:%s ok:%t expected:%t", test.key, ok, test.ok)
+			continue
+		}
+		if ok {
+			if text := string(test.key.AppendValue(nil, v)); text != test.text {
+				t.Errorf("Key:%s text:%s expected:%s", test.key, text, test.text)
+			}
+		}
+		if k, err := ParseKey(test.key.String()); err != nil || k != test.key {
+			t.Errorf("ParseKey:%s key:%s error:%v", test.key, k, err)
+		}
+	}
+
+	r.Proto = 6
+	if _, ok := KeyICMPCode.Value(&r); ok {
+		t.Errorf("Key:icmp-code applied to protocol:6")
+	}
+	if _, err := ParseKey("sip-first-12"); err == nil {
+		t.Errorf("ParseKey:sip-first-12 expected error")
+	}
+}
+
+//TestTotalerFile verifies the bins of a test file match totals computed with a map
+func TestTotalerFile(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var totaler, err = NewTotaler(Options{Key: KeySIPFirst24})
+	if err != nil {
+		t.Fatalf("NewTotaler error:%s", err)
+	}
+	var expected = map[uint32]uint64{}
+	var records, ignored uint64
+	for _, r := range silktest.ReadRecords(t, filePath) {
+		totaler.AddRecord(&r)
+		records++
+		if r.SrcIP.Is4() {
+			var b = r.SrcIP.As4()
+			expected[uint32(b[0])<<16|uint32(b[1])<<8|uint32(b[2])] += uint64(r.Bytes)
+		} else {
+			ignored++
+		}
+	}
+
+	var bins = totaler.Bins(true)
+	var sum uint64
+	for x, b := range bins {
+		if b.Bytes != expected[b.Value] {
+			t.Errorf("File:%s value:%d bytes:%d expected:%d", filePath, b.Value, b.Bytes, expected[b.Value])
+		}
+		if x > 0 && bins[x-1].Value >= b.Value {
+			t.Errorf("File:%s value:%d is not after:%d", filePath, b.Value, bins[x-1].Value)
+		}
+		sum += b.Records
+	}
+	if len(bins) != len(expected) || sum+totaler.Ignored().Records != records || totaler.Ignored().Records != ignored {
+		t.Errorf("File:%s bins:%d records:%d ignored:%d expected:%d %d %d", filePath, len(bins), sum, totaler.Ignored().Records, len(expected), records, ignored)
+	}
+
+}
+
+//TestTotalerZeroes verifies every key value is returned unless zeroes are skipped
+func TestTotalerZeroes(t *testing.T) {
+	for _, test := range []struct {
+		key      Key
+		expected int
+	}{
+		{KeySIPFirst8, 256},
+		{KeySIPLast16, 65536},
+		{KeyDPort, 65536},
+		{KeyProtocol, 256},
+		{KeyICMPCode, 65536},
+	} {
+		var totaler, err = NewTotaler(Options{Key: test.key})
+		if err != nil {
+			t.Fatalf("NewTotaler error:%s", err)
+		}
+		var r = silk.Record{SrcIP: netip.MustParseAddr("10.1.2.3"), DstPort: 771, Proto: 1, Bytes: 10}
+		totaler.AddRecord(&r)
+		var v, _ = test.key.Value(&r)
+		var bins = totaler.Bins(false)
+		if len(bins) != test.expected || bins[0].Value != 0 || int(bins[len(bins)-1].Value) != test.expected-1 {
+			t.Errorf("Key:%s bins:%d expected:%d", test.key, len(bins), test.expected)
+		} else if bins[v].Value != v || bins[v].Bytes != 10 {
+			t.Errorf("Key:%s bin:%+v expected value:%d bytes:10", test.key, bins[v], v)
+		}
+		if bins = totaler.Bins(true); len(bins) != 1 || bins[0].Value != v {
+			t.Errorf("Key:%s bins without zeroes:%+v expected value:%d", test.key, bins, v)
+		}
+	}
+}
+
+//TestTotalerThresholds verifies bins outside the record thresholds are left out
+func TestTotalerThresholds(t *testing.T) {
+	var totaler, err = NewTotaler(Options{Key: KeySPort, MinRecords: 2, MaxRecords: 3})
+	if err != nil {
+		t.Fatalf("NewTotaler error:%s", err)
+	}
+	for _, port := range []uint16{1, 2, 2, 4, 4, 4, 5, 5, 5, 5} {
+		totaler.AddRecord(&silk.Record{SrcPort: port, Bytes: 10})
+	}
+	var bins = totaler.Bins(false)
+	if len(bins) != 2 || bins[0].Value != 2 || bins[1].Value != 4 || bins[1].Bytes != 30 {
+		t.Errorf("Bins:%+v expected ports 2 and 4", bins)
+	}
+
+	if _, err = NewTotaler(Options{MinRecords: 5, MaxRecords: 2}); err == nil {
+		t.Errorf("NewTotaler max records less than min records expected error")
+	}
+}
@@ -0,0 +1,154 @@
+package uniq
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+)
+
+//keyWidth returns the bytes field f takes in a key. Values are big endian
+//so keys sort in the order of format.CompareFields.
+func keyWidth(f format.Field) int {
+	switch f {
+	case format.FieldSIP, format.FieldDIP, format.FieldNhIP:
+		return 16
+	case format.FieldSTime, format.FieldETime:
+		return 8
+	case format.FieldPackets, format.FieldBytes, format.FieldDuration:
+		return 4
+	case format.FieldIType, format.FieldICode:
+		return 1
+	}
+	return 2
+}
+
+//appendKey appends field f of r to a key
+func appendKey(dst []byte, f format.Field, r *silk.Record) []byte {
+	switch f {
+	case format.FieldSIP:
+		return appendAddr(dst, r.SrcIP)
+	case format.FieldDIP:
+		return appendAddr(dst, r.DstIP)
+	case format.FieldNhIP:
+		return appendAddr(dst, r.NextHopIP)
+	case format.FieldETime:
+		return appendUint64(dst, r.StartTimeMS+uint64(r.Duration))
+	}
+	var v = f.Value(r)
+	switch keyWidth(f) {
+	case 8:
+		return appendUint64(dst, v)
+	case 4:
+		return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case 1:
+		return append(dst, byte(v))
+	}
+	return append(dst, byte(v>>8), byte(v))
+}
+
+//appendAddr appends the 16 byte form of addr
+func appendAddr(dst []byte, addr netip.Addr) []byte {
+	var b = addr.As16()
+	return append(dst, b[:]...)
+}
+
+//appendUint64 appends v big endian
+func appendUint64(dst []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(dst, b[:]...)
+}
+
+//decodeKey sets the fields of r from a key of fields. An end time without
+//a start time key is set as a start time with no duration. ICMP types and
+//codes are set in the destination port with the protocol set to ICMP when
+//the protocol isn't a key, so they print like rwuniq.
+func decodeKey(key []byte, fields []format.Field, r *silk.Record) {
+	*r = silk.Record{}
+	var endTimeMS uint64
+	var hasEndTime, hasStartTime, hasProto, hasICMP bool
+	for _, f := range fields {
+		var b = key[:keyWidth(f)]
+		key = key[len(b):]
+		switch f {
+		case format.FieldSIP:
+			r.SrcIP = decodeAddr(b)
+		case format.FieldDIP:
+			r.DstIP = decodeAddr(b)
+		case format.FieldNhIP:
+			r.NextHopIP = decodeAddr(b)
+		case format.FieldSTime:
+			r.StartTimeMS = binary.BigEndian.Uint64(b)
+			hasStartTime = true
+		case format.FieldETime:
+			endTimeMS = binary.BigEndian.Uint64(b)
+			hasEndTime = true
+		case format.FieldPackets:
+			r.Packets = binary.BigEndian.Uint32(b)
+		case format.FieldBytes:
+			r.Bytes = binary.BigEndian.Uint32(b)
+		case format.FieldDuration:
+			r.Duration = binary.BigEndian.Uint32(b)
+		case format.FieldIType:
+			r.DstPort = r.DstPort&0x00FF | uint16(b[0])<<8
+			hasICMP = true
+		case format.FieldICode:
+			r.DstPort = r.DstPort&0xFF00 | uint16(b[0])
+			hasICMP = true
+		default:
+			setField(r, f, binary.BigEndian.Uint16(b))
+			hasProto = hasProto || f == format.FieldProtocol
+		}
+	}
+	if hasEndTime {
+		if hasStartTime && endTimeMS >= r.StartTimeMS {
+			r.Duration = uint32(endTimeMS - r.StartTimeMS)
+		} else if hasStartTime == false {
+			r.StartTimeMS = endTimeMS
+			r.Duration = 0
+		}
+	}
+	if hasICMP && hasProto == false {
+		r.Proto = 1
+	}
+}
+
+//decodeAddr returns the address of a 16 byte key field, IPv4 mapped
+//addresses are returned as IPv4 like silk records
+func decodeAddr(b []byte) netip.Addr {
+	var a [16]byte
+	copy(a[:], b)
+	return netip.AddrFrom16(a).Unmap()
+}
+
+//setField sets a field of 16 bits or fewer
+func setField(r *silk.Record, f format.Field, v uint16) {
+	switch f {
+	case format.FieldSPort:
+		r.SrcPort = v
+	case format.FieldDPort:
+		r.DstPort = v
+	case format.FieldProtocol:
+		r.Proto = uint8(v)
+	case format.FieldFlags:
+		r.Flags = uint8(v)
+	case format.FieldSensor:
+		r.Sensor = v
+	case format.FieldIn:
+		r.SNMPIn = v
+	case format.FieldOut:
+		r.SNMPOut = v
+	case format.FieldInitialFlags:
+		r.InitalFlags = uint8(v)
+	case format.FieldSessionFlags:
+		r.SessionFlags = uint8(v)
+	case format.FieldAttributes:
+		r.Attributes = uint8(v)
+	case format.FieldApplication:
+		r.Application = v
+	case format.FieldFlowType:
+		r.ClassType = uint8(v)
+	}
+}
@@ -0,0 +1,218 @@
+package uniq
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+//maxMergeRuns is the most runs merged at once, when there are more runs
+//the first ones are merged into a single run until few enough remain
+const maxMergeRuns = 64
+
+//A run is a file of groups in key order. Each group is its key, the
+//records, bytes, packets, start and end time as big endian uint64s, then
+//for each distinct field a big endian uint32 count and the values.
+
+//runWriter writes groups to a run
+type runWriter struct {
+	file    *os.File
+	buf     *bufio.Writer
+	widths  []int
+	scratch [8]byte
+}
+
+//newRunWriter returns a runWriter writing to f, widths are the widths of
+//the distinct values
+func newRunWriter(f *os.File, widths []int) *runWriter {
+	return &runWriter{file: f, buf: bufio.NewWriterSize(f, 1<<16), widths: widths}
+}
+
+//write writes the group with key
+func (w *runWriter) write(key []byte, e *entry) (err error) {
+	w.buf.Write(key)
+	for _, v := range []uint64{e.records, e.bytes, e.packets, e.startTimeMS, e.endTimeMS} {
+		binary.BigEndian.PutUint64(w.scratch[:], v)
+		w.buf.Write(w.scratch[:])
+	}
+	for x := range w.widths {
+		binary.BigEndian.PutUint32(w.scratch[:4], uint32(len(e.distinct[x])))
+		w.buf.Write(w.scratch[:4])
+		for value := range e.distinct[x] {
+			if _, err = w.buf.WriteString(value); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+//close flushes and closes the run file
+func (w *runWriter) close() (err error) {
+	err = w.buf.Flush()
+	if e := w.file.Close(); e != nil && err == nil {
+		err = e
+	}
+	return
+}
+
+//runReader reads the groups of a run
+type runReader struct {
+	file     *os.File
+	buf      *bufio.Reader
+	key      []byte
+	totals   [5]uint64
+	distinct [][]byte
+}
+
+//read reads the next group, it returns io.EOF after the last group
+func (r *runReader) read(widths []int) (err error) {
+	if _, err = io.ReadFull(r.buf, r.key); err != nil {
+		return
+	}
+	var b [8]byte
+	for x := range r.totals {
+		if _, err = io.ReadFull(r.buf, b[:]); err != nil {
+			return r.truncated(err)
+		}
+		r.totals[x] = binary.BigEndian.Uint64(b[:])
+	}
+	for x, width := range widths {
+		if _, err = io.ReadFull(r.buf, b[:4]); err != nil {
+			return r.truncated(err)
+		}
+		var n = int(binary.BigEndian.Uint32(b[:4])) * width
+		if cap(r.distinct[x]) < n {
+			r.distinct[x] = make([]byte, n)
+		}
+		r.distinct[x] = r.distinct[x][:n]
+		if _, err = io.ReadFull(r.buf, r.distinct[x]); err != nil {
+			return r.truncated(err)
+		}
+	}
+	return
+}
+
+//truncated returns the error of a run ending inside a group
+func (r *runReader) truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("Run:%s is truncated", r.file.Name())
+	}
+	return err
+}
+
+//merger merges runs, combining the groups with equal keys
+type merger struct {
+	readers []*runReader
+	widths  []int
+	heap    runHeap
+	started bool
+	key     []byte
+	entry   entry
+	err     error
+}
+
+//newMerger opens the runs at paths, keys are keyWidth bytes
+func newMerger(paths []string, keyWidth int, widths []int) (m *merger, err error) {
+	m = &merger{widths: widths}
+	for _, path := range paths {
+		var r = &runReader{key: make([]byte, keyWidth), distinct: make([][]byte, len(widths))}
+		if r.file, err = os.Open(path); err != nil {
+			m.close()
+			return nil, err
+		}
+		r.buf = bufio.NewReaderSize(r.file, 1<<16)
+		m.readers = append(m.readers, r)
+	}
+	return
+}
+
+//next advances to the next key, the totals of every run with the key are
+//combined in entry
+func (m *merger) next() bool {
+	if m.err != nil {
+		return false
+	}
+	if m.started == false {
+		m.started = true
+		for _, r := range m.readers {
+			if err := r.read(m.widths); err == nil {
+				m.heap = append(m.heap, r)
+			} else if err != io.EOF {
+				m.err = err
+				return false
+			}
+		}
+		heap.Init(&m.heap)
+	}
+	if len(m.heap) == 0 {
+		return false
+	}
+
+	m.key = append(m.key[:0], m.heap[0].key...)
+	m.entry = entry{distinct: make([]map[string]struct{}, len(m.widths))}
+	for x := range m.entry.distinct {
+		m.entry.distinct[x] = map[string]struct{}{}
+	}
+	var first = true
+	for len(m.heap) > 0 && bytes.Equal(m.heap[0].key, m.key) {
+		var r = m.heap[0]
+		m.combine(r, first)
+		first = false
+		if err := r.read(m.widths); err == io.EOF {
+			heap.Pop(&m.heap)
+		} else if err != nil {
+			m.err = err
+			return false
+		} else {
+			heap.Fix(&m.heap, 0)
+		}
+	}
+	return true
+}
+
+//combine adds the current group of r to entry
+func (m *merger) combine(r *runReader, first bool) {
+	var e = &m.entry
+	e.records += r.totals[0]
+	e.bytes += r.totals[1]
+	e.packets += r.totals[2]
+	if first || r.totals[3] < e.startTimeMS {
+		e.startTimeMS = r.totals[3]
+	}
+	if r.totals[4] > e.endTimeMS {
+		e.endTimeMS = r.totals[4]
+	}
+	for x, width := range m.widths {
+		for values := r.distinct[x]; len(values) > 0; values = values[width:] {
+			e.distinct[x][string(values[:width])] = struct{}{}
+		}
+	}
+}
+
+//close closes the run files
+func (m *merger) close() {
+	for _, r := range m.readers {
+		r.file.Close()
+	}
+	m.readers = nil
+	m.heap = nil
+}
+
+//runHeap orders runReaders by their current key
+type runHeap []*runReader
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return bytes.Compare(h[i].key, h[j].key) < 0 }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	var old = *h
+	var r = old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
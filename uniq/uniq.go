@@ -0,0 +1,375 @@
+//Package uniq groups flow records by a list of key fields and totals each
+//group like rwuniq. Groups are held in a hash table up to a memory limit,
+//beyond it the table is written to a temporary file sorted by key, a run,
+//and the runs are merged when the groups are read. Groups are always read
+//in key order.
+package uniq
+
+import (
+	"fmt"
+	"os"
+	stdsort "sort"
+	"strconv"
+	"strings"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+)
+
+//DefaultMemoryLimit is the default number of bytes of groups held in memory
+const DefaultMemoryLimit = 256 << 20
+
+//Estimated memory of a group and of a distinct value beyond their keys,
+//covering the hash table, entry and set overhead
+const (
+	groupOverhead    = 160
+	distinctOverhead = 48
+)
+
+//Range is an inclusive range of totals, a Max of 0 has no maximum
+type Range struct {
+	Min uint64
+	Max uint64
+}
+
+//ParseRange parses MIN-MAX, MIN- or MIN like rwuniq's --bytes=1000-
+func ParseRange(s string) (r Range, err error) {
+	var low, high = s, ""
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		low, high = s[:i], s[i+1:]
+	}
+	if r.Min, err = strconv.ParseUint(low, 10, 64); err != nil {
+		err = fmt.Errorf("Invalid range:%s", s)
+		return
+	}
+	if high != "" {
+		if r.Max, err = strconv.ParseUint(high, 10, 64); err != nil || r.Max < r.Min {
+			err = fmt.Errorf("Invalid range:%s", s)
+		}
+	}
+	return
+}
+
+//Contains returns true when v is in the range
+func (r Range) Contains(v uint64) bool {
+	return v >= r.Min && (r.Max == 0 || v <= r.Max)
+}
+
+//Options configure an Aggregator. Fields are the group keys and Distinct
+//the fields whose distinct values are counted in each group. Groups whose
+//totals are outside Records, Bytes or Packets are not returned by Next.
+//MemoryLimit is the bytes of groups held in memory before a run is
+//written, DefaultMemoryLimit when 0. Runs are created in TempDir,
+//os.TempDir when empty.
+type Options struct {
+	Fields      []format.Field
+	Distinct    []format.Field
+	Records     Range
+	Bytes       Range
+	Packets     Range
+	MemoryLimit int
+	TempDir     string
+}
+
+//Group is the totals of the records with one key. Key has the key fields
+//set and every other field zero. Distinct has the number of distinct
+//values of each Options.Distinct field.
+type Group struct {
+	Key         silk.Record
+	Records     uint64
+	Bytes       uint64
+	Packets     uint64
+	StartTimeMS uint64
+	EndTimeMS   uint64
+	Distinct    []uint64
+}
+
+//entry is the totals of a group being aggregated
+type entry struct {
+	records     uint64
+	bytes       uint64
+	packets     uint64
+	startTimeMS uint64
+	endTimeMS   uint64
+	distinct    []map[string]struct{}
+}
+
+//Aggregator groups records. It is a silk.FlowReceiver, records can also be
+//added with AddRecord. Once every record is added Close ends the input and
+//the groups are read with Next and Group. Release removes any runs, it is
+//called by Next after the last group.
+type Aggregator struct {
+	opts     Options
+	keyWidth int
+	widths   []int
+	groups   map[string]*entry
+	memory   int
+	runs     []string
+	key      []byte
+	value    []byte
+	closed   bool
+	keys     []string
+	pos      int
+	merge    *merger
+	group    Group
+	err      error
+}
+
+//NewAggregator returns an Aggregator, Fields must not be empty
+func NewAggregator(opts Options) (a *Aggregator, err error) {
+	if len(opts.Fields) == 0 {
+		err = fmt.Errorf("Key fields are required")
+		return
+	}
+	if opts.MemoryLimit <= 0 {
+		opts.MemoryLimit = DefaultMemoryLimit
+	}
+	a = &Aggregator{opts: opts, groups: map[string]*entry{}}
+	for _, f := range opts.Fields {
+		a.keyWidth += keyWidth(f)
+	}
+	for _, f := range opts.Distinct {
+		a.widths = append(a.widths, keyWidth(f))
+	}
+	a.group.Distinct = make([]uint64, len(opts.Distinct))
+	return
+}
+
+//HandleHeader does nothing, the Aggregator needs no header information
+func (a *Aggregator) HandleHeader(h silk.Header) {}
+
+//HandleFlow adds f, errors are returned by Err
+func (a *Aggregator) HandleFlow(f silk.Flow) {
+	a.Add(&f)
+}
+
+//Add adds f to its group
+func (a *Aggregator) Add(f *silk.Flow) error {
+	var r silk.Record
+	r.SetFlow(f)
+	return a.AddRecord(&r)
+}
+
+//AddRecord adds r to its group, a run is written when the memory limit is reached
+func (a *Aggregator) AddRecord(r *silk.Record) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.closed {
+		a.err = fmt.Errorf("AddRecord called after Close")
+		return a.err
+	}
+
+	a.key = a.key[:0]
+	for _, f := range a.opts.Fields {
+		a.key = appendKey(a.key, f, r)
+	}
+	var e, ok = a.groups[string(a.key)]
+	if ok == false {
+		e = &entry{startTimeMS: r.StartTimeMS}
+		if len(a.opts.Distinct) > 0 {
+			e.distinct = make([]map[string]struct{}, len(a.opts.Distinct))
+			for x := range e.distinct {
+				e.distinct[x] = map[string]struct{}{}
+			}
+		}
+		a.groups[string(a.key)] = e
+		a.memory += groupOverhead + len(a.key)
+	}
+	e.records++
+	e.bytes += uint64(r.Bytes)
+	e.packets += uint64(r.Packets)
+	if r.StartTimeMS < e.startTimeMS {
+		e.startTimeMS = r.StartTimeMS
+	}
+	if end := r.StartTimeMS + uint64(r.Duration); end > e.endTimeMS {
+		e.endTimeMS = end
+	}
+	for x, f := range a.opts.Distinct {
+		a.value = appendKey(a.value[:0], f, r)
+		if _, ok = e.distinct[x][string(a.value)]; ok == false {
+			e.distinct[x][string(a.value)] = struct{}{}
+			a.memory += distinctOverhead + len(a.value)
+		}
+	}
+
+	if a.memory >= a.opts.MemoryLimit {
+		if a.err = a.spill(); a.err != nil {
+			return a.err
+		}
+	}
+	return nil
+}
+
+//sortedKeys returns the keys of the groups in memory in order
+func (a *Aggregator) sortedKeys() (keys []string) {
+	keys = make([]string, 0, len(a.groups))
+	for key := range a.groups {
+		keys = append(keys, key)
+	}
+	stdsort.Strings(keys)
+	return
+}
+
+//spill writes the groups in memory to a new run and clears them
+func (a *Aggregator) spill() (err error) {
+	var w *runWriter
+	if w, err = a.createRun(); err != nil {
+		return
+	}
+	for _, key := range a.sortedKeys() {
+		if err = w.write([]byte(key), a.groups[key]); err != nil {
+			w.close()
+			return
+		}
+	}
+	if err = w.close(); err != nil {
+		return
+	}
+	a.groups = map[string]*entry{}
+	a.memory = 0
+	return
+}
+
+//createRun creates a temporary run file and adds it to the runs
+func (a *Aggregator) createRun() (w *runWriter, err error) {
+	var f *os.File
+	if f, err = os.CreateTemp(a.opts.TempDir, "silkuniq-*.run"); err != nil {
+		return
+	}
+	a.runs = append(a.runs, f.Name())
+	return newRunWriter(f, a.widths), nil
+}
+
+//Close ends the input, when runs were written the groups in memory are
+//written as the last run and the runs are opened for merging. Errors are
+//returned by Err.
+func (a *Aggregator) Close() {
+	if a.err != nil || a.closed {
+		return
+	}
+	a.closed = true
+	if len(a.runs) == 0 {
+		a.keys = a.sortedKeys()
+		return
+	}
+	if len(a.groups) > 0 {
+		if a.err = a.spill(); a.err != nil {
+			return
+		}
+	}
+	a.groups = nil
+
+	for len(a.runs) > maxMergeRuns {
+		if a.err = a.mergeRuns(maxMergeRuns); a.err != nil {
+			return
+		}
+	}
+	a.merge, a.err = newMerger(a.runs, a.keyWidth, a.widths)
+}
+
+//mergeRuns merges the first n runs into a new run that takes their place
+func (a *Aggregator) mergeRuns(n int) (err error) {
+	var inputs = a.runs[:n:n]
+	var m *merger
+	if m, err = newMerger(inputs, a.keyWidth, a.widths); err != nil {
+		return
+	}
+	var w *runWriter
+	if w, err = a.createRun(); err != nil {
+		m.close()
+		return
+	}
+	a.runs = append([]string{a.runs[len(a.runs)-1]}, a.runs[n:len(a.runs)-1]...)
+	for m.next() {
+		if err = w.write(m.key, &m.entry); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = m.err
+	}
+	m.close()
+	for _, path := range inputs {
+		if e := os.Remove(path); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := w.close(); e != nil && err == nil {
+		err = e
+	}
+	return
+}
+
+//Next advances to the next group in key order whose totals are in the
+//ranges of the Options. It returns false after the last group or on an
+//error, use Err to tell them apart.
+func (a *Aggregator) Next() bool {
+	if a.err != nil || a.closed == false {
+		return false
+	}
+	for {
+		var key []byte
+		var e *entry
+		if a.merge != nil {
+			if a.merge.next() == false {
+				a.err = a.merge.err
+				a.Release()
+				return false
+			}
+			key, e = a.merge.key, &a.merge.entry
+		} else {
+			if a.pos == len(a.keys) {
+				a.Release()
+				return false
+			}
+			key, e = []byte(a.keys[a.pos]), a.groups[a.keys[a.pos]]
+			a.pos++
+		}
+		if a.opts.Records.Contains(e.records) && a.opts.Bytes.Contains(e.bytes) && a.opts.Packets.Contains(e.packets) {
+			a.setGroup(key, e)
+			return true
+		}
+	}
+}
+
+//setGroup sets the current group from an entry
+func (a *Aggregator) setGroup(key []byte, e *entry) {
+	decodeKey(key, a.opts.Fields, &a.group.Key)
+	a.group.Records = e.records
+	a.group.Bytes = e.bytes
+	a.group.Packets = e.packets
+	a.group.StartTimeMS = e.startTimeMS
+	a.group.EndTimeMS = e.endTimeMS
+	for x := range a.group.Distinct {
+		a.group.Distinct[x] = uint64(len(e.distinct[x]))
+	}
+}
+
+//Group returns the current group, it is overwritten by the next call to Next
+func (a *Aggregator) Group() *Group {
+	return &a.group
+}
+
+//Err returns the first error of AddRecord, Close or Next
+func (a *Aggregator) Err() error {
+	return a.err
+}
+
+//Release frees the groups and removes the runs, Next returns false afterwards
+func (a *Aggregator) Release() (err error) {
+	if a.merge != nil {
+		a.merge.close()
+		a.merge = nil
+	}
+	for _, path := range a.runs {
+		if e := os.Remove(path); e != nil && err == nil {
+			err = e
+		}
+	}
+	a.runs = nil
+	a.groups = nil
+	a.keys = nil
+	a.pos = 0
+	return
+}
@@ -0,0 +1,220 @@
+package uniq
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chrispassas/silk"
+	"github.com/chrispassas/silk/format"
+	"github.com/chrispassas/silk/internal/silktest"
+)
+
+//testKey returns the values of fields of r as text
+func testKey(fields []format.Field, r *silk.Record) string {
+	var b strings.Builder
+	for _, f := range fields {
+		switch f {
+		case format.FieldSIP:
+			fmt.Fprintf(&b, "%s|", r.SrcIP)
+		case format.FieldDIP:
+			fmt.Fprintf(&b, "%s|", r.DstIP)
+		case format.FieldNhIP:
+			fmt.Fprintf(&b, "%s|", r.NextHopIP)
+		case format.FieldETime:
+			fmt.Fprintf(&b, "%d|", r.StartTimeMS+uint64(r.Duration))
+		default:
+			fmt.Fprintf(&b, "%d|", f.Value(r))
+		}
+	}
+	return b.String()
+}
+
+//testGroup is an expected group
+type testGroup struct {
+	records, bytes, packets uint64
+	startTimeMS, endTimeMS  uint64
+	distinct                []map[string]bool
+}
+
+//TestAggregator verifies aggregating in memory and with runs matches totals computed with a map
+func TestAggregator(t *testing.T) {
+	var filePath = "../testdata/FT_RWIPV6ROUTING-v2-c1-L.dat"
+	var records = silktest.ReadRecords(t, filePath)
+	if len(records) > 50000 {
+		records = records[:50000]
+	}
+
+	var tests = []struct {
+		fields      []format.Field
+		distinct    []format.Field
+		memoryLimit int
+	}{
+		{fields: []format.Field{format.FieldSIP}},
+		{fields: []format.Field{format.FieldProtocol, format.FieldDPort}, distinct: []format.Field{format.FieldSIP, format.FieldSPort}},
+		{fields: []format.Field{format.FieldSIP}, distinct: []format.Field{format.FieldDIP}, memoryLimit: 1 << 12},
+		{fields: []format.Field{format.FieldDIP, format.FieldSPort}, memoryLimit: 4096},
+		{fields: []format.Field{format.FieldETime}, distinct: []format.Field{format.FieldDPort}, memoryLimit: 1 << 14},
+	}
+	for _, test := range tests {
+		var expected = map[string]*testGroup{}
+		for x := range records {
+			var r = &records[x]
+			var key = testKey(test.fields, r)
+			var g, ok = expected[key]
+			if ok == false {
+				g = &testGroup{startTimeMS: r.StartTimeMS, distinct: make([]map[string]bool, len(test.distinct))}
+				for y := range g.distinct {
+					g.distinct[y] = map[string]bool{}
+				}
+				expected[key] = g
+			}
+			g.records++
+			g.bytes += uint64(r.Bytes)
+			g.packets += uint64(r.Packets)
+			if r.StartTimeMS < g.startTimeMS {
+				g.startTimeMS = r.StartTimeMS
+			}
+			if end := r.StartTimeMS + uint64(r.Duration); end > g.endTimeMS {
+				g.endTimeMS = end
+			}
+			for y, f := range test.distinct {
+				g.distinct[y][testKey([]format.Field{f}, r)] = true
+			}
+		}
+
+		var tempDir = t.TempDir()
+		var a, err = NewAggregator(Options{Fields: test.fields, Distinct: test.distinct, MemoryLimit: test.memoryLimit, TempDir: tempDir})
+		if err != nil {
+			t.Fatalf("NewAggregator error:%s", err)
+		}
+		for x := range records {
+			if err = a.AddRecord(&records[x]); err != nil {
+				t.Fatalf("AddRecord error:%s", err)
+			}
+		}
+		var runs = len(a.runs)
+		if test.memoryLimit != 0 && runs == 0 {
+			t.Errorf("Fields:%v memoryLimit:%d expected runs", test.fields, test.memoryLimit)
+		}
+		a.Close()
+
+		var n int
+		var previous silk.Record
+		for a.Next() {
+			var g = a.Group()
+			if n > 0 && format.CompareFields(test.fields, &previous, &g.Key) >= 0 {
+				t.Errorf("Fields:%v group:%d key:%+v is not after:%+v", test.fields, n, g.Key, previous)
+			}
+			previous = g.Key
+			n++
+
+			var key = testKey(test.fields, &g.Key)
+			var e, ok = expected[key]
+			if ok == false {
+				t.Errorf("Fields:%v unexpected key:%s", test.fields, key)
+				continue
+			}
+			if g.Records != e.records || g.Bytes != e.bytes || g.Packets != e.packets || g.StartTimeMS != e.startTimeMS || g.EndTimeMS != e.endTimeMS {
+				t.Errorf("Fields:%v key:%s group:%+v expected:%+v", test.fields, key, *g, *e)
+			}
+			for y := range test.distinct {
+				if g.Distinct[y] != uint64(len(e.distinct[y])) {
+					t.Errorf("Fields:%v key:%s distinct:%v count:%d expected:%d", test.fields, key, test.distinct[y], g.Distinct[y], len(e.distinct[y]))
+				}
+			}
+		}
+		if err = a.Err(); err != nil {
+			t.Fatalf("Fields:%v error:%s", test.fields, err)
+		}
+		if n != len(expected) {
+			t.Errorf("Fields:%v runs:%d groups:%d expected:%d", test.fields, runs, n, len(expected))
+		}
+		var left, _ = os.ReadDir(tempDir)
+		if len(left) != 0 {
+			t.Errorf("Fields:%v runs left:%d expected:0", test.fields, len(left))
+		}
+	}
+}
+
+//TestAggregatorRanges verifies groups outside the ranges are skipped
+func TestAggregatorRanges(t *testing.T) {
+	var a, err = NewAggregator(Options{
+		Fields:  []format.Field{format.FieldSPort},
+		Records: Range{Min: 2},
+		Bytes:   Range{Max: 1000},
+	})
+	if err != nil {
+		t.Fatalf("NewAggregator error:%s", err)
+	}
+	for _, r := range []silk.Record{
+		{SrcPort: 1, Bytes: 100},
+		{SrcPort: 2, Bytes: 100},
+		{SrcPort: 2, Bytes: 200},
+		{SrcPort: 3, Bytes: 900},
+		{SrcPort: 3, Bytes: 900},
+		{SrcPort: 4, Bytes: 10},
+		{SrcPort: 4, Bytes: 10},
+		{SrcPort: 4, Bytes: 10},
+	} {
+		a.AddRecord(&r)
+	}
+	a.Close()
+	var ports []uint16
+	for a.Next() {
+		ports = append(ports, a.Group().Key.SrcPort)
+	}
+	if fmt.Sprint(ports) != "[2 4]" {
+		t.Errorf("Ports:%v expected:[2 4]", ports)
+	}
+}
+
+//TestDecodeKey verifies keys decode to the record fields they were made from
+func TestDecodeKey(t *testing.T) {
+	var r = silk.Record{SrcPort: 80, Proto: 1, DstPort: 0x0803, StartTimeMS: 1000, Duration: 500, Bytes: 99}
+	var tests = []struct {
+		fields   []format.Field
+		expected silk.Record
+	}{
+		{fields: []format.Field{format.FieldSPort, format.FieldBytes}, expected: silk.Record{SrcPort: 80, Bytes: 99}},
+		{fields: []format.Field{format.FieldIType, format.FieldICode}, expected: silk.Record{Proto: 1, DstPort: 0x0803}},
+		{fields: []format.Field{format.FieldETime}, expected: silk.Record{StartTimeMS: 1500}},
+		{fields: []format.Field{format.FieldSTime, format.FieldETime}, expected: silk.Record{StartTimeMS: 1000, Duration: 500}},
+	}
+	for _, test := range tests {
+		var key []byte
+		for _, f := range test.fields {
+			key = appendKey(key, f, &r)
+		}
+		var decoded silk.Record
+		decodeKey(key, test.fields, &decoded)
+		if decoded != test.expected {
+			t.Errorf("Fields:%v decoded:%+v expected:%+v", test.fields, decoded, test.expected)
+		}
+	}
+}
+
+//TestParseRange verifies range parsing
+func TestParseRange(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected Range
+		err      bool
+	}{
+		{value: "1000-", expected: Range{Min: 1000}},
+		{value: "10-20", expected: Range{Min: 10, Max: 20}},
+		{value: "5", expected: Range{Min: 5}},
+		{value: "20-10", err: true},
+		{value: "-5", err: true},
+		{value: "a-", err: true},
+	}
+	for _, test := range tests {
+		var r, err = ParseRange(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("Value:%s error:%v expected error:%t", test.value, err, test.err)
+		} else if err == nil && r != test.expected {
+			t.Errorf("Value:%s range:%+v expected:%+v", test.value, r, test.expected)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package silk
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/golang/snappy"
+	lzo "github.com/rasky/go-lzo"
+)
+
+//DefaultBlockSize is the number of bytes of records the Writer buffers before
+//writing them, for compressed files this is the uncompressed block size.
+//It matches the silk default block size.
+const DefaultBlockSize = 65536
+
+//Writer encodes Flow values as silk records. Records are buffered and
+//written to the underlying io.Writer a block at a time, Close must be
+//called to write any remaining buffered records.
+//When the header has a compression type each block is compressed and
+//written with the same 8 byte compressed/decompressed size block header
+//parseReader reads.
+type Writer struct {
+	w           io.Writer
+	header      Header
+	encode      func(record []byte, r *Record, h *Header) error
+	record      Record
+	buf         []byte
+	n           int
+	err         error
+	compressed  bytes.Buffer
+	zlibWriter  *zlib.Writer
+	blockHeader []byte
+	snappyBlock []byte
+}
+
+//NewWriter writes the silk header h to w and returns a Writer for its records
+//using DefaultBlockSize. An empty MagicNumber or FileVersion is filled in with
+//the silk defaults and the end of header entry is padded so records start on
+//a multiple of the record size. The record layout is chosen by the header
+//RecordFormat and RecordVersion, a zero RecordSize is filled in from it.
+//Formats that can't be written return an UnsupportedFormatError. FT_RWIPV6
+//version 2 stores start times as an offset from the file date in variable
+//length header entry 1 so it must be present.
+func NewWriter(w io.Writer, h Header) (sw *Writer, err error) {
+	return NewWriterSize(w, h, DefaultBlockSize)
+}
+
+//NewWriterSize is NewWriter with a block size in bytes. Blocks hold a whole
+//number of records so the size must be at least one record long.
+func NewWriterSize(w io.Writer, h Header, blockSize int) (sw *Writer, err error) {
+	var rf recordFormat
+
+	switch h.Compression {
+	case 0, 1, 2, 3:
+	default:
+		err = ErrUnsupportedCompression
+		return
+	}
+	if rf, err = lookupFormat(&h); err != nil {
+		return
+	}
+	if rf.encode == nil {
+		err = &UnsupportedFormatError{Format: h.RecordFormat, Version: h.RecordVersion, RecordSize: h.RecordSize, Encode: true}
+		return
+	}
+	if blockSize < int(h.RecordSize) {
+		err = fmt.Errorf("Block size:%d smaller then record size:%d", blockSize, h.RecordSize)
+		return
+	}
+	if err = writeHeader(w, &h); err != nil {
+		return
+	}
+
+	mod := blockSize / int(h.RecordSize)
+	sw = &Writer{
+		w:           w,
+		header:      h,
+		encode:      rf.encode,
+		buf:         make([]byte, mod*int(h.RecordSize)),
+		blockHeader: make([]byte, 8),
+	}
+	if h.Compression == 1 {
+		sw.zlibWriter = zlib.NewWriter(&sw.compressed)
+	}
+	return
+}
+
+//Header returns the header as it was written, including the end of header padding
+func (sw *Writer) Header() Header {
+	return sw.header
+}
+
+//Write encodes f as the next record. A flow that can't be represented in the
+//record format returns an error and is skipped, the Writer remains usable.
+func (sw *Writer) Write(f Flow) (err error) {
+	sw.record.SetFlow(&f)
+	return sw.WriteRecord(&sw.record)
+}
+
+//WriteRecord is Write for a Record, it does not allocate
+func (sw *Writer) WriteRecord(r *Record) (err error) {
+	if sw.err != nil {
+		return sw.err
+	}
+
+	var recordSize = int(sw.header.RecordSize)
+	if sw.n+recordSize > len(sw.buf) {
+		if err = sw.Flush(); err != nil {
+			return
+		}
+	}
+	if err = sw.encode(sw.buf[sw.n:sw.n+recordSize], r, &sw.header); err != nil {
+		return
+	}
+	sw.n += recordSize
+	return
+}
+
+//Flush writes any buffered records to the underlying io.Writer
+func (sw *Writer) Flush() (err error) {
+	if sw.err != nil {
+		return sw.err
+	}
+	if sw.n == 0 {
+		return
+	}
+	if sw.header.Compression == 0 {
+		if _, err = sw.w.Write(sw.buf[:sw.n]); err != nil {
+			sw.err = err
+			return
+		}
+		sw.n = 0
+		return
+	}
+
+	var block []byte
+	if block, err = sw.compressBlock(sw.buf[:sw.n]); err != nil {
+		sw.err = err
+		return
+	}
+	binary.BigEndian.PutUint32(sw.blockHeader[0:4], uint32(len(block)))
+	binary.BigEndian.PutUint32(sw.blockHeader[4:8], uint32(sw.n))
+	if _, err = sw.w.Write(sw.blockHeader); err != nil {
+		sw.err = err
+		return
+	}
+	if _, err = sw.w.Write(block); err != nil {
+		sw.err = err
+		return
+	}
+	sw.n = 0
+	return
+}
+
+//compressBlock compresses a block of records with the header compression type
+func (sw *Writer) compressBlock(src []byte) (block []byte, err error) {
+	switch sw.header.Compression {
+	case 1:
+		sw.compressed.Reset()
+		sw.zlibWriter.Reset(&sw.compressed)
+		if _, err = sw.zlibWriter.Write(src); err != nil {
+			return
+		}
+		if err = sw.zlibWriter.Close(); err != nil {
+			return
+		}
+		block = sw.compressed.Bytes()
+	case 2:
+		block = lzo.Compress1X(src)
+	case 3:
+		sw.snappyBlock = snappy.Encode(sw.snappyBlock[:cap(sw.snappyBlock)], src)
+		block = sw.snappyBlock
+	default:
+		err = ErrUnsupportedCompression
+	}
+	return
+}
+
+//Close flushes buffered records. It does not close the underlying io.Writer.
+func (sw *Writer) Close() (err error) {
+	return sw.Flush()
+}
+
+//encodeFlow is the inverse of decodeFlow
+func encodeFlow(record []byte, f *Record, h *Header, o offsets) (err error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if h.FileFlags != 0 {
+		order = binary.BigEndian
+	}
+
+	for i := range record {
+		record[i] = 0
+	}
+
+	if o.packedStartTime {
+		if f.StartTimeMS < h.fileDateMS || f.StartTimeMS-h.fileDateMS > uint64(calMsec) {
+			err = fmt.Errorf("Start time:%d is outside of file hour starting:%d", f.StartTimeMS, h.fileDateMS)
+			return
+		}
+		var rflagStime = uint32(f.StartTimeMS - h.fileDateMS)
+		if f.Proto == 6 {
+			rflagStime |= isTCPAnd
+			if (f.Attributes & SilkTCPStateExpanded) != 0 {
+				rflagStime |= uint32(f.SessionFlags) << 24
+				record[4] = f.InitalFlags
+			} else {
+				record[4] = f.Flags
+			}
+		} else {
+			record[4] = f.Proto
+		}
+		record[5] = f.Attributes
+		order.PutUint32(record[o.startStartTime:o.endStartTime], rflagStime)
+	} else {
+		order.PutUint64(record[o.startStartTime:o.endStartTime], f.StartTimeMS)
+		record[o.startProto] = f.Proto
+		record[o.startTCPFlags] = f.Flags
+		record[o.startClassType] = f.ClassType
+		order.PutUint16(record[o.startSensor:o.endSensor], f.Sensor)
+		record[o.startInitalFlags] = f.InitalFlags
+		record[o.startSessionFlags] = f.SessionFlags
+		record[o.startAttributes] = f.Attributes
+	}
+
+	order.PutUint32(record[o.startDuration:o.endDuration], f.Duration)
+	order.PutUint16(record[o.startSrcPort:o.endSrcPort], f.SrcPort)
+	order.PutUint16(record[o.startDstPort:o.endDstPort], f.DstPort)
+	order.PutUint32(record[o.startPackets:o.endPackets], f.Packets)
+	order.PutUint32(record[o.startBytes:o.endBytes], f.Bytes)
+	order.PutUint16(record[o.startApplication:o.endApplication], f.Application)
+
+	if o.routing {
+		order.PutUint16(record[o.startSNMPIn:o.endSNMPIn], f.SNMPIn)
+		order.PutUint16(record[o.startSNMPOut:o.endSNMPOut], f.SNMPOut)
+		if err = putAddr(record[o.startNextHopIP:o.endNextHopIP], f.NextHopIP, order); err != nil {
+			return
+		}
+	}
+	if err = putAddr(record[o.startSrcIP:o.endSrcIP], f.SrcIP, order); err != nil {
+		return
+	}
+	if err = putAddr(record[o.startDstIP:o.endDstIP], f.DstIP, order); err != nil {
+		return
+	}
+	return
+}
+
+//putAddr writes addr into a 16 byte IPv6 field or a 4 byte IPv4 field.
+//IPv4 fields are stored as a uint32 in the file byte order, a zero addr is
+//written as all zeros.
+func putAddr(dst []byte, addr netip.Addr, order binary.ByteOrder) (err error) {
+	if addr.IsValid() == false {
+		return
+	}
+	if len(dst) == 16 {
+		var b = addr.As16()
+		copy(dst, b[:])
+		return
+	}
+	if addr.Is4() == false {
+		err = fmt.Errorf("IPv6 address:%s can not be stored in an IPv4 record", addr.String())
+		return
+	}
+	var b = addr.As4()
+	order.PutUint32(dst, binary.BigEndian.Uint32(b[:]))
+	return
+}
@@ -0,0 +1,220 @@
+package silk
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+//getWriterTestFileList returns the test files the Writer is able to reproduce
+func getWriterTestFileList() []string {
+	return []string{
+		"testdata/FT_RWIPV6-v1-c1-L.dat",
+		"testdata/FT_RWIPV6-v1-c1-B.dat",
+		"testdata/FT_RWIPV6-v2-c1-L.dat",
+		"testdata/FT_RWIPV6-v2-c1-B.dat",
+		"testdata/FT_RWIPV6ROUTING-v2-c1-L.dat",
+		"testdata/FT_RWIPV6ROUTING-v2-c1-B.dat",
+		"testdata/FT_RWGENERIC-v5-c1-L.dat",
+		"testdata/FT_RWGENERIC-v5-c1-B.dat",
+	}
+}
+
+//writeTestFile writes flows to a buffer using header h
+func writeTestFile(t *testing.T, h Header, flows []Flow) (buf *bytes.Buffer, written Header) {
+	return writeTestFileSize(t, h, flows, DefaultBlockSize)
+}
+
+//writeTestFileSize writes flows to a buffer using header h and blockSize
+func writeTestFileSize(t *testing.T, h Header, flows []Flow, blockSize int) (buf *bytes.Buffer, written Header) {
+	var err error
+	var sw *Writer
+
+	buf = new(bytes.Buffer)
+	if sw, err = NewWriterSize(buf, h, blockSize); err != nil {
+		t.Fatalf("NewWriter error:%s", err)
+	}
+	for _, flow := range flows {
+		if err = sw.Write(flow); err != nil {
+			t.Fatalf("Write error:%s", err)
+		}
+	}
+	if err = sw.Close(); err != nil {
+		t.Fatalf("Close error:%s", err)
+	}
+	return buf, sw.Header()
+}
+
+//TestWriterRoundTrip writes every flow of each test file uncompressed and
+//verifies the header bytes match the original and the flows read back equal.
+func TestWriterRoundTrip(t *testing.T) {
+	for _, filePath := range getWriterTestFileList() {
+		var sf, sf2 File
+		var err error
+		var original []byte
+
+		if sf, err = OpenFile(filePath); err != nil {
+			t.Errorf("OpenFile file:%s error:%s", filePath, err)
+			continue
+		}
+		if original, err = ioutil.ReadFile(filePath); err != nil {
+			t.Errorf("ReadFile file:%s error:%s", filePath, err)
+			continue
+		}
+
+		var h = sf.Header
+		h.Compression = 0
+		buf, written := writeTestFile(t, h, sf.Flows)
+
+		if written.HeaderLength != sf.Header.HeaderLength {
+			t.Errorf("File:%s header length:%d expected:%d", filePath, written.HeaderLength, sf.Header.HeaderLength)
+		}
+		var headerBytes = buf.Bytes()[:written.HeaderLength]
+		for x := range headerBytes {
+			if x == 7 {
+				//compression
+				continue
+			}
+			if headerBytes[x] != original[x] {
+				t.Errorf("File:%s header byte:%d is:%x expected:%x", filePath, x, headerBytes[x], original[x])
+				break
+			}
+		}
+		if buf.Len() != written.HeaderLength+len(sf.Flows)*int(h.RecordSize) {
+			t.Errorf("File:%s length:%d expected:%d", filePath, buf.Len(), written.HeaderLength+len(sf.Flows)*int(h.RecordSize))
+		}
+
+		receiver := NewSliceFlowReceiver(len(sf.Flows))
+		if err = Parse(buf, receiver); err != nil {
+			t.Errorf("Parse file:%s error:%s", filePath, err)
+			continue
+		}
+		sf2 = receiver.File
+
+		if len(sf2.Flows) != len(sf.Flows) {
+			t.Errorf("File:%s Rows found:%d, rows expected:%d", filePath, len(sf2.Flows), len(sf.Flows))
+			continue
+		}
+		for x := range sf.Flows {
+			if reflect.DeepEqual(sf.Flows[x], sf2.Flows[x]) == false {
+				t.Errorf("File:%s row:%d written:%+v read:%+v", filePath, x, sf.Flows[x], sf2.Flows[x])
+				break
+			}
+		}
+	}
+}
+
+//TestWriterInvalidFlows verifies flows that don't fit the record format are rejected
+func TestWriterInvalidFlows(t *testing.T) {
+	var sf File
+	var err error
+	var sw *Writer
+
+	if sf, err = OpenFile("testdata/FT_RWIPV6-v2-c1-L.dat"); err != nil {
+		t.Fatalf("OpenFile error:%s", err)
+	}
+	var h = sf.Header
+	h.Compression = 0
+	if sw, err = NewWriter(ioutil.Discard, h); err != nil {
+		t.Fatalf("NewWriter error:%s", err)
+	}
+	var flow = sf.Flows[0]
+	flow.StartTimeMS += 3600 * 1000 * 2
+	if err = sw.Write(flow); err == nil {
+		t.Errorf("Write start time outside file hour should fail")
+	}
+
+	h.RecordSize = 52
+	h.RecordFormat = 0x16
+	h.RecordVersion = 5
+	if sw, err = NewWriter(ioutil.Discard, h); err != nil {
+		t.Fatalf("NewWriter error:%s", err)
+	}
+	flow = sf.Flows[0]
+	flow.SrcIP = net.ParseIP("2001:db8::1")
+	if err = sw.Write(flow); err == nil {
+		t.Errorf("Write IPv6 address to IPv4 record should fail")
+	}
+
+	h.RecordSize = 12
+	if _, err = NewWriter(ioutil.Discard, h); err == nil {
+		t.Errorf("NewWriter unsupported record size should fail")
+	}
+}
+
+//compareTestFlows verifies the flows read back from a written file
+func compareTestFlows(t *testing.T, name string, expected []Flow, buf *bytes.Buffer) {
+	var err error
+
+	receiver := NewSliceFlowReceiver(len(expected))
+	if err = Parse(buf, receiver); err != nil {
+		t.Errorf("Parse %s error:%s", name, err)
+		return
+	}
+	if len(receiver.Flows) != len(expected) {
+		t.Errorf("%s Rows found:%d, rows expected:%d", name, len(receiver.Flows), len(expected))
+		return
+	}
+	for x := range expected {
+		if reflect.DeepEqual(expected[x], receiver.Flows[x]) == false {
+			t.Errorf("%s row:%d written:%+v read:%+v", name, x, expected[x], receiver.Flows[x])
+			return
+		}
+	}
+}
+
+//TestWriterCompressionRoundTrip rewrites every file in testdata with its own
+//compression and byte order and verifies the flows read back.
+func TestWriterCompressionRoundTrip(t *testing.T) {
+	var filePaths []string
+	var err error
+
+	if filePaths, err = filepath.Glob("testdata/*.dat"); err != nil {
+		t.Fatalf("Glob error:%s", err)
+	}
+	for _, filePath := range filePaths {
+		var sf File
+		if sf, err = OpenFile(filePath); err != nil {
+			t.Logf("Skipping unsupported file:%s error:%s", filePath, err)
+			continue
+		}
+		if _, err = NewWriter(ioutil.Discard, sf.Header); err != nil {
+			t.Logf("Skipping file the writer does not support:%s error:%s", filePath, err)
+			continue
+		}
+		buf, written := writeTestFile(t, sf.Header, sf.Flows)
+		if written.Compression != sf.Header.Compression {
+			t.Errorf("File:%s compression:%d expected:%d", filePath, written.Compression, sf.Header.Compression)
+		}
+		compareTestFlows(t, filePath, sf.Flows, buf)
+	}
+}
+
+//TestWriterBlockSize writes small blocks with every compression type
+func TestWriterBlockSize(t *testing.T) {
+	var sf File
+	var err error
+
+	if sf, err = OpenFile("testdata/FT_RWIPV6ROUTING-v2-c1-B.dat"); err != nil {
+		t.Fatalf("OpenFile error:%s", err)
+	}
+	var flows = sf.Flows[:1000]
+	for _, compression := range []uint8{0, 1, 2, 3} {
+		var h = sf.Header
+		h.Compression = compression
+		buf, _ := writeTestFileSize(t, h, flows, int(h.RecordSize)*3+1)
+		compareTestFlows(t, "small block", flows, buf)
+	}
+
+	if _, err = NewWriterSize(ioutil.Discard, sf.Header, int(sf.Header.RecordSize)-1); err == nil {
+		t.Errorf("NewWriterSize block smaller then record should fail")
+	}
+	var h = sf.Header
+	h.Compression = 4
+	if _, err = NewWriter(ioutil.Discard, h); err != ErrUnsupportedCompression {
+		t.Errorf("NewWriter compression:4 error:%v expected:%s", err, ErrUnsupportedCompression)
+	}
+}